@@ -27,6 +27,11 @@ func NewGoCallback() GoCallback {
 	return GoCallback{}
 }
 
+// SetCallback sets the function that receives the samples of the Cb calls
+func (p *GoCallback) SetCallback(cb SamplesCallback) {
+	p.callback = cb
+}
+
 func (p *GoCallback) Info(str string) {
 	SLog.Info("%s", str)
 }
@@ -103,3 +108,12 @@ type BaseFrontend interface {
 	Init() bool
 	Destroy()
 }
+
+// MakeFanOut returns a SamplesCallback that calls all callbacks with the same data
+func MakeFanOut(callbacks ...SamplesCallback) SamplesCallback {
+	return func(data SampleCallbackData) {
+		for _, cb := range callbacks {
+			cb(data)
+		}
+	}
+}
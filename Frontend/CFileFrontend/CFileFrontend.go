@@ -7,6 +7,7 @@ import (
 	"github.com/OpenSatelliteProject/SatHelperApp/Logger"
 	. "github.com/logrusorgru/aurora"
 	"github.com/racerxdl/kissdvb/Frontend"
+	"io"
 	"os"
 	"sync"
 	"time"
@@ -14,36 +15,97 @@ import (
 
 const BufferSize = 65535
 
+// How often a paused playback checks for resume
+const pausePoll = 10 * time.Millisecond
+
 // region Struct Definition
 type CFileFrontend struct {
 	sync.Mutex
 	running         bool
 	filename        string
-	cb              Frontend.SamplesCallback
+	format          string
+	decoder         *Frontend.SampleDecoder
+	callback        Frontend.GoCallback
 	sampleRate      uint32
 	centerFrequency uint32
-	sampleBuffer    []complex64
+	readBuffer      []byte
 	fileHandler     *os.File
-	t0              time.Time
 	fastAsPossible  bool
+	pacer           pacer
+
+	speed    float64
+	loop     bool
+	paused   bool
+	position int64 // Next sample read
+	length   int64 // Samples in the file
+	seekTo   int64 // Pending seek, -1 for none
+	run      int   // Incremented by each Start, a reader from a previous one stops
+	started  bool
+	done     chan struct{}
 }
 
 // endregion
 // region Constructor
 func NewCFileFrontend(filename string) *CFileFrontend {
+	decoder, _ := Frontend.MakeSampleDecoder(Frontend.FormatCF32, binary.LittleEndian)
+
 	return &CFileFrontend{
 		filename:        filename,
+		format:          Frontend.FormatCF32,
+		decoder:         decoder,
+		callback:        Frontend.NewGoCallback(),
 		running:         false,
 		sampleRate:      0,
 		centerFrequency: 0,
-		cb:              nil,
 		fastAsPossible:  false,
+		speed:           1,
+		seekTo:          -1,
+		done:            make(chan struct{}),
 	}
 }
 
+// NewSigMFFrontend creates a frontend for a SigMF recording, with the sample format, sample rate and center frequency
+// of its metadata
+func NewSigMFFrontend(path string) (*CFileFrontend, error) {
+	metaPath, dataPath, err := Frontend.SigMFPaths(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := Frontend.ReadSigMFMeta(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	format, byteOrder, err := Frontend.ParseSigMFDatatype(meta.Global.Datatype)
+	if err != nil {
+		return nil, err
+	}
+
+	f := NewCFileFrontend(dataPath)
+	if err := f.SetSampleFormat(format, byteOrder); err != nil {
+		return nil, err
+	}
+	f.SetSampleRate(uint32(meta.Global.SampleRate))
+	f.SetCenterFrequency(uint32(meta.CenterFrequency()))
+
+	return f, nil
+}
+
 // endregion
 // region Getters
+
+// Done is closed when the playback ends: at the end of the file when not looping, on a read error or when stopped.
+// Each Start has a new one.
+func (f *CFileFrontend) Done() <-chan struct{} {
+	f.Lock()
+	defer f.Unlock()
+	return f.done
+}
 func (f *CFileFrontend) GetName() string {
+	if f.format != Frontend.FormatCF32 {
+		return fmt.Sprintf("CFileFrontend (%s %s)", f.filename, f.format)
+	}
 	return fmt.Sprintf("CFileFrontend (%s)", f.filename)
 }
 func (f *CFileFrontend) GetShortName() string {
@@ -65,7 +127,19 @@ func (f *CFileFrontend) EnableFastAsPossible() {
 // endregion
 // region Setters
 func (f *CFileFrontend) SetSamplesAvailableCallback(cb Frontend.SamplesCallback) {
-	f.cb = cb
+	f.callback.SetCallback(cb)
+}
+
+// SetSampleFormat sets the Frontend sample format of the file and its byte order, cf32 little endian by default
+func (f *CFileFrontend) SetSampleFormat(format string, byteOrder binary.ByteOrder) error {
+	decoder, err := Frontend.MakeSampleDecoder(format, byteOrder)
+	if err != nil {
+		return err
+	}
+
+	f.format = format
+	f.decoder = decoder
+	return nil
 }
 func (f *CFileFrontend) SetSampleRate(sampleRate uint32) uint32 {
 	f.sampleRate = sampleRate
@@ -82,10 +156,17 @@ func (f *CFileFrontend) Init() bool {
 	return true
 }
 func (f *CFileFrontend) Destroy() {}
-func (f *CFileFrontend) isRunning() bool {
+func (f *CFileFrontend) isRunning(run int) bool {
 	f.Lock()
 	defer f.Unlock()
-	return f.running
+	return f.running && f.run == run
+}
+func (f *CFileFrontend) stopRun(run int) {
+	f.Lock()
+	defer f.Unlock()
+	if f.run == run {
+		f.running = false
+	}
 }
 func (f *CFileFrontend) Start() {
 	f.Lock()
@@ -97,54 +178,87 @@ func (f *CFileFrontend) Start() {
 	}
 
 	f.running = true
+	f.run++
+	if f.started {
+		f.done = make(chan struct{})
+	}
+	f.started = true
 
-	go func(frontend *CFileFrontend) {
+	go func(frontend *CFileFrontend, run int, done chan struct{}) {
 		SLog.Info("CFileFrontend Routine started")
-		f, err := os.Open(f.filename)
-
-		var period = BufferSize / float32(frontend.sampleRate)
+		defer close(done)
 
+		f, err := os.Open(frontend.filename)
 		if err != nil {
 			SLog.Error("Error opening file %s: %s", Bold(frontend.filename), Bold(err))
-			frontend.running = false
+			frontend.stopRun(run)
 			return
 		}
-		defer frontend.fileHandler.Close()
+		defer f.Close()
+
+		sampleSize := int64(frontend.decoder.SampleSize())
+		if info, err := f.Stat(); err == nil {
+			frontend.Lock()
+			frontend.length = info.Size() / sampleSize
+			frontend.Unlock()
+		}
 
 		frontend.fileHandler = f
-		frontend.t0 = time.Now()
-		frontend.sampleBuffer = make([]complex64, BufferSize)
+		frontend.readBuffer = make([]byte, BufferSize*sampleSize)
+		frontend.pacer.reset(frontend.getRate())
 
 		var reader = bufio.NewReader(f)
 
-		if frontend.fastAsPossible {
-			period /= 8 // Avoid lock up
-		}
-
-		for frontend.isRunning() {
-			if float32(time.Since(frontend.t0).Seconds()) >= period {
-				err := binary.Read(reader, binary.LittleEndian, frontend.sampleBuffer)
-				if err != nil {
-					SLog.Error("Error reading input CFile: %s", Bold(err))
-					frontend.running = false
-					break
+		for frontend.isRunning(run) {
+			// Seeks are applied while paused too, so the position follows them
+			if sample, ok := frontend.takeSeek(); ok {
+				if _, err := f.Seek(sample*sampleSize, io.SeekStart); err != nil {
+					SLog.Error("Error seeking input CFile: %s", Bold(err))
+				} else {
+					reader.Reset(f)
+					frontend.setPosition(sample)
 				}
-				if frontend.cb != nil {
-					var cbData = Frontend.SampleCallbackData{
-						SampleType:   Frontend.SampleTypeFloatIQ,
-						NumSamples:   len(frontend.sampleBuffer),
-						ComplexArray: frontend.sampleBuffer,
-					}
-					frontend.cb(cbData)
+				frontend.pacer.reset(frontend.getRate())
+			}
+
+			if frontend.IsPaused() {
+				time.Sleep(pausePoll)
+				frontend.pacer.reset(frontend.getRate())
+				continue
+			}
+
+			if rate := frontend.getRate(); rate != frontend.pacer.getRate() {
+				frontend.pacer.reset(rate)
+			}
+
+			frontend.pacer.wait(BufferSize)
+
+			n, err := io.ReadFull(reader, frontend.readBuffer)
+			if n > 0 {
+				frontend.decoder.Decode(frontend.readBuffer[:n], &frontend.callback)
+				frontend.setPosition(frontend.GetPosition() + int64(n)/sampleSize)
+				frontend.pacer.delivered(n / int(sampleSize))
+			}
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				// Nothing was read since the start of the file, there is nothing to loop
+				if frontend.IsLooping() && frontend.GetPosition() > 0 {
+					frontend.SeekSample(0)
+					continue
 				}
-				frontend.t0 = time.Now()
+				SLog.Info("End of file %s", Bold(frontend.filename))
+				frontend.stopRun(run)
+				break
 			}
-			if !frontend.fastAsPossible {
-				time.Sleep(time.Duration((period / 100) * float32(time.Second)))
+
+			if err != nil {
+				SLog.Error("Error reading input CFile: %s", Bold(err))
+				frontend.stopRun(run)
+				break
 			}
 		}
 		SLog.Error("CFileFrontend Routine ended")
-	}(f)
+	}(f, f.run, f.done)
 }
 
 func (f *CFileFrontend) Stop() {
@@ -167,3 +281,147 @@ func (f *CFileFrontend) SetGain3(uint8)    {}
 func (f *CFileFrontend) SetBiasT(bool)     {}
 
 // endregion
+// region Playback
+
+// getRate returns the samples per second of the playback speed
+func (f *CFileFrontend) getRate() float64 {
+	f.Lock()
+	defer f.Unlock()
+
+	rate := float64(f.sampleRate) * f.speed
+	if f.fastAsPossible {
+		rate *= 8 // Avoid lock up
+	}
+
+	return rate
+}
+
+// SetSpeed sets the playback speed, 1 for real time
+func (f *CFileFrontend) SetSpeed(speed float64) {
+	if speed <= 0 {
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.speed = speed
+}
+func (f *CFileFrontend) GetSpeed() float64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.speed
+}
+
+// SetLoop restarts the playback at the end of the file
+func (f *CFileFrontend) SetLoop(loop bool) {
+	f.Lock()
+	defer f.Unlock()
+	f.loop = loop
+}
+func (f *CFileFrontend) IsLooping() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.loop
+}
+func (f *CFileFrontend) Pause() {
+	f.Lock()
+	defer f.Unlock()
+	f.paused = true
+}
+func (f *CFileFrontend) Resume() {
+	f.Lock()
+	defer f.Unlock()
+	f.paused = false
+}
+func (f *CFileFrontend) IsPaused() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.paused
+}
+
+// SeekSample moves the playback to the sample, clamped to the file. It is done before the next read, also when paused,
+// and can be called before Start.
+func (f *CFileFrontend) SeekSample(sample int64) {
+	f.Lock()
+	defer f.Unlock()
+
+	if sample < 0 {
+		sample = 0
+	}
+	f.seekTo = sample
+}
+
+// SeekTime moves the playback to the time from the start of the file
+func (f *CFileFrontend) SeekTime(t time.Duration) {
+	f.SeekSample(int64(t.Seconds() * float64(f.sampleRate)))
+}
+
+func (f *CFileFrontend) takeSeek() (int64, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	sample := f.seekTo
+	f.seekTo = -1
+
+	if sample > f.length {
+		sample = f.length
+	}
+
+	return sample, sample >= 0
+}
+func (f *CFileFrontend) setPosition(sample int64) {
+	f.Lock()
+	defer f.Unlock()
+	f.position = sample
+}
+
+// GetPosition returns the sample of the next read
+func (f *CFileFrontend) GetPosition() int64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.position
+}
+
+// GetLength returns the samples of the file, known after Start
+func (f *CFileFrontend) GetLength() int64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.length
+}
+
+// GetTime returns the playback position as time from the start of the file
+func (f *CFileFrontend) GetTime() time.Duration {
+	return f.samplesToDuration(f.GetPosition())
+}
+
+// GetDuration returns the duration of the file, known after Start
+func (f *CFileFrontend) GetDuration() time.Duration {
+	return f.samplesToDuration(f.GetLength())
+}
+
+// GetThroughput returns the samples per second measured over the last second of playback
+func (f *CFileFrontend) GetThroughput() float64 {
+	throughput, _, _ := f.pacer.getStats()
+	return throughput
+}
+
+// GetUnderruns returns how many times the reads were late by more than a buffer and had to catch up
+func (f *CFileFrontend) GetUnderruns() int64 {
+	_, underruns, _ := f.pacer.getStats()
+	return underruns
+}
+
+// GetOverruns returns how many times the reads were too late to catch up and the playback clock was restarted
+func (f *CFileFrontend) GetOverruns() int64 {
+	_, _, overruns := f.pacer.getStats()
+	return overruns
+}
+
+func (f *CFileFrontend) samplesToDuration(samples int64) time.Duration {
+	if f.sampleRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(f.sampleRate) * float64(time.Second))
+}
+
+// endregion
@@ -0,0 +1,158 @@
+package CFileFrontend
+
+import (
+	"github.com/racerxdl/kissdvb/Frontend"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(pausePoll)
+	}
+}
+
+// makeCFile writes a file of numSamples cf32 zero samples
+func makeCFile(t *testing.T, numSamples int) string {
+	f, err := ioutil.TempFile("", "cfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(make([]byte, numSamples*8)); err != nil {
+		os.Remove(f.Name())
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func waitDone(t *testing.T, frontend *CFileFrontend) {
+	select {
+	case <-frontend.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("playback didn't end")
+	}
+}
+
+func TestSeekWhilePaused(t *testing.T) {
+	const numSamples = 4 * BufferSize
+
+	filename := makeCFile(t, numSamples)
+	defer os.Remove(filename)
+
+	frontend := NewCFileFrontend(filename)
+	frontend.SetSampleRate(BufferSize)
+	frontend.SetSamplesAvailableCallback(func(Frontend.SampleCallbackData) {})
+	frontend.Pause()
+	frontend.Start()
+	defer frontend.Stop()
+
+	frontend.SeekSample(3 * BufferSize)
+	waitFor(t, "the seek while paused", func() bool { return frontend.GetPosition() == 3*BufferSize })
+
+	time.Sleep(5 * pausePoll)
+	if p := frontend.GetPosition(); p != 3*BufferSize {
+		t.Fatalf("paused playback moved to %d", p)
+	}
+
+	frontend.EnableFastAsPossible()
+	frontend.Resume()
+	waitDone(t, frontend)
+
+	if p := frontend.GetPosition(); p != numSamples {
+		t.Fatalf("playback ended at %d, expected %d", p, numSamples)
+	}
+}
+
+func TestLoopEmptyFile(t *testing.T) {
+	filename := makeCFile(t, 0)
+	defer os.Remove(filename)
+
+	frontend := NewCFileFrontend(filename)
+	frontend.SetSampleRate(BufferSize)
+	frontend.SetLoop(true)
+	frontend.SetSamplesAvailableCallback(func(Frontend.SampleCallbackData) {})
+	frontend.Start()
+
+	// An empty file ends the playback instead of seeking to its start forever
+	waitDone(t, frontend)
+	if frontend.isRunning(frontend.run) {
+		t.Fatal("still running after the end of an empty file")
+	}
+}
+
+func TestRestart(t *testing.T) {
+	filename := makeCFile(t, 2*BufferSize)
+	defer os.Remove(filename)
+
+	frontend := NewCFileFrontend(filename)
+	frontend.SetSampleRate(BufferSize)
+	frontend.EnableFastAsPossible()
+	frontend.SetSamplesAvailableCallback(func(Frontend.SampleCallbackData) {})
+
+	// Played to the end
+	done := frontend.Done()
+	frontend.Start()
+	waitDone(t, frontend)
+
+	// Stopped while playing, then started again before the reader ended
+	frontend.SetLoop(true)
+	frontend.Start()
+	if frontend.Done() == done {
+		t.Fatal("Done not renewed by Start")
+	}
+	frontend.Stop()
+	frontend.Start()
+	frontend.Stop()
+	waitDone(t, frontend)
+
+	frontend.SetLoop(false)
+	frontend.SeekSample(0)
+	frontend.Start()
+	waitDone(t, frontend)
+}
+
+func TestSigMFFrontend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigmf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "capture")
+	meta := `{"global": {"core:datatype": "ci16_be", "core:sample_rate": 250000, "core:version": "1.0.0"},
+		"captures": [{"core:sample_start": 0, "core:frequency": 1.2e9}], "annotations": []}`
+	if err := ioutil.WriteFile(base+Frontend.SigMFMetaExtension, []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(base+Frontend.SigMFDataExtension, make([]byte, 400), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Either file of the recording
+	for _, path := range []string{base + Frontend.SigMFMetaExtension, base + Frontend.SigMFDataExtension} {
+		f, err := NewSigMFFrontend(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.filename != base+Frontend.SigMFDataExtension || f.format != Frontend.FormatCS16 ||
+			f.GetSampleRate() != 250000 || f.GetCenterFrequency() != 1200000000 {
+			t.Errorf("%s: %s, %d samples/s at %d Hz", path, f.GetName(), f.GetSampleRate(), f.GetCenterFrequency())
+		}
+	}
+
+	// The archive isn't read as a base name
+	if _, err := NewSigMFFrontend(base + Frontend.SigMFArchiveExtension); err == nil || !strings.Contains(err.Error(), "archive") {
+		t.Errorf("SigMF archive opened: %v", err)
+	}
+}
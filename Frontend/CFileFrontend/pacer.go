@@ -0,0 +1,129 @@
+package CFileFrontend
+
+import (
+	"sync"
+	"time"
+)
+
+// Lag after which the pacer stops catching up and restarts its clock
+const maxLag = 500 * time.Millisecond
+
+// Window of the measured throughput
+const throughputWindow = time.Second
+
+// pacer schedules the reads on an absolute clock. The buffer starting at the n-th sample is due at
+// start + n / rate, so the average rate is exact and the scheduling jitter doesn't accumulate.
+type pacer struct {
+	sync.Mutex
+	start   time.Time
+	samples int64   // Samples delivered since start
+	rate    float64 // Samples per second
+	late    bool
+
+	underruns int64 // Reads late by more than a buffer, caught up by reading without waiting
+	overruns  int64 // Reads late by more than maxLag, the clock was restarted
+
+	windowStart   time.Time
+	windowSamples int64
+	throughput    float64
+
+	now   func() time.Time    // time.Now when nil
+	sleep func(time.Duration) // time.Sleep when nil
+}
+
+func (p *pacer) currentTime() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+func (p *pacer) sleepFor(d time.Duration) {
+	if p.sleep != nil {
+		p.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// reset restarts the clock at rate, after a pause, a seek or a speed change
+func (p *pacer) reset(rate float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.start = p.currentTime()
+	p.samples = 0
+	p.rate = rate
+	p.late = false
+	p.windowStart = time.Time{}
+	p.windowSamples = 0
+}
+
+func (p *pacer) getRate() float64 {
+	p.Lock()
+	defer p.Unlock()
+	return p.rate
+}
+
+// wait sleeps until the next buffer of bufferSamples is due. A zero rate doesn't wait.
+func (p *pacer) wait(bufferSamples int) {
+	p.Lock()
+	if p.rate <= 0 {
+		p.Unlock()
+		return
+	}
+
+	due := p.start.Add(time.Duration(float64(p.samples) / p.rate * float64(time.Second)))
+	lag := p.currentTime().Sub(due)
+
+	if lag <= 0 {
+		p.late = false
+		p.Unlock()
+		p.sleepFor(-lag)
+		return
+	}
+
+	defer p.Unlock()
+
+	if lag > maxLag {
+		p.overruns++
+		p.late = false
+		p.start = p.currentTime()
+		p.samples = 0
+		return
+	}
+
+	period := time.Duration(float64(bufferSamples) / p.rate * float64(time.Second))
+	if lag > period && !p.late {
+		p.underruns++
+		p.late = true
+	}
+}
+
+// delivered counts the samples read and updates the throughput
+func (p *pacer) delivered(samples int) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.samples += int64(samples)
+
+	// The window starts at the first read, the samples of a read are counted in the time up to it
+	if p.windowStart.IsZero() {
+		p.windowStart = p.currentTime()
+		return
+	}
+
+	p.windowSamples += int64(samples)
+
+	if elapsed := p.currentTime().Sub(p.windowStart); elapsed >= throughputWindow {
+		p.throughput = float64(p.windowSamples) / elapsed.Seconds()
+		p.windowStart = p.currentTime()
+		p.windowSamples = 0
+	}
+}
+
+func (p *pacer) getStats() (throughput float64, underruns, overruns int64) {
+	p.Lock()
+	defer p.Unlock()
+	return p.throughput, p.underruns, p.overruns
+}
@@ -0,0 +1,123 @@
+package CFileFrontend
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is the pacer time, it only moves when advanced or slept
+type fakeClock struct {
+	t     time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.slept += d
+	c.t = c.t.Add(d)
+}
+
+func makeTestPacer() (*pacer, *fakeClock) {
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	return &pacer{now: c.now, sleep: c.sleep}, c
+}
+
+func TestPacerClock(t *testing.T) {
+	const rate = 1000
+	const buffer = 100
+
+	p, c := makeTestPacer()
+	p.reset(rate)
+	start := c.t
+
+	// The reads are due at start + n / rate whatever the time spent between them
+	for i := 0; i < 25; i++ {
+		p.wait(buffer)
+		if due := start.Add(time.Duration(i) * buffer * time.Second / rate); !c.t.Equal(due) {
+			t.Fatalf("read %d at %s, due at %s", i, c.t.Sub(start), due.Sub(start))
+		}
+		p.delivered(buffer)
+		c.t = c.t.Add(time.Duration(i%4) * 20 * time.Millisecond)
+	}
+
+	// 25 buffers of 100 ms, the time between them was slept
+	if c.t.Sub(start) < 2400*time.Millisecond || c.slept > 2400*time.Millisecond {
+		t.Errorf("%s elapsed, %s slept", c.t.Sub(start), c.slept)
+	}
+
+	throughput, underruns, overruns := p.getStats()
+	if throughput != rate || underruns != 0 || overruns != 0 {
+		t.Errorf("throughput %g, %d underruns, %d overruns", throughput, underruns, overruns)
+	}
+}
+
+func TestPacerLate(t *testing.T) {
+	const rate = 1000
+	const buffer = 100
+
+	p, c := makeTestPacer()
+	p.reset(rate)
+
+	p.wait(buffer)
+	p.delivered(buffer)
+
+	// Late by more than a buffer: counted once and caught up without sleeping
+	c.t = c.t.Add(250 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		p.wait(buffer)
+		p.delivered(buffer)
+	}
+	if c.slept != 0 {
+		t.Errorf("slept %s while late", c.slept)
+	}
+	if _, underruns, overruns := p.getStats(); underruns != 1 || overruns != 0 {
+		t.Errorf("%d underruns and %d overruns, expected 1 and 0", underruns, overruns)
+	}
+
+	// Caught up, the next read waits again
+	p.wait(buffer)
+	p.delivered(buffer)
+	if c.slept != 50*time.Millisecond {
+		t.Errorf("slept %s after catching up, expected 50ms", c.slept)
+	}
+
+	// Late by more than maxLag: the clock restarts at the read
+	c.t = c.t.Add(maxLag + time.Second)
+	restart := c.t
+	p.wait(buffer)
+	p.delivered(buffer)
+	p.wait(buffer)
+	if _, underruns, overruns := p.getStats(); underruns != 1 || overruns != 1 {
+		t.Errorf("%d underruns and %d overruns, expected 1 and 1", underruns, overruns)
+	}
+	if due := restart.Add(buffer * time.Second / rate); !c.t.Equal(due) {
+		t.Errorf("read after the restart at %s, expected %s", c.t.Sub(restart), due.Sub(restart))
+	}
+}
+
+func TestPacerRate(t *testing.T) {
+	f := NewCFileFrontend("")
+	f.SetSampleRate(2000000)
+	f.SetSpeed(0.5)
+	if r := f.getRate(); r != 1000000 {
+		t.Errorf("rate %g at half speed", r)
+	}
+
+	// As fast as possible is 8 times the speed
+	f.EnableFastAsPossible()
+	if r := f.getRate(); r != 8000000 {
+		t.Errorf("rate %g as fast as possible", r)
+	}
+
+	// A zero rate doesn't wait
+	p, c := makeTestPacer()
+	p.reset(0)
+	p.delivered(1000)
+	p.wait(1000)
+	if c.slept != 0 {
+		t.Errorf("slept %s at rate 0", c.slept)
+	}
+}
@@ -0,0 +1,320 @@
+package NetworkFrontend
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/OpenSatelliteProject/SatHelperApp/Logger"
+	. "github.com/logrusorgru/aurora"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"io"
+	"math"
+	"net"
+	"sync"
+)
+
+const BufferSize = 65535
+
+const maxDatagramSize = 65536
+
+// region Struct Definition
+
+// NetworkFrontend receives raw IQ samples sent to a UDP port or a TCP server socket, like the GNU Radio
+// network sinks. UDP datagrams may start with a sequence number used to count the lost datagrams.
+type NetworkFrontend struct {
+	sync.Mutex
+	running         bool
+	network         string
+	address         string
+	format          string
+	decoder         *Frontend.SampleDecoder
+	byteOrder       binary.ByteOrder
+	sequenceBytes   int
+	callback        Frontend.GoCallback
+	sampleRate      uint32
+	centerFrequency uint32
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	conn       net.Conn
+
+	hasSequence  bool
+	lastSequence uint64
+	lostPackets  int64
+}
+
+// endregion
+// region Constructor
+
+// NewNetworkFrontend creates a frontend listening at address on network udp or tcp, for samples in the
+// Frontend format with the byte order
+func NewNetworkFrontend(network, address, format string, byteOrder binary.ByteOrder) (*NetworkFrontend, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("invalid network %q, expected udp or tcp", network)
+	}
+
+	decoder, err := Frontend.MakeSampleDecoder(format, byteOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NetworkFrontend{
+		network:   network,
+		address:   address,
+		format:    format,
+		decoder:   decoder,
+		byteOrder: byteOrder,
+		callback:  Frontend.NewGoCallback(),
+	}, nil
+}
+
+// endregion
+// region Getters
+func (f *NetworkFrontend) GetName() string {
+	return fmt.Sprintf("NetworkFrontend (%s://%s %s)", f.network, f.address, f.format)
+}
+func (f *NetworkFrontend) GetShortName() string {
+	return "NetworkFrontend"
+}
+func (f *NetworkFrontend) GetAvailableSampleRates() []uint32 {
+	return make([]uint32, 0)
+}
+func (f *NetworkFrontend) GetCenterFrequency() uint32 {
+	return f.centerFrequency
+}
+func (f *NetworkFrontend) GetSampleRate() uint32 {
+	return f.sampleRate
+}
+
+// GetLostPackets returns the UDP datagrams missing from the sequence numbers
+func (f *NetworkFrontend) GetLostPackets() int64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.lostPackets
+}
+
+// endregion
+// region Setters
+func (f *NetworkFrontend) SetSamplesAvailableCallback(cb Frontend.SamplesCallback) {
+	f.callback.SetCallback(cb)
+}
+func (f *NetworkFrontend) SetSampleRate(sampleRate uint32) uint32 {
+	f.sampleRate = sampleRate
+	return sampleRate
+}
+func (f *NetworkFrontend) SetCenterFrequency(centerFrequency uint32) uint32 {
+	f.centerFrequency = centerFrequency
+	return centerFrequency
+}
+
+// SetSequenceBytes sets the size of the sequence number (in the frontend byte order) at the start of each
+// UDP datagram: 0 for none, 4 or 8
+func (f *NetworkFrontend) SetSequenceBytes(n int) error {
+	if n != 0 && n != 4 && n != 8 {
+		return fmt.Errorf("invalid sequence number size %d, expected 0, 4 or 8", n)
+	}
+	f.sequenceBytes = n
+	return nil
+}
+func (f *NetworkFrontend) SetAntenna(string) {}
+func (f *NetworkFrontend) SetAGC(bool)       {}
+func (f *NetworkFrontend) SetGain1(uint8)    {}
+func (f *NetworkFrontend) SetGain2(uint8)    {}
+func (f *NetworkFrontend) SetGain3(uint8)    {}
+func (f *NetworkFrontend) SetBiasT(bool)     {}
+
+// endregion
+// region Commands
+
+// Init opens the listening socket
+func (f *NetworkFrontend) Init() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.packetConn != nil || f.listener != nil {
+		return true
+	}
+
+	var err error
+	if f.network == "udp" {
+		f.packetConn, err = net.ListenPacket("udp", f.address)
+	} else {
+		f.listener, err = net.Listen("tcp", f.address)
+	}
+
+	if err != nil {
+		SLog.Error("Error listening at %s://%s: %s", f.network, Bold(f.address), Bold(err))
+		return false
+	}
+
+	SLog.Info("Waiting %s samples at %s://%s", Bold(f.format), f.network, Bold(f.address))
+
+	return true
+}
+func (f *NetworkFrontend) Destroy() {
+	f.Lock()
+	defer f.Unlock()
+	f.closeSockets()
+}
+func (f *NetworkFrontend) closeSockets() {
+	if f.packetConn != nil {
+		f.packetConn.Close()
+		f.packetConn = nil
+	}
+	if f.listener != nil {
+		f.listener.Close()
+		f.listener = nil
+	}
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
+func (f *NetworkFrontend) isRunning() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.running
+}
+func (f *NetworkFrontend) Start() {
+	if !f.Init() {
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if f.running {
+		SLog.Error("NetworkFrontend is already running.")
+		return
+	}
+
+	f.running = true
+
+	if f.packetConn != nil {
+		go f.udpLoop(f.packetConn)
+	} else {
+		go f.tcpLoop(f.listener)
+	}
+}
+
+// Stop stops receiving and closes the sockets, Start listens again
+func (f *NetworkFrontend) Stop() {
+	f.Lock()
+	defer f.Unlock()
+
+	if !f.running {
+		SLog.Error("NetworkFrontend is not running")
+		return
+	}
+
+	f.running = false
+	f.closeSockets()
+}
+
+func (f *NetworkFrontend) udpLoop(conn net.PacketConn) {
+	SLog.Info("NetworkFrontend UDP Routine started")
+	buffer := make([]byte, maxDatagramSize)
+
+	for f.isRunning() {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if f.isRunning() {
+				SLog.Error("Error reading UDP samples: %s", Bold(err))
+			}
+			break
+		}
+
+		payload := buffer[:n]
+		if f.sequenceBytes > 0 {
+			if n < f.sequenceBytes {
+				continue
+			}
+			f.checkSequence(payload[:f.sequenceBytes])
+			payload = payload[f.sequenceBytes:]
+		}
+
+		f.decoder.Decode(payload, &f.callback)
+	}
+
+	SLog.Error("NetworkFrontend UDP Routine ended")
+}
+
+// checkSequence counts the datagrams skipped by the sequence number. Sequence numbers going back are reported as
+// a restart of the sender.
+func (f *NetworkFrontend) checkSequence(header []byte) {
+	var seq, mask uint64
+	if len(header) == 4 {
+		seq = uint64(f.byteOrder.Uint32(header))
+		mask = math.MaxUint32
+	} else {
+		seq = f.byteOrder.Uint64(header)
+		mask = math.MaxUint64
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if f.hasSequence {
+		gap := (seq - f.lastSequence - 1) & mask
+		if gap > 0 && gap < mask/2 {
+			f.lostPackets += int64(gap)
+			SLog.Warn("Lost %d UDP packets (sequence %d to %d)", gap, f.lastSequence, seq)
+		} else if gap != 0 {
+			SLog.Warn("UDP sequence went back from %d to %d", f.lastSequence, seq)
+		}
+	}
+
+	f.hasSequence = true
+	f.lastSequence = seq
+}
+
+func (f *NetworkFrontend) tcpLoop(listener net.Listener) {
+	SLog.Info("NetworkFrontend TCP Routine started")
+	buffer := make([]byte, BufferSize*f.decoder.SampleSize())
+
+	for f.isRunning() {
+		conn, err := listener.Accept()
+		if err != nil {
+			if f.isRunning() {
+				SLog.Error("Error accepting TCP connection: %s", Bold(err))
+			}
+			break
+		}
+
+		SLog.Info("Receiving samples from %s", Bold(conn.RemoteAddr()))
+
+		f.Lock()
+		f.conn = conn
+		f.Unlock()
+
+		// The stream can split samples between reads, the partial sample is kept at the buffer start
+		pending := 0
+		for f.isRunning() {
+			n, err := conn.Read(buffer[pending:])
+			if n > 0 {
+				n += pending
+				used := f.decoder.Decode(buffer[:n], &f.callback)
+				pending = copy(buffer, buffer[used:n])
+			}
+
+			if err != nil {
+				if err != io.EOF && f.isRunning() {
+					SLog.Error("Error reading TCP samples: %s", Bold(err))
+				}
+				break
+			}
+		}
+
+		SLog.Info("Connection from %s closed", Bold(conn.RemoteAddr()))
+
+		f.Lock()
+		if f.conn == conn {
+			f.conn.Close()
+			f.conn = nil
+		}
+		f.Unlock()
+	}
+
+	SLog.Error("NetworkFrontend TCP Routine ended")
+}
+
+// endregion
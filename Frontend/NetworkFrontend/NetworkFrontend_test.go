@@ -0,0 +1,139 @@
+package NetworkFrontend
+
+import (
+	"encoding/binary"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+// collect returns a callback sending a copy of the complex samples of each call to the channel
+func collect() (Frontend.SamplesCallback, chan []complex64) {
+	received := make(chan []complex64, 16)
+	return func(data Frontend.SampleCallbackData) {
+		var buffer []complex64
+		received <- append([]complex64{}, data.ComplexSamples(&buffer)...)
+	}, received
+}
+
+func receive(t *testing.T, received chan []complex64) []complex64 {
+	select {
+	case s := <-received:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("no samples received")
+	}
+	return nil
+}
+
+func TestUDPSequence(t *testing.T) {
+	tests := []struct {
+		bytes     int
+		byteOrder binary.ByteOrder
+		sequences []uint64
+		lost      int64
+	}{
+		// 2 dropped
+		{4, binary.LittleEndian, []uint64{0, 1, 3}, 1},
+		// Wraps around, then 3 dropped
+		{4, binary.BigEndian, []uint64{math.MaxUint32 - 1, math.MaxUint32, 0, 4}, 3},
+		// 11 to 14 dropped
+		{8, binary.BigEndian, []uint64{10, 15, 16}, 4},
+		// The sender restarted
+		{8, binary.LittleEndian, []uint64{1 << 40, 1, 2}, 0},
+	}
+
+	for _, tt := range tests {
+		f, err := NewNetworkFrontend("udp", "127.0.0.1:0", Frontend.FormatCS8, tt.byteOrder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetSequenceBytes(tt.bytes); err != nil {
+			t.Fatal(err)
+		}
+
+		cb, received := collect()
+		f.SetSamplesAvailableCallback(cb)
+		f.Start()
+
+		conn, err := net.Dial("udp", f.packetConn.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, seq := range tt.sequences {
+			datagram := make([]byte, tt.bytes+4)
+			if tt.bytes == 4 {
+				tt.byteOrder.PutUint32(datagram, uint32(seq))
+			} else {
+				tt.byteOrder.PutUint64(datagram, seq)
+			}
+			// Two cs8 samples after the sequence number
+			copy(datagram[tt.bytes:], []byte{byte(i), 0x80, 64, byte(-64 & 0xFF)})
+
+			if _, err := conn.Write(datagram); err != nil {
+				t.Fatal(err)
+			}
+
+			samples := receive(t, received)
+			if len(samples) != 2 || samples[0] != complex(float32(i)/128, -1) || samples[1] != complex(0.5, -0.5) {
+				t.Errorf("%d bytes sequence: datagram %d samples %v", tt.bytes, i, samples)
+			}
+		}
+
+		if lost := f.GetLostPackets(); lost != tt.lost {
+			t.Errorf("%d bytes sequence %v: %d lost packets, expected %d", tt.bytes, tt.sequences, lost, tt.lost)
+		}
+
+		conn.Close()
+		f.Stop()
+	}
+}
+
+func TestTCP(t *testing.T) {
+	const numSamples = 1000
+
+	f, err := NewNetworkFrontend("tcp", "127.0.0.1:0", Frontend.FormatCS16, binary.BigEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb, received := collect()
+	f.SetSamplesAvailableCallback(cb)
+	f.Start()
+	defer f.Stop()
+
+	conn, err := net.Dial("tcp", f.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data := make([]byte, numSamples*4)
+	for i := 0; i < numSamples; i++ {
+		binary.BigEndian.PutUint16(data[i*4:], uint16(int16(i*16)))
+		binary.BigEndian.PutUint16(data[i*4+2:], uint16(int16(-i*16)))
+	}
+
+	// Writes that split the samples, the partial sample waits for the next read
+	for _, chunk := range [][]byte{data[:3], data[3:1001], data[1001:]} {
+		if _, err := conn.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	samples := make([]complex64, 0, numSamples)
+	for len(samples) < numSamples {
+		samples = append(samples, receive(t, received)...)
+	}
+
+	for i, s := range samples {
+		expected := complex(float32(i*16)/32768, float32(-i*16)/32768)
+		if s != expected {
+			t.Fatalf("sample %d is %v, expected %v", i, s, expected)
+		}
+	}
+}
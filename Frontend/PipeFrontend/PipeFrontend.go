@@ -0,0 +1,225 @@
+package PipeFrontend
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/OpenSatelliteProject/SatHelperApp/Logger"
+	. "github.com/logrusorgru/aurora"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const BufferSize = 65535
+
+// Stdin is the path that reads the standard input
+const Stdin = "-"
+
+// region Struct Definition
+
+// PipeFrontend reads raw IQ from the standard input or a named pipe as fast as the writer sends it, like
+// `rtl_sdr - | kissdvb -input -`. Done is closed at the end of the input.
+type PipeFrontend struct {
+	sync.Mutex
+	running         bool
+	path            string
+	format          string
+	decoder         *Frontend.SampleDecoder
+	callback        Frontend.GoCallback
+	sampleRate      uint32
+	centerFrequency uint32
+	reader          io.ReadCloser
+	samples         int64
+	done            chan struct{}
+}
+
+// endregion
+// region Constructor
+func NewPipeFrontend(path, format string, byteOrder binary.ByteOrder) (*PipeFrontend, error) {
+	decoder, err := Frontend.MakeSampleDecoder(format, byteOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PipeFrontend{
+		path:     path,
+		format:   format,
+		decoder:  decoder,
+		callback: Frontend.NewGoCallback(),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// IsPipe is true for the standard input and named pipes
+func IsPipe(path string) bool {
+	if path == Stdin {
+		return true
+	}
+
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+// endregion
+// region Getters
+func (f *PipeFrontend) GetName() string {
+	if f.path == Stdin {
+		return fmt.Sprintf("PipeFrontend (stdin %s)", f.format)
+	}
+	return fmt.Sprintf("PipeFrontend (%s %s)", f.path, f.format)
+}
+func (f *PipeFrontend) GetShortName() string {
+	return "PipeFrontend"
+}
+func (f *PipeFrontend) GetAvailableSampleRates() []uint32 {
+	return make([]uint32, 0)
+}
+func (f *PipeFrontend) GetCenterFrequency() uint32 {
+	return f.centerFrequency
+}
+func (f *PipeFrontend) GetSampleRate() uint32 {
+	return f.sampleRate
+}
+
+// GetSamples returns the number of samples read
+func (f *PipeFrontend) GetSamples() int64 {
+	return atomic.LoadInt64(&f.samples)
+}
+
+// Done is closed when the input ends or fails
+func (f *PipeFrontend) Done() <-chan struct{} {
+	return f.done
+}
+
+// endregion
+// region Setters
+func (f *PipeFrontend) SetSamplesAvailableCallback(cb Frontend.SamplesCallback) {
+	f.callback.SetCallback(func(data Frontend.SampleCallbackData) {
+		atomic.AddInt64(&f.samples, int64(data.NumSamples))
+		if cb != nil {
+			cb(data)
+		}
+	})
+}
+func (f *PipeFrontend) SetSampleRate(sampleRate uint32) uint32 {
+	f.sampleRate = sampleRate
+	return sampleRate
+}
+func (f *PipeFrontend) SetCenterFrequency(centerFrequency uint32) uint32 {
+	f.centerFrequency = centerFrequency
+	return centerFrequency
+}
+func (f *PipeFrontend) SetAntenna(string) {}
+func (f *PipeFrontend) SetAGC(bool)       {}
+func (f *PipeFrontend) SetGain1(uint8)    {}
+func (f *PipeFrontend) SetGain2(uint8)    {}
+func (f *PipeFrontend) SetGain3(uint8)    {}
+func (f *PipeFrontend) SetBiasT(bool)     {}
+
+// endregion
+// region Commands
+
+// Init opens the pipe. Opening a named pipe waits for the writer.
+func (f *PipeFrontend) Init() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.reader != nil {
+		return true
+	}
+
+	if f.path == Stdin {
+		f.reader = os.Stdin
+		return true
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		SLog.Error("Error opening pipe %s: %s", Bold(f.path), Bold(err))
+		return false
+	}
+	f.reader = file
+
+	return true
+}
+func (f *PipeFrontend) Destroy() {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.reader != nil && f.reader != os.Stdin {
+		f.reader.Close()
+	}
+	f.reader = nil
+}
+func (f *PipeFrontend) isRunning() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.running
+}
+func (f *PipeFrontend) Start() {
+	if !f.Init() {
+		close(f.done)
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if f.running {
+		SLog.Error("PipeFrontend is already running.")
+		return
+	}
+
+	f.running = true
+
+	go func(frontend *PipeFrontend, reader io.Reader) {
+		SLog.Info("PipeFrontend Routine started")
+		defer close(frontend.done)
+
+		buffer := make([]byte, BufferSize*frontend.decoder.SampleSize())
+		pending := 0
+
+		for frontend.isRunning() {
+			n, err := reader.Read(buffer[pending:])
+			if n > 0 {
+				n += pending
+				used := frontend.decoder.Decode(buffer[:n], &frontend.callback)
+				pending = copy(buffer, buffer[used:n])
+			}
+
+			if err == io.EOF {
+				SLog.Info("End of input after %d samples", frontend.GetSamples())
+				break
+			}
+
+			if err != nil {
+				if frontend.isRunning() {
+					SLog.Error("Error reading input pipe: %s", Bold(err))
+				}
+				break
+			}
+		}
+
+		frontend.Lock()
+		frontend.running = false
+		frontend.Unlock()
+		SLog.Error("PipeFrontend Routine ended")
+	}(f, f.reader)
+}
+
+// Stop stops reading. The input is not read again, Done is closed when the reading routine ends.
+func (f *PipeFrontend) Stop() {
+	f.Lock()
+	defer f.Unlock()
+
+	if !f.running {
+		SLog.Error("PipeFrontend is not running")
+		return
+	}
+
+	f.running = false
+}
+
+// endregion
@@ -0,0 +1,81 @@
+package PipeFrontend
+
+import (
+	"encoding/binary"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPipeFrontend(t *testing.T) {
+	const numSamples = 3*BufferSize + 7
+
+	f, err := NewPipeFrontend(Stdin, Frontend.FormatCS16, binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, writer := io.Pipe()
+	f.reader = reader
+
+	received := make([]int16, 0, numSamples*2)
+	f.SetSamplesAvailableCallback(func(data Frontend.SampleCallbackData) {
+		received = append(received, data.Int16Array...)
+	})
+	f.Start()
+
+	data := make([]byte, numSamples*4)
+	for i := 0; i < numSamples*2; i++ {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(int16(i)))
+	}
+
+	// Writes that split the samples
+	go func() {
+		for start := 0; start < len(data); start += 50001 {
+			end := start + 50001
+			if end > len(data) {
+				end = len(data)
+			}
+			if _, err := writer.Write(data[start:end]); err != nil {
+				return
+			}
+		}
+		writer.Close()
+	}()
+
+	select {
+	case <-f.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Done not closed at the end of the input")
+	}
+
+	if f.GetSamples() != numSamples {
+		t.Fatalf("%d samples read, expected %d", f.GetSamples(), numSamples)
+	}
+
+	for i, v := range received {
+		if v != int16(i) {
+			t.Fatalf("value %d is %d", i, v)
+		}
+	}
+
+	if f.isRunning() {
+		t.Error("still running after the end of the input")
+	}
+}
+
+func TestIsPipe(t *testing.T) {
+	f, err := ioutil.TempFile("", "pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if !IsPipe(Stdin) || IsPipe(f.Name()) || IsPipe(f.Name()+".missing") {
+		t.Error("wrong pipe detection")
+	}
+}
@@ -0,0 +1,278 @@
+package RtlTcpFrontend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/OpenSatelliteProject/SatHelperApp/Logger"
+	. "github.com/logrusorgru/aurora"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const BufferSize = 65535
+
+const dialTimeout = 5 * time.Second
+
+// rtl_tcp commands, one byte followed by the big endian uint32 parameter
+const (
+	cmdSetFrequency  = 0x01
+	cmdSetSampleRate = 0x02
+	cmdSetGainMode   = 0x03
+	cmdSetGain       = 0x04
+	cmdSetAGCMode    = 0x08
+)
+
+var tunerNames = []string{"Unknown", "E4000", "FC0012", "FC0013", "FC2580", "R820T", "R828D"}
+
+// region Struct Definition
+type RtlTcpFrontend struct {
+	sync.Mutex
+	running         bool
+	address         string
+	conn            net.Conn
+	decoder         *Frontend.SampleDecoder
+	callback        Frontend.GoCallback
+	sampleRate      uint32
+	centerFrequency uint32
+	gain            uint8
+	agc             bool
+	tunerType       uint32
+	gainCount       uint32
+	readBuffer      []byte
+}
+
+// endregion
+// region Constructor
+func NewRtlTcpFrontend(address string) *RtlTcpFrontend {
+	// rtl_tcp sends the unsigned 8 bit I and Q of the dongle
+	decoder, _ := Frontend.MakeSampleDecoder(Frontend.FormatCU8, binary.LittleEndian)
+
+	return &RtlTcpFrontend{
+		address:         address,
+		decoder:         decoder,
+		callback:        Frontend.NewGoCallback(),
+		running:         false,
+		sampleRate:      0,
+		centerFrequency: 0,
+	}
+}
+
+// endregion
+// region Getters
+func (f *RtlTcpFrontend) GetName() string {
+	return fmt.Sprintf("RtlTcpFrontend (%s)", f.address)
+}
+func (f *RtlTcpFrontend) GetShortName() string {
+	return "RtlTcpFrontend"
+}
+func (f *RtlTcpFrontend) GetAvailableSampleRates() []uint32 {
+	return []uint32{1024000, 1400000, 1800000, 1920000, 2048000, 2400000, 2560000, 2880000, 3200000}
+}
+func (f *RtlTcpFrontend) GetCenterFrequency() uint32 {
+	return f.centerFrequency
+}
+func (f *RtlTcpFrontend) GetSampleRate() uint32 {
+	return f.sampleRate
+}
+
+// GetTunerName returns the tuner reported by the server, valid after Init
+func (f *RtlTcpFrontend) GetTunerName() string {
+	if int(f.tunerType) < len(tunerNames) {
+		return tunerNames[f.tunerType]
+	}
+	return fmt.Sprintf("Tuner %d", f.tunerType)
+}
+
+// endregion
+// region Setters
+func (f *RtlTcpFrontend) SetSamplesAvailableCallback(cb Frontend.SamplesCallback) {
+	f.callback.SetCallback(cb)
+}
+func (f *RtlTcpFrontend) SetSampleRate(sampleRate uint32) uint32 {
+	f.Lock()
+	defer f.Unlock()
+	f.sampleRate = sampleRate
+	f.sendCommand(cmdSetSampleRate, sampleRate)
+	return sampleRate
+}
+func (f *RtlTcpFrontend) SetCenterFrequency(centerFrequency uint32) uint32 {
+	f.Lock()
+	defer f.Unlock()
+	f.centerFrequency = centerFrequency
+	f.sendCommand(cmdSetFrequency, centerFrequency)
+	return centerFrequency
+}
+
+// SetAGC enables the tuner automatic gain and the RTL2832 digital AGC, disabling it goes back to the manual gain
+func (f *RtlTcpFrontend) SetAGC(agc bool) {
+	f.Lock()
+	defer f.Unlock()
+	f.agc = agc
+	f.sendGain()
+}
+
+// SetGain1 sets the tuner gain in dB
+func (f *RtlTcpFrontend) SetGain1(value uint8) {
+	f.Lock()
+	defer f.Unlock()
+	f.gain = value
+	f.sendGain()
+}
+func (f *RtlTcpFrontend) SetAntenna(string) {}
+func (f *RtlTcpFrontend) SetGain2(uint8)    {}
+func (f *RtlTcpFrontend) SetGain3(uint8)    {}
+func (f *RtlTcpFrontend) SetBiasT(bool)     {}
+
+// endregion
+// region Commands
+
+// sendCommand sends a command when connected. The settings done before Init are sent by Init.
+func (f *RtlTcpFrontend) sendCommand(cmd byte, param uint32) {
+	if f.conn == nil {
+		return
+	}
+
+	var buf [5]byte
+	buf[0] = cmd
+	binary.BigEndian.PutUint32(buf[1:], param)
+
+	if _, err := f.conn.Write(buf[:]); err != nil {
+		SLog.Error("Error sending command %d to rtl_tcp: %s", cmd, Bold(err))
+	}
+}
+
+func (f *RtlTcpFrontend) sendGain() {
+	if f.agc {
+		f.sendCommand(cmdSetGainMode, 0)
+		f.sendCommand(cmdSetAGCMode, 1)
+		return
+	}
+
+	f.sendCommand(cmdSetAGCMode, 0)
+	f.sendCommand(cmdSetGainMode, 1)
+	f.sendCommand(cmdSetGain, uint32(f.gain)*10) // Tenths of dB
+}
+
+// Init connects to the server, reads the dongle info and sends the current settings
+func (f *RtlTcpFrontend) Init() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.conn != nil {
+		return true
+	}
+
+	conn, err := net.DialTimeout("tcp", f.address, dialTimeout)
+	if err != nil {
+		SLog.Error("Error connecting to rtl_tcp at %s: %s", Bold(f.address), Bold(err))
+		return false
+	}
+
+	// "RTL0", tuner type and number of gains
+	var header [12]byte
+	conn.SetReadDeadline(time.Now().Add(dialTimeout))
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		SLog.Error("Error reading rtl_tcp dongle info: %s", Bold(err))
+		conn.Close()
+		return false
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if string(header[:4]) != "RTL0" {
+		SLog.Error("Invalid rtl_tcp dongle info magic %q", string(header[:4]))
+		conn.Close()
+		return false
+	}
+
+	f.conn = conn
+	f.tunerType = binary.BigEndian.Uint32(header[4:])
+	f.gainCount = binary.BigEndian.Uint32(header[8:])
+	SLog.Info("Connected to rtl_tcp at %s, tuner %s with %d gains", Bold(f.address), Bold(f.GetTunerName()), f.gainCount)
+
+	if f.sampleRate != 0 {
+		f.sendCommand(cmdSetSampleRate, f.sampleRate)
+	}
+	if f.centerFrequency != 0 {
+		f.sendCommand(cmdSetFrequency, f.centerFrequency)
+	}
+	f.sendGain()
+
+	return true
+}
+func (f *RtlTcpFrontend) Destroy() {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
+func (f *RtlTcpFrontend) isRunning() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.running
+}
+func (f *RtlTcpFrontend) Start() {
+	if !f.Init() {
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if f.running {
+		SLog.Error("RtlTcpFrontend is already running.")
+		return
+	}
+
+	f.running = true
+	f.readBuffer = make([]byte, BufferSize*2)
+
+	go func(frontend *RtlTcpFrontend, conn net.Conn) {
+		SLog.Info("RtlTcpFrontend Routine started")
+		reader := bufio.NewReaderSize(conn, len(frontend.readBuffer))
+
+		for frontend.isRunning() {
+			if _, err := io.ReadFull(reader, frontend.readBuffer); err != nil {
+				if frontend.isRunning() {
+					SLog.Error("Error reading from rtl_tcp: %s", Bold(err))
+				}
+				frontend.Lock()
+				frontend.running = false
+				// Start connects again, unless Stop already closed this connection
+				if frontend.conn == conn {
+					conn.Close()
+					frontend.conn = nil
+				}
+				frontend.Unlock()
+				break
+			}
+
+			frontend.decoder.Decode(frontend.readBuffer, &frontend.callback)
+		}
+		SLog.Error("RtlTcpFrontend Routine ended")
+	}(f, f.conn)
+}
+
+// Stop stops reading and closes the connection, Start connects again
+func (f *RtlTcpFrontend) Stop() {
+	f.Lock()
+
+	if !f.running {
+		f.Unlock()
+		SLog.Error("RtlTcpFrontend is not running")
+		return
+	}
+
+	f.running = false
+	f.Unlock()
+
+	f.Destroy()
+}
+
+// endregion
@@ -0,0 +1,174 @@
+package RtlTcpFrontend
+
+import (
+	"encoding/binary"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+type command struct {
+	cmd   byte
+	param uint32
+}
+
+// fakeServer accepts one client, sends the dongle info, reads numCommands commands and then sends the samples and
+// closes the connection
+func fakeServer(t *testing.T, numCommands int, samples []byte) (string, <-chan command) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := make(chan command, numCommands)
+
+	go func() {
+		defer l.Close()
+		defer close(commands)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		header := []byte("RTL0\x00\x00\x00\x05\x00\x00\x00\x1d")
+		if _, err := conn.Write(header); err != nil {
+			return
+		}
+
+		var buf [5]byte
+		for i := 0; i < numCommands; i++ {
+			if _, err := io.ReadFull(conn, buf[:]); err != nil {
+				return
+			}
+			commands <- command{buf[0], binary.BigEndian.Uint32(buf[1:])}
+		}
+
+		_, _ = conn.Write(samples)
+	}()
+
+	return l.Addr().String(), commands
+}
+
+func TestRtlTcpFrontend(t *testing.T) {
+	expected := []command{
+		// Init sends the manual gain
+		{cmdSetAGCMode, 0}, {cmdSetGainMode, 1}, {cmdSetGain, 0},
+		{cmdSetFrequency, 1200000000},
+		{cmdSetSampleRate, 2048000},
+		{cmdSetAGCMode, 0}, {cmdSetGainMode, 1}, {cmdSetGain, 300},
+		{cmdSetGainMode, 0}, {cmdSetAGCMode, 1},
+	}
+
+	samples := make([]byte, BufferSize*2)
+	for i := range samples {
+		samples[i] = byte(i)
+	}
+
+	address, commands := fakeServer(t, len(expected), samples)
+
+	f := NewRtlTcpFrontend(address)
+	if !f.Init() {
+		t.Fatal("Init failed")
+	}
+
+	if f.GetTunerName() != "R820T" || f.gainCount != 29 {
+		t.Errorf("dongle info: tuner %s with %d gains", f.GetTunerName(), f.gainCount)
+	}
+
+	f.SetCenterFrequency(1200000000)
+	f.SetSampleRate(2048000)
+	f.SetGain1(30)
+	f.SetAGC(true)
+
+	for i, e := range expected {
+		select {
+		case c, ok := <-commands:
+			if !ok {
+				t.Fatalf("command %d not received", i)
+			}
+			if c != e {
+				t.Errorf("command %d is %d %d, expected %d %d", i, c.cmd, c.param, e.cmd, e.param)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("command %d not received", i)
+		}
+	}
+
+	received := make(chan Frontend.SampleCallbackData, 1)
+	f.SetSamplesAvailableCallback(func(data Frontend.SampleCallbackData) {
+		data.Int8Array = append([]int8{}, data.Int8Array...)
+		received <- data
+	})
+	f.Start()
+
+	var data Frontend.SampleCallbackData
+	select {
+	case data = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no samples received")
+	}
+
+	if data.SampleType != Frontend.SampleTypeS8IQ || data.NumSamples != BufferSize {
+		t.Fatalf("%d samples of type %d, expected %d cu8 samples", data.NumSamples, data.SampleType, BufferSize)
+	}
+
+	// The same conversion as a cu8 file: the bytes centered at 128 scaled to [-1, 1)
+	var buffer []complex64
+	iq := data.ComplexSamples(&buffer)
+	for i, s := range iq {
+		re := float32(int(samples[i*2])-128) / 128
+		im := float32(int(samples[i*2+1])-128) / 128
+		if s != complex(re, im) {
+			t.Fatalf("sample %d is %v, expected %v", i, s, complex(re, im))
+		}
+	}
+
+	if iq[0] != complex(-1, float32(-127)/128) || iq[64] != complex(0, float32(1)/128) || imag(iq[127]) != float32(127)/128 {
+		t.Errorf("u8 conversion range %v %v %v", iq[0], iq[64], iq[127])
+	}
+
+	// The server closed the connection, the reader drops it so Start can connect again
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		f.Lock()
+		closed := f.conn == nil && !f.running
+		f.Unlock()
+
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("connection kept after the read error")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRtlTcpFrontendInvalidHeader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200"))
+		conn.Close()
+	}()
+
+	f := NewRtlTcpFrontend(l.Addr().String())
+	if f.Init() {
+		t.Fatal("invalid dongle info accepted")
+	}
+	if f.conn != nil {
+		t.Fatal("connection kept after an invalid dongle info")
+	}
+}
@@ -0,0 +1,155 @@
+package Frontend
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"unsafe"
+)
+
+// IQ sample formats of the raw inputs
+const (
+	FormatCF32 = "cf32" // complex float32
+	FormatCS16 = "cs16" // complex int16
+	FormatCS8  = "cs8"  // complex int8
+	FormatCU8  = "cu8"  // complex uint8 centered at 128, like rtl_sdr
+)
+
+// Maximum samples of one Cb call
+const maxCallbackSamples = 65535
+
+// ParseSampleFormat checks the format name and returns the size in bytes of one IQ sample
+func ParseSampleFormat(format string) (int, error) {
+	switch format {
+	case FormatCF32:
+		return 8, nil
+	case FormatCS16:
+		return 4, nil
+	case FormatCS8, FormatCU8:
+		return 2, nil
+	}
+
+	return 0, fmt.Errorf("invalid sample format %q, expected %s, %s, %s or %s", format, FormatCF32, FormatCS16, FormatCS8, FormatCU8)
+}
+
+// ParseByteOrder returns the byte order named little or big
+func ParseByteOrder(name string) (binary.ByteOrder, error) {
+	switch strings.ToLower(name) {
+	case "little", "le":
+		return binary.LittleEndian, nil
+	case "big", "be":
+		return binary.BigEndian, nil
+	}
+
+	return nil, fmt.Errorf("invalid byte order %q, expected little or big", name)
+}
+
+// ComplexSamples returns the samples as complex64. The integer types are scaled to [-1, 1) into *buffer,
+// which is grown when needed so it can be reused by the next call.
+func (d SampleCallbackData) ComplexSamples(buffer *[]complex64) []complex64 {
+	if d.SampleType == SampleTypeFloatIQ {
+		return d.ComplexArray
+	}
+
+	if cap(*buffer) < d.NumSamples {
+		*buffer = make([]complex64, d.NumSamples)
+	}
+	out := (*buffer)[:d.NumSamples]
+
+	switch d.SampleType {
+	case SampleTypeS16IQ:
+		for i := range out {
+			out[i] = complex(float32(d.Int16Array[i*2])/32768, float32(d.Int16Array[i*2+1])/32768)
+		}
+	case SampleTypeS8IQ:
+		for i := range out {
+			out[i] = complex(float32(d.Int8Array[i*2])/128, float32(d.Int8Array[i*2+1])/128)
+		}
+	}
+
+	return out
+}
+
+// SampleDecoder converts raw IQ bytes to the native types and calls the Cb function of the format.
+// cu8 is delivered as S8IQ.
+type SampleDecoder struct {
+	format     string
+	sampleSize int
+	byteOrder  binary.ByteOrder
+
+	floatBuffer []complex64
+	int16Buffer []int16
+	int8Buffer  []int8
+}
+
+func MakeSampleDecoder(format string, byteOrder binary.ByteOrder) (*SampleDecoder, error) {
+	sampleSize, err := ParseSampleFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &SampleDecoder{
+		format:     format,
+		sampleSize: sampleSize,
+		byteOrder:  byteOrder,
+	}
+
+	switch format {
+	case FormatCF32:
+		d.floatBuffer = make([]complex64, maxCallbackSamples)
+	case FormatCS16:
+		d.int16Buffer = make([]int16, maxCallbackSamples*2)
+	default:
+		d.int8Buffer = make([]int8, maxCallbackSamples*2)
+	}
+
+	return d, nil
+}
+
+// SampleSize returns the bytes of one IQ sample
+func (d *SampleDecoder) SampleSize() int {
+	return d.sampleSize
+}
+
+// Decode delivers the whole samples of data to cb and returns how many bytes were used
+func (d *SampleDecoder) Decode(data []byte, cb *GoCallback) int {
+	used := 0
+
+	for len(data) >= d.sampleSize {
+		n := len(data) / d.sampleSize
+		if n > maxCallbackSamples {
+			n = maxCallbackSamples
+		}
+
+		switch d.format {
+		case FormatCF32:
+			for i := 0; i < n; i++ {
+				re := math.Float32frombits(d.byteOrder.Uint32(data[i*8:]))
+				im := math.Float32frombits(d.byteOrder.Uint32(data[i*8+4:]))
+				d.floatBuffer[i] = complex(re, im)
+			}
+			cb.CbFloatIQ(uintptr(unsafe.Pointer(&d.floatBuffer[0])), n)
+		case FormatCS16:
+			for i := 0; i < n*2; i++ {
+				d.int16Buffer[i] = int16(d.byteOrder.Uint16(data[i*2:]))
+			}
+			cb.CbS16IQ(uintptr(unsafe.Pointer(&d.int16Buffer[0])), n)
+		case FormatCS8:
+			for i := 0; i < n*2; i++ {
+				d.int8Buffer[i] = int8(data[i])
+			}
+			cb.CbS8IQ(uintptr(unsafe.Pointer(&d.int8Buffer[0])), n)
+		case FormatCU8:
+			for i := 0; i < n*2; i++ {
+				d.int8Buffer[i] = int8(data[i] ^ 0x80)
+			}
+			cb.CbS8IQ(uintptr(unsafe.Pointer(&d.int8Buffer[0])), n)
+		}
+
+		data = data[n*d.sampleSize:]
+		used += n * d.sampleSize
+	}
+
+	return used
+}
@@ -0,0 +1,156 @@
+package Frontend
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// decodeAll decodes data and returns the samples of all the callbacks and the bytes used
+func decodeAll(d *SampleDecoder, data []byte) ([]complex64, []int, int) {
+	samples := make([]complex64, 0)
+	types := make([]int, 0)
+
+	cb := NewGoCallback()
+	cb.SetCallback(func(data SampleCallbackData) {
+		var buffer []complex64
+		samples = append(samples, data.ComplexSamples(&buffer)...)
+		types = append(types, data.SampleType)
+	})
+
+	return samples, types, d.Decode(data, &cb)
+}
+
+func float32Bytes(byteOrder binary.ByteOrder, values ...float32) []byte {
+	b := make([]byte, len(values)*4)
+	for i, v := range values {
+		byteOrder.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+	return b
+}
+
+func int16Bytes(byteOrder binary.ByteOrder, values ...int16) []byte {
+	b := make([]byte, len(values)*2)
+	for i, v := range values {
+		byteOrder.PutUint16(b[i*2:], uint16(v))
+	}
+	return b
+}
+
+func TestSampleDecoder(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		byteOrder  binary.ByteOrder
+		data       []byte
+		expected   []complex64
+		used       int
+		sampleType int
+	}{
+		{"cf32 little", FormatCF32, binary.LittleEndian, float32Bytes(binary.LittleEndian, 0.5, -0.25, -1, 2),
+			[]complex64{complex(0.5, -0.25), complex(-1, 2)}, 16, SampleTypeFloatIQ},
+		{"cf32 big", FormatCF32, binary.BigEndian, float32Bytes(binary.BigEndian, 0.5, -0.25),
+			[]complex64{complex(0.5, -0.25)}, 8, SampleTypeFloatIQ},
+		{"cs16 little", FormatCS16, binary.LittleEndian, int16Bytes(binary.LittleEndian, 16384, -32768, 32767, -1),
+			[]complex64{complex(0.5, -1), complex(float32(32767)/32768, float32(-1)/32768)}, 8, SampleTypeS16IQ},
+		{"cs16 big", FormatCS16, binary.BigEndian, int16Bytes(binary.BigEndian, -16384, 1),
+			[]complex64{complex(-0.5, float32(1)/32768)}, 4, SampleTypeS16IQ},
+		{"cs8", FormatCS8, binary.LittleEndian, []byte{0x40, 0x80, 0x7F, 0xFF},
+			[]complex64{complex(0.5, -1), complex(float32(127)/128, float32(-1)/128)}, 4, SampleTypeS8IQ},
+		// Unsigned centered at 128: the sign bit flip gives the cs8 value
+		{"cu8", FormatCU8, binary.LittleEndian, []byte{0x80, 0x00, 0xFF, 0xC0},
+			[]complex64{complex(0, -1), complex(float32(127)/128, 0.5)}, 4, SampleTypeS8IQ},
+		// The partial sample at the end is left for the next call
+		{"cf32 partial", FormatCF32, binary.LittleEndian, append(float32Bytes(binary.LittleEndian, 1, 1), 0, 0, 0x80),
+			[]complex64{complex(1, 1)}, 8, SampleTypeFloatIQ},
+		{"cs16 partial", FormatCS16, binary.LittleEndian, append(int16Bytes(binary.LittleEndian, 256, 512), 1, 2, 3),
+			[]complex64{complex(float32(256)/32768, float32(512)/32768)}, 4, SampleTypeS16IQ},
+		{"cu8 odd", FormatCU8, binary.LittleEndian, []byte{0x90, 0x70, 0x12},
+			[]complex64{complex(float32(16)/128, float32(-16)/128)}, 2, SampleTypeS8IQ},
+		{"cs8 less than a sample", FormatCS8, binary.LittleEndian, []byte{0x12}, []complex64{}, 0, SampleTypeS8IQ},
+	}
+
+	for _, tt := range tests {
+		d, err := MakeSampleDecoder(tt.format, tt.byteOrder)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples, types, used := decodeAll(d, tt.data)
+		if used != tt.used {
+			t.Errorf("%s: %d bytes used, expected %d", tt.name, used, tt.used)
+		}
+
+		if len(samples) != len(tt.expected) {
+			t.Errorf("%s: %d samples, expected %d", tt.name, len(samples), len(tt.expected))
+			continue
+		}
+
+		for i, s := range samples {
+			if s != tt.expected[i] {
+				t.Errorf("%s: sample %d is %v, expected %v", tt.name, i, s, tt.expected[i])
+			}
+		}
+
+		for _, st := range types {
+			if st != tt.sampleType {
+				t.Errorf("%s: callback of type %d, expected %d", tt.name, st, tt.sampleType)
+			}
+		}
+	}
+}
+
+func TestSampleDecoderLargeBuffer(t *testing.T) {
+	const numSamples = 2*maxCallbackSamples + 10
+
+	d, _ := MakeSampleDecoder(FormatCU8, binary.LittleEndian)
+	data := make([]byte, numSamples*2)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	calls := 0
+	total := 0
+	cb := NewGoCallback()
+	cb.SetCallback(func(data SampleCallbackData) {
+		if data.NumSamples > maxCallbackSamples {
+			t.Errorf("callback with %d samples", data.NumSamples)
+		}
+		for i, v := range data.Int8Array {
+			if expected := int8(byte(total*2+i) ^ 0x80); v != expected {
+				t.Fatalf("byte %d is %d, expected %d", total*2+i, v, expected)
+			}
+		}
+		calls++
+		total += data.NumSamples
+	})
+
+	if used := d.Decode(data, &cb); used != len(data) || total != numSamples || calls != 3 {
+		t.Errorf("%d bytes used and %d samples in %d callbacks", used, total, calls)
+	}
+}
+
+func TestParseSampleFormat(t *testing.T) {
+	sizes := map[string]int{FormatCF32: 8, FormatCS16: 4, FormatCS8: 2, FormatCU8: 2}
+	for format, size := range sizes {
+		if n, err := ParseSampleFormat(format); err != nil || n != size {
+			t.Errorf("%s: size %d, error %v", format, n, err)
+		}
+	}
+
+	if _, err := ParseSampleFormat("cu16"); err == nil {
+		t.Error("invalid format accepted")
+	}
+	if _, err := MakeSampleDecoder("f32", binary.LittleEndian); err == nil {
+		t.Error("decoder of an invalid format created")
+	}
+
+	for name, order := range map[string]binary.ByteOrder{"little": binary.LittleEndian, "BE": binary.BigEndian} {
+		if o, err := ParseByteOrder(name); err != nil || o != order {
+			t.Errorf("byte order %s is %v, error %v", name, o, err)
+		}
+	}
+	if _, err := ParseByteOrder("middle"); err == nil {
+		t.Error("invalid byte order accepted")
+	}
+}
@@ -0,0 +1,277 @@
+package Frontend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/OpenSatelliteProject/SatHelperApp/Logger"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SigMFMetaExtension    = ".sigmf-meta"
+	SigMFDataExtension    = ".sigmf-data"
+	SigMFArchiveExtension = ".sigmf"
+	sigMFVersion          = "1.0.0"
+)
+
+// SigMFMeta is the part of the SigMF metadata (https://github.com/sigmf/SigMF) used by kissdvb
+type SigMFMeta struct {
+	Global      SigMFGlobal       `json:"global"`
+	Captures    []SigMFCapture    `json:"captures"`
+	Annotations []SigMFAnnotation `json:"annotations"`
+}
+
+type SigMFGlobal struct {
+	Datatype    string  `json:"core:datatype"`
+	SampleRate  float64 `json:"core:sample_rate,omitempty"`
+	Version     string  `json:"core:version"`
+	Description string  `json:"core:description,omitempty"`
+	Recorder    string  `json:"core:recorder,omitempty"`
+}
+
+type SigMFCapture struct {
+	SampleStart uint64  `json:"core:sample_start"`
+	Frequency   float64 `json:"core:frequency,omitempty"`
+	Datetime    string  `json:"core:datetime,omitempty"`
+}
+
+type SigMFAnnotation struct {
+	SampleStart uint64 `json:"core:sample_start"`
+	SampleCount uint64 `json:"core:sample_count,omitempty"`
+	Label       string `json:"core:label,omitempty"`
+	Comment     string `json:"core:comment,omitempty"`
+}
+
+// IsSigMF is true for the paths of a SigMF recording: name.sigmf-meta, name.sigmf-data or the name.sigmf archive
+func IsSigMF(path string) bool {
+	return strings.HasSuffix(path, SigMFMetaExtension) || strings.HasSuffix(path, SigMFDataExtension) ||
+		strings.HasSuffix(path, SigMFArchiveExtension)
+}
+
+// SigMFPaths returns the metadata and data files of the recording at path. Archives have to be extracted first.
+func SigMFPaths(path string) (meta, data string, err error) {
+	if strings.HasSuffix(path, SigMFArchiveExtension) {
+		return "", "", fmt.Errorf("%s is a SigMF archive, extract it with tar -xf and give its %s file", path, SigMFMetaExtension)
+	}
+
+	base := strings.TrimSuffix(strings.TrimSuffix(path, SigMFMetaExtension), SigMFDataExtension)
+	return base + SigMFMetaExtension, base + SigMFDataExtension, nil
+}
+
+func ReadSigMFMeta(path string) (*SigMFMeta, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	meta := &SigMFMeta{}
+	if err := json.NewDecoder(f).Decode(meta); err != nil {
+		return nil, fmt.Errorf("invalid SigMF metadata %s: %s", path, err)
+	}
+
+	return meta, nil
+}
+
+// ParseSigMFDatatype returns the sample format and byte order of a SigMF core:datatype
+func ParseSigMFDatatype(datatype string) (string, binary.ByteOrder, error) {
+	switch datatype {
+	case "cf32_le":
+		return FormatCF32, binary.LittleEndian, nil
+	case "cf32_be":
+		return FormatCF32, binary.BigEndian, nil
+	case "ci16_le":
+		return FormatCS16, binary.LittleEndian, nil
+	case "ci16_be":
+		return FormatCS16, binary.BigEndian, nil
+	case "ci8":
+		return FormatCS8, binary.LittleEndian, nil
+	case "cu8":
+		return FormatCU8, binary.LittleEndian, nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported SigMF datatype %q, expected cf32_le, cf32_be, ci16_le, ci16_be, ci8 or cu8", datatype)
+}
+
+// CenterFrequency returns the frequency of the first capture
+func (m *SigMFMeta) CenterFrequency() float64 {
+	if len(m.Captures) == 0 {
+		return 0
+	}
+	return m.Captures[0].Frequency
+}
+
+// SigMFRecorder writes the samples of a SamplesCallback to a SigMF recording in their native type. The annotations
+// are placed at the number of samples recorded when Annotate is called, or at the sample given to AnnotateAt. The
+// metadata is written by Close.
+type SigMFRecorder struct {
+	sync.Mutex
+	metaPath   string
+	file       *os.File
+	writer     *bufio.Writer
+	meta       SigMFMeta
+	sampleType int
+	samples    uint64
+	byteBuffer []byte
+	closed     bool
+}
+
+// MakeSigMFRecorder creates name.sigmf-data, name.sigmf-meta is written by Close
+func MakeSigMFRecorder(name string, sampleRate, centerFrequency float64) (*SigMFRecorder, error) {
+	metaPath, dataPath, err := SigMFPaths(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	SLog.Info("Recording SigMF to %s", dataPath)
+
+	return &SigMFRecorder{
+		metaPath:   metaPath,
+		file:       f,
+		writer:     bufio.NewWriter(f),
+		sampleType: -1,
+		meta: SigMFMeta{
+			Global: SigMFGlobal{
+				SampleRate: sampleRate,
+				Version:    sigMFVersion,
+				Recorder:   "kissdvb",
+			},
+			Captures: []SigMFCapture{{
+				Frequency: centerFrequency,
+				Datetime:  time.Now().UTC().Format(time.RFC3339),
+			}},
+			Annotations: make([]SigMFAnnotation, 0),
+		},
+	}, nil
+}
+
+// SamplesCallback can be used as the Frontend callback
+func (r *SigMFRecorder) SamplesCallback(data SampleCallbackData) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	if r.sampleType == -1 {
+		r.sampleType = data.SampleType
+	} else if r.sampleType != data.SampleType {
+		SLog.Error("SigMF recording got sample type %d after %d, samples dropped", data.SampleType, r.sampleType)
+		return
+	}
+
+	var n int
+	switch data.SampleType {
+	case SampleTypeFloatIQ:
+		n = r.grow(data.NumSamples * 8)
+		for i, s := range data.ComplexArray[:data.NumSamples] {
+			binary.LittleEndian.PutUint32(r.byteBuffer[i*8:], math.Float32bits(real(s)))
+			binary.LittleEndian.PutUint32(r.byteBuffer[i*8+4:], math.Float32bits(imag(s)))
+		}
+	case SampleTypeS16IQ:
+		n = r.grow(data.NumSamples * 4)
+		for i, v := range data.Int16Array[:data.NumSamples*2] {
+			binary.LittleEndian.PutUint16(r.byteBuffer[i*2:], uint16(v))
+		}
+	case SampleTypeS8IQ:
+		n = r.grow(data.NumSamples * 2)
+		for i, v := range data.Int8Array[:data.NumSamples*2] {
+			r.byteBuffer[i] = byte(v)
+		}
+	}
+
+	if _, err := r.writer.Write(r.byteBuffer[:n]); err != nil {
+		SLog.Error("Error writing SigMF data: %s", err)
+	}
+	r.samples += uint64(data.NumSamples)
+}
+
+func (r *SigMFRecorder) grow(size int) int {
+	if len(r.byteBuffer) < size {
+		r.byteBuffer = make([]byte, size)
+	}
+	return size
+}
+
+// Annotate adds an annotation at the current sample
+func (r *SigMFRecorder) Annotate(label, comment string) {
+	r.AnnotateAt(r.GetSamples(), label, comment)
+}
+
+// AnnotateAt adds an annotation at a sample of the recording, like the one where a decoded event was received
+func (r *SigMFRecorder) AnnotateAt(sample uint64, label, comment string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.meta.Annotations = append(r.meta.Annotations, SigMFAnnotation{
+		SampleStart: sample,
+		Label:       label,
+		Comment:     comment,
+	})
+}
+
+// GetSamples returns the number of samples recorded
+func (r *SigMFRecorder) GetSamples() uint64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.samples
+}
+
+// Close finishes the data file and writes the metadata. A nil recorder does nothing.
+func (r *SigMFRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	err := r.writer.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+
+	switch r.sampleType {
+	case SampleTypeS16IQ:
+		r.meta.Global.Datatype = "ci16_le"
+	case SampleTypeS8IQ:
+		r.meta.Global.Datatype = "ci8"
+	default:
+		r.meta.Global.Datatype = "cf32_le"
+	}
+
+	sort.SliceStable(r.meta.Annotations, func(i, j int) bool {
+		return r.meta.Annotations[i].SampleStart < r.meta.Annotations[j].SampleStart
+	})
+
+	data, jerr := json.MarshalIndent(r.meta, "", "  ")
+	if jerr == nil {
+		jerr = ioutil.WriteFile(r.metaPath, data, 0644)
+	}
+	if err == nil {
+		err = jerr
+	}
+
+	SLog.Info("SigMF recording of %d samples saved to %s", r.samples, r.metaPath)
+
+	return err
+}
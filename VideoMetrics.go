@@ -0,0 +1,29 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	metricVideoFrames = prometheus.NewDesc("kissdvb_video_frames_total", "Decoded video frames", nil, nil)
+	metricAudioFrames = prometheus.NewDesc("kissdvb_audio_frames_total", "Played audio frames", nil, nil)
+	metricVideoFifo   = prometheus.NewDesc("kissdvb_video_fifo_bytes", "Transport stream bytes waiting for the demuxer", nil, nil)
+)
+
+type videoCollector struct {
+	vp *VideoPlayer
+}
+
+func (c videoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricVideoFrames
+	ch <- metricAudioFrames
+	ch <- metricVideoFifo
+}
+
+func (c videoCollector) Collect(ch chan<- prometheus.Metric) {
+	video, audio := c.vp.GetDecodedFrames()
+	ch <- prometheus.MustNewConstMetric(metricVideoFrames, prometheus.CounterValue, float64(video))
+	ch <- prometheus.MustNewConstMetric(metricAudioFrames, prometheus.CounterValue, float64(audio))
+	ch <- prometheus.MustNewConstMetric(metricVideoFifo, prometheus.GaugeValue, float64(c.vp.GetBufferedBytes()))
+}
@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -8,6 +11,7 @@ import (
 	"github.com/racerxdl/kissdvb/h264"
 	"image"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,6 +46,9 @@ type VideoPlayer struct {
 
 	width  int
 	height int
+
+	videoFrames int64
+	audioFrames int64
 }
 
 func MakeVideoPlayer() *VideoPlayer {
@@ -99,6 +106,7 @@ func (vp *VideoPlayer) processAudio(out []float32) {
 	af := vp.frameParser.NextAudioFrame()
 	if af != nil {
 		copy(out, af.Samples)
+		atomic.AddInt64(&vp.audioFrames, 1)
 	}
 }
 
@@ -148,6 +156,7 @@ func (vp *VideoPlayer) putVideoData(data []byte) {
 	vf := vp.frameParser.NextFrame()
 
 	if vf != nil {
+		atomic.AddInt64(&vp.videoFrames, 1)
 		sleepTime := vf.PTS - vp.currFrameTime
 
 		if sleepTime > 0 {
@@ -173,6 +182,16 @@ func (vp *VideoPlayer) putVideoData(data []byte) {
 	}
 }
 
+// GetDecodedFrames returns how many video and audio frames were decoded
+func (vp *VideoPlayer) GetDecodedFrames() (video, audio int64) {
+	return atomic.LoadInt64(&vp.videoFrames), atomic.LoadInt64(&vp.audioFrames)
+}
+
+// GetBufferedBytes returns the transport stream bytes waiting for the demuxer
+func (vp *VideoPlayer) GetBufferedBytes() int {
+	return vp.fifoReader.Len()
+}
+
 func (vp *VideoPlayer) GetFrame() *image.RGBA {
 	vp.frameSync.Lock()
 	defer vp.frameSync.Unlock()
@@ -0,0 +1,81 @@
+package channelsim
+
+import (
+	"flag"
+	"fmt"
+	"math"
+)
+
+type Config struct {
+	SampleRate float64 `json:"sampleRate"`
+	SymbolRate float64 `json:"symbolRate"`
+
+	EsN0 float64 `json:"esN0"` // dB, +Inf disables the noise
+
+	FrequencyOffset float64 `json:"frequencyOffset"` // Hz
+	FrequencyDrift  float64 `json:"frequencyDrift"`  // Hz/s
+	PhaseNoise      float64 `json:"phaseNoise"`      // Oscillator linewidth in Hz
+
+	TimingOffset float64 `json:"timingOffset"` // Symbols
+	ClockDrift   float64 `json:"clockDrift"`   // Sample clock error in ppm
+
+	IQGain  float64 `json:"iqGain"`  // Q / I amplitude imbalance in dB
+	IQPhase float64 `json:"iqPhase"` // Degrees
+	DCI     float64 `json:"dcI"`
+	DCQ     float64 `json:"dcQ"`
+
+	Seed int64 `json:"seed"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SampleRate: 2e6,
+		SymbolRate: 1e6,
+		EsN0:       math.Inf(1),
+		Seed:       1,
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.SampleRate, "samplerate", c.SampleRate, "Sample rate in Hz")
+	fs.Float64Var(&c.SymbolRate, "symbolrate", c.SymbolRate, "Symbol rate in Hz, used for the Es/N0 and timing offset")
+	fs.Float64Var(&c.EsN0, "esn0", c.EsN0, "Es/N0 of the added white gaussian noise in dB (+Inf for no noise)")
+	fs.Float64Var(&c.FrequencyOffset, "freq-offset", c.FrequencyOffset, "Carrier frequency offset in Hz")
+	fs.Float64Var(&c.FrequencyDrift, "freq-drift", c.FrequencyDrift, "Carrier frequency drift in Hz/s")
+	fs.Float64Var(&c.PhaseNoise, "phase-noise", c.PhaseNoise, "Phase noise as the oscillator linewidth in Hz")
+	fs.Float64Var(&c.TimingOffset, "timing-offset", c.TimingOffset, "Symbol timing offset in symbols")
+	fs.Float64Var(&c.ClockDrift, "clock-drift", c.ClockDrift, "Symbol clock error in ppm")
+	fs.Float64Var(&c.IQGain, "iq-gain", c.IQGain, "IQ amplitude imbalance in dB")
+	fs.Float64Var(&c.IQPhase, "iq-phase", c.IQPhase, "IQ phase imbalance in degrees")
+	fs.Float64Var(&c.DCI, "dc-i", c.DCI, "DC offset of I")
+	fs.Float64Var(&c.DCQ, "dc-q", c.DCQ, "DC offset of Q")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "Random seed for the noise")
+}
+
+func (c *Config) Validate() error {
+	if c.SampleRate <= 0 || c.SymbolRate <= 0 {
+		return fmt.Errorf("invalid sample rate %f or symbol rate %f", c.SampleRate, c.SymbolRate)
+	}
+
+	if math.IsNaN(c.EsN0) {
+		return fmt.Errorf("invalid Es/N0")
+	}
+
+	if c.PhaseNoise < 0 {
+		return fmt.Errorf("invalid phase noise linewidth %f", c.PhaseNoise)
+	}
+
+	if c.TimingOffset < 0 {
+		return fmt.Errorf("timing offset should be positive, got %f", c.TimingOffset)
+	}
+
+	if math.Abs(c.ClockDrift) >= 1e5 {
+		return fmt.Errorf("invalid clock drift %f ppm", c.ClockDrift)
+	}
+
+	if math.Abs(c.IQPhase) >= 90 {
+		return fmt.Errorf("invalid IQ phase imbalance %f", c.IQPhase)
+	}
+
+	return nil
+}
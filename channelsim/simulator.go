@@ -0,0 +1,145 @@
+package channelsim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Simulator adds channel and receiver impairments to a baseband, in this order:
+// symbol timing offset and drift, AWGN, carrier frequency offset and drift, phase noise, IQ imbalance and DC offset.
+type Simulator struct {
+	cfg Config
+	rng *rand.Rand
+
+	// Resampler
+	step    float64 // Input samples per output sample
+	pos     float64 // Next output position relative to the first sample of pending
+	pending []complex64
+
+	// Noise
+	noise       bool
+	noiseFactor float64 // Noise variance = signal power * noiseFactor
+	powerSum    float64
+	powerCount  int64
+
+	// Carrier
+	phase      float64
+	phaseStep  float64
+	phaseDrift float64 // Increment of phaseStep per sample
+	phaseNoise float64 // Standard deviation of the phase random walk per sample
+
+	// IQ imbalance: Q' = gain * (Q cos(phi) + I sin(phi))
+	iqGain float64
+	iqSin  float64
+	iqCos  float64
+
+	out []complex64
+}
+
+func MakeSimulator(cfg Config) (*Simulator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	sps := cfg.SampleRate / cfg.SymbolRate
+	iqPhase := cfg.IQPhase * math.Pi / 180
+	s := &Simulator{
+		cfg:  cfg,
+		rng:  rand.New(rand.NewSource(cfg.Seed)),
+		step: 1 + cfg.ClockDrift*1e-6,
+		// The input is sampled TimingOffset symbols later, with one sample of history for the interpolator
+		pos:        1 + cfg.TimingOffset*sps,
+		pending:    make([]complex64, 1),
+		noise:      !math.IsInf(cfg.EsN0, 1),
+		phaseStep:  2 * math.Pi * cfg.FrequencyOffset / cfg.SampleRate,
+		phaseDrift: 2 * math.Pi * cfg.FrequencyDrift / (cfg.SampleRate * cfg.SampleRate),
+		phaseNoise: math.Sqrt(2 * math.Pi * cfg.PhaseNoise / cfg.SampleRate),
+		iqGain:     math.Pow(10, cfg.IQGain/20),
+		iqSin:      math.Sin(iqPhase),
+		iqCos:      math.Cos(iqPhase),
+	}
+
+	// Es = P * sps / SampleRate and N0 = noise variance / SampleRate
+	s.noiseFactor = sps / math.Pow(10, cfg.EsN0/10)
+
+	return s, nil
+}
+
+// Work returns the impaired samples. The output length changes with the clock drift and the output
+// buffer is reused on the next call.
+func (s *Simulator) Work(in []complex64) []complex64 {
+	for _, v := range in {
+		s.powerSum += float64(real(v)*real(v) + imag(v)*imag(v))
+	}
+	s.powerCount += int64(len(in))
+
+	s.out = s.out[:0]
+	s.resample(in)
+
+	sigma := float64(0)
+	if s.noise && s.powerCount > 0 {
+		// Each component has half of the noise power
+		sigma = math.Sqrt(s.powerSum / float64(s.powerCount) * s.noiseFactor / 2)
+	}
+
+	for i, v := range s.out {
+		re := float64(real(v))
+		im := float64(imag(v))
+
+		if sigma > 0 {
+			re += s.rng.NormFloat64() * sigma
+			im += s.rng.NormFloat64() * sigma
+		}
+
+		sin, cos := math.Sincos(s.phase)
+		re, im = re*cos-im*sin, re*sin+im*cos
+
+		s.phase += s.phaseStep
+		s.phaseStep += s.phaseDrift
+		if s.phaseNoise > 0 {
+			s.phase += s.rng.NormFloat64() * s.phaseNoise
+		}
+
+		im = s.iqGain * (im*s.iqCos + re*s.iqSin)
+
+		s.out[i] = complex(float32(re+s.cfg.DCI), float32(im+s.cfg.DCQ))
+	}
+
+	s.phase = math.Mod(s.phase, 2*math.Pi)
+
+	return s.out
+}
+
+// resample interpolates the input at the drifting clock with a cubic (4 points Lagrange) interpolator
+func (s *Simulator) resample(in []complex64) {
+	s.pending = append(s.pending, in...)
+
+	// Needs the sample before and the two after the output position
+	for s.pos+2 < float64(len(s.pending)) {
+		i := int(s.pos)
+		mu := float32(s.pos - float64(i))
+
+		x0 := s.pending[i-1]
+		x1 := s.pending[i]
+		x2 := s.pending[i+1]
+		x3 := s.pending[i+2]
+
+		c0 := -mu * (mu - 1) * (mu - 2) / 6
+		c1 := (mu + 1) * (mu - 1) * (mu - 2) / 2
+		c2 := -(mu + 1) * mu * (mu - 2) / 2
+		c3 := (mu + 1) * mu * (mu - 1) / 6
+
+		s.out = append(s.out, x0*complex(c0, 0)+x1*complex(c1, 0)+x2*complex(c2, 0)+x3*complex(c3, 0))
+		s.pos += s.step
+	}
+
+	// Keep one sample before the next position
+	drop := int(s.pos) - 1
+	if drop > len(s.pending) {
+		drop = len(s.pending)
+	}
+	if drop > 0 {
+		s.pending = s.pending[:copy(s.pending, s.pending[drop:])]
+		s.pos -= float64(drop)
+	}
+}
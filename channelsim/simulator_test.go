@@ -0,0 +1,194 @@
+package channelsim
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+const testSamples = 200000
+
+// run passes the input through the simulator in blocks and returns a copy of the output
+func run(t *testing.T, cfg Config, in []complex64) []complex64 {
+	s, err := MakeSimulator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]complex64, 0, len(in))
+	for start := 0; start < len(in); start += 4099 {
+		end := start + 4099
+		if end > len(in) {
+			end = len(in)
+		}
+		out = append(out, s.Work(in[start:end])...)
+	}
+	return out
+}
+
+// makeQPSK returns random QPSK symbols of power p repeated sps times
+func makeQPSK(n, sps int, p float64) []complex64 {
+	rng := rand.New(rand.NewSource(7))
+	a := float32(math.Sqrt(p / 2))
+	in := make([]complex64, n)
+	for i := 0; i < n; i += sps {
+		v := complex(a*float32(2*rng.Intn(2)-1), a*float32(2*rng.Intn(2)-1))
+		for k := i; k < i+sps && k < n; k++ {
+			in[k] = v
+		}
+	}
+	return in
+}
+
+func makeTone(n int, omega float64) []complex64 {
+	in := make([]complex64, n)
+	for i := range in {
+		in[i] = complex64(cmplx.Rect(1, omega*float64(i)))
+	}
+	return in
+}
+
+// frequency returns the mean frequency of samples at sampleRate
+func frequency(samples []complex64, sampleRate float64) float64 {
+	sum := complex128(0)
+	for i := 1; i < len(samples); i++ {
+		sum += complex128(samples[i] * complex(real(samples[i-1]), -imag(samples[i-1])))
+	}
+	return cmplx.Phase(sum) * sampleRate / (2 * math.Pi)
+}
+
+func TestAWGN(t *testing.T) {
+	for _, esN0 := range []float64{0, 6, 15} {
+		cfg := DefaultConfig()
+		cfg.EsN0 = esN0
+
+		// The Es/N0 doesn't depend on the signal level
+		const power = 0.3
+		in := makeQPSK(testSamples, 2, power)
+		out := run(t, cfg, in)
+
+		// No timing offset, the interpolator keeps the last two samples for the next block
+		if len(out) != len(in)-2 {
+			t.Fatalf("%d samples from %d", len(out), len(in))
+		}
+
+		noise := float64(0)
+		for i, v := range out {
+			d := v - in[i]
+			noise += float64(real(d)*real(d) + imag(d)*imag(d))
+		}
+		noise /= float64(len(out))
+
+		// Es = P * sps / SampleRate, N0 = noise variance / SampleRate
+		sps := cfg.SampleRate / cfg.SymbolRate
+		measured := 10 * math.Log10(power*sps/noise)
+		if math.Abs(measured-esN0) > 0.05 {
+			t.Errorf("Es/N0 of %.3f dB, expected %g", measured, esN0)
+		}
+	}
+}
+
+func TestNoImpairments(t *testing.T) {
+	in := makeQPSK(1000, 2, 1)
+	for i, v := range run(t, DefaultConfig(), in) {
+		if v != in[i] {
+			t.Fatalf("sample %d is %v, expected %v", i, v, in[i])
+		}
+	}
+}
+
+func TestFrequencyOffset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FrequencyOffset = -12345
+	cfg.FrequencyDrift = 200e3
+
+	out := run(t, cfg, makeTone(testSamples, 0))
+
+	// The frequency at a sample is the offset plus the drift since the start
+	const window = 1000
+	for _, start := range []int{0, len(out) / 2, len(out) - window} {
+		measured := frequency(out[start:start+window], cfg.SampleRate)
+		expected := cfg.FrequencyOffset + cfg.FrequencyDrift*(float64(start)+window/2)/cfg.SampleRate
+		if math.Abs(measured-expected) > 1 {
+			t.Errorf("frequency at sample %d is %.2f Hz, expected %.2f", start, measured, expected)
+		}
+	}
+}
+
+func TestPhaseNoise(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PhaseNoise = 1000
+
+	out := run(t, cfg, makeTone(testSamples, 0))
+
+	// The phase is a random walk with a variance of 2 pi linewidth / sample rate per sample
+	variance := float64(0)
+	for i := 1; i < len(out); i++ {
+		d := cmplx.Phase(complex128(out[i] * complex(real(out[i-1]), -imag(out[i-1]))))
+		variance += d * d
+	}
+	variance /= float64(len(out) - 1)
+
+	expected := 2 * math.Pi * cfg.PhaseNoise / cfg.SampleRate
+	if math.Abs(variance/expected-1) > 0.03 {
+		t.Errorf("phase step variance %g, expected %g", variance, expected)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	// A slow tone is interpolated with a negligible error
+	const omega = 2 * math.Pi * 0.01
+
+	for _, tt := range []struct {
+		offset float64
+		drift  float64
+	}{
+		{0.25, 0},
+		{1.6, 0},
+		{0.5, 100},
+		{0, -250},
+	} {
+		cfg := DefaultConfig()
+		cfg.TimingOffset = tt.offset
+		cfg.ClockDrift = tt.drift
+
+		out := run(t, cfg, makeTone(testSamples, omega))
+
+		// Output sample k is the input at offset * sps + k * (1 + drift)
+		step := 1 + tt.drift*1e-6
+		delay := tt.offset * cfg.SampleRate / cfg.SymbolRate
+		expectedLength := int(math.Ceil((testSamples - 2 - delay) / step))
+		if len(out) < expectedLength-1 || len(out) > expectedLength+1 {
+			t.Errorf("offset %g drift %g: %d samples, expected %d", tt.offset, tt.drift, len(out), expectedLength)
+		}
+
+		for k, v := range out {
+			if delay+float64(k)*step < 1 {
+				// The interpolator starts with a zero sample before the input
+				continue
+			}
+			expected := complex64(cmplx.Rect(1, omega*(delay+float64(k)*step)))
+			if cmplx.Abs(complex128(v-expected)) > 1e-4 {
+				t.Fatalf("offset %g drift %g: sample %d is %v, expected %v", tt.offset, tt.drift, k, v, expected)
+			}
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, change := range []func(c *Config){
+		func(c *Config) { c.SymbolRate = 0 },
+		func(c *Config) { c.EsN0 = math.NaN() },
+		func(c *Config) { c.PhaseNoise = -1 },
+		func(c *Config) { c.TimingOffset = -0.5 },
+		func(c *Config) { c.ClockDrift = 1e5 },
+		func(c *Config) { c.IQPhase = 90 },
+	} {
+		c := DefaultConfig()
+		change(&c)
+		if _, err := MakeSimulator(c); err == nil {
+			t.Errorf("invalid config %+v accepted", c)
+		}
+	}
+}
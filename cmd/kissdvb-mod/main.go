@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"github.com/racerxdl/kissdvb/modulator"
+	"io"
+	"log"
+	"os"
+)
+
+// kissdvb-mod generates a DVB-S baseband (complex64 cfile) from a transport stream file
+func main() {
+	var input, output string
+
+	cfg := modulator.DefaultConfig()
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.StringVar(&input, "input", "", "Input transport stream file")
+	fs.StringVar(&output, "output", "", "Output baseband file (complex64)")
+	cfg.BindFlags(fs)
+	_ = fs.Parse(os.Args[1:])
+
+	if input == "" || output == "" {
+		log.Fatalln("-input and -output are required")
+	}
+
+	in, err := os.Open(input)
+	if err != nil {
+		log.Fatalf("Cannot open input: %s", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Cannot create output: %s", err)
+	}
+
+	writer := bufio.NewWriter(out)
+
+	mod, err := modulator.MakeModulator(cfg, writer)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+
+	reader := bufio.NewReader(in)
+	packet := make([]byte, 188)
+	skipped := 0
+
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			break
+		}
+
+		if b != 0x47 { // Resync
+			skipped++
+			continue
+		}
+
+		packet[0] = b
+		if _, err := io.ReadFull(reader, packet[1:]); err != nil {
+			break
+		}
+
+		mod.PutTSFrame(packet)
+	}
+
+	if err := mod.Flush(); err != nil {
+		log.Fatalf("Error writing output: %s", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("Error writing output: %s", err)
+	}
+
+	if err := out.Close(); err != nil {
+		log.Fatalf("Error writing output: %s", err)
+	}
+
+	if skipped > 0 {
+		log.Printf("Skipped %d bytes out of sync", skipped)
+	}
+
+	log.Printf("Modulated %d packets (%s, %d samples per symbol) to %s", mod.GetPackets(), cfg.CodeRate, cfg.SamplesPerSymbol(), output)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"github.com/racerxdl/kissdvb/channelsim"
+	"io"
+	"log"
+	"math"
+	"os"
+)
+
+const bufferSize = 65535
+
+// kissdvb-sim adds channel impairments to a baseband file (complex64 cfile)
+func main() {
+	var input, output string
+
+	cfg := channelsim.DefaultConfig()
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.StringVar(&input, "input", "", "Input baseband file (complex64)")
+	fs.StringVar(&output, "output", "", "Output baseband file (complex64)")
+	cfg.BindFlags(fs)
+	_ = fs.Parse(os.Args[1:])
+
+	if input == "" || output == "" {
+		log.Fatalln("-input and -output are required")
+	}
+
+	sim, err := channelsim.MakeSimulator(cfg)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+
+	in, err := os.Open(input)
+	if err != nil {
+		log.Fatalf("Cannot open input: %s", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Cannot create output: %s", err)
+	}
+
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	raw := make([]byte, bufferSize*8)
+	samples := make([]complex64, bufferSize)
+	total := 0
+
+	for {
+		n, readErr := io.ReadFull(reader, raw)
+		n /= 8
+
+		for i := 0; i < n; i++ {
+			re := math.Float32frombits(binary.LittleEndian.Uint32(raw[i*8:]))
+			im := math.Float32frombits(binary.LittleEndian.Uint32(raw[i*8+4:]))
+			samples[i] = complex(re, im)
+		}
+
+		impaired := sim.Work(samples[:n])
+		if err := binary.Write(writer, binary.LittleEndian, impaired); err != nil {
+			log.Fatalf("Error writing output: %s", err)
+		}
+
+		total += len(impaired)
+
+		if readErr != nil {
+			if readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+				log.Fatalf("Error reading input: %s", readErr)
+			}
+			break
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("Error writing output: %s", err)
+	}
+
+	if err := out.Close(); err != nil {
+		log.Fatalf("Error writing output: %s", err)
+	}
+
+	log.Printf("Wrote %d samples to %s", total, output)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/kissdvb/receiver"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Input          string `json:"input"`
+	FastAsPossible bool   `json:"fastAsPossible"`
+
+	// File playback
+	Seek     string  `json:"seek"`
+	Loop     bool    `json:"loop"`
+	Speed    float64 `json:"speed"`
+	Controls bool    `json:"controls"`
+
+	// Raw IQ network input
+	IQFormat    string `json:"iqFormat"`
+	IQByteOrder string `json:"iqByteOrder"`
+	UDPSequence int    `json:"udpSequence"`
+
+	// rtl_tcp tuner
+	CenterFrequency float64 `json:"centerFrequency"`
+	Gain            float64 `json:"gain"`
+	AGC             bool    `json:"agc"`
+
+	Record string `json:"record"` // SigMF recording of the input
+
+	receiver.Config
+	receiver.OutputConfig
+
+	Channels []receiver.ChannelConfig `json:"channels"`
+
+	Video         bool              `json:"video"`
+	StatsInterval receiver.Duration `json:"statsInterval"`
+	Metrics       string            `json:"metrics"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Speed:         1,
+		IQFormat:      Frontend.FormatCF32,
+		IQByteOrder:   "little",
+		Config:        receiver.DefaultConfig(),
+		OutputConfig:  receiver.DefaultOutputConfig(),
+		Video:         true,
+		StatsInterval: receiver.Duration{Duration: time.Second * 5},
+	}
+}
+
+func (c *Config) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Input, "input", c.Input, "Input baseband file or SigMF recording, - for raw IQ from stdin or a named pipe, rtltcp://host:port, or udp://host:port and tcp://host:port for raw IQ")
+	fs.BoolVar(&c.FastAsPossible, "fast", c.FastAsPossible, "Read the input file as fast as possible")
+	fs.StringVar(&c.Seek, "seek", c.Seek, "Start the input file at a time like 1m30s or a sample number")
+	fs.BoolVar(&c.Loop, "loop", c.Loop, "Restart the input file at its end")
+	fs.Float64Var(&c.Speed, "speed", c.Speed, "Playback speed of the input file, 1 for real time")
+	fs.BoolVar(&c.Controls, "controls", c.Controls, "Read file playback commands from stdin (headless): pause, resume, seek <position>, speed <multiplier>, loop on|off, status")
+	fs.StringVar(&c.IQFormat, "iq-format", c.IQFormat, "IQ sample format of the input: cf32, cs16, cs8 or cu8")
+	fs.StringVar(&c.IQByteOrder, "iq-byteorder", c.IQByteOrder, "IQ byte order of the input: little or big")
+	fs.IntVar(&c.UDPSequence, "udp-sequence", c.UDPSequence, "Bytes of the sequence number at the start of each UDP datagram: 0, 4 or 8")
+	fs.Float64Var(&c.CenterFrequency, "frequency", c.CenterFrequency, "Center frequency in Hz, tuned by rtl_tcp and written to the SigMF recordings")
+	fs.Float64Var(&c.Gain, "gain", c.Gain, "rtl_tcp tuner gain in dB")
+	fs.BoolVar(&c.AGC, "agc", c.AGC, "rtl_tcp automatic gain")
+	fs.StringVar(&c.Record, "record", c.Record, "Record the input IQ as SigMF to name.sigmf-data and name.sigmf-meta, annotated with the locks and services")
+	c.Config.BindFlags(fs)
+	c.OutputConfig.BindFlags(fs)
+	fs.Var((*channelList)(&c.Channels), "channel", "Receive a channel from a wideband input as offset:symbolrate[:coderate[:tsfile]]. Can be repeated")
+	fs.BoolVar(&c.Video, "video", c.Video, "Play the decoded video and audio (ignored in headless)")
+	fs.DurationVar(&c.StatsInterval.Duration, "stats", c.StatsInterval.Duration, "Statistics log interval (headless)")
+	fs.StringVar(&c.Metrics, "metrics", c.Metrics, "Serve Prometheus metrics at this address (like :9100)")
+}
+
+// LoadConfig parses the command line. If -config is specified, the file is loaded first and the other flags override it.
+func LoadConfig(args []string) (*Config, error) {
+	var configFile string
+
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", "", "JSON config file")
+	cfg.bindFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if configFile != "" {
+		data, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read config file: %s", err)
+		}
+
+		cfg = DefaultConfig()
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("cannot parse config file %s: %s", configFile, err)
+		}
+
+		// Parse again so flags override the file
+		fs = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		fs.StringVar(&configFile, "config", configFile, "JSON config file")
+		cfg.bindFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) Validate() error {
+	if c.Input == "" {
+		return fmt.Errorf("no input specified")
+	}
+
+	if c.Speed <= 0 {
+		return fmt.Errorf("invalid playback speed %f", c.Speed)
+	}
+
+	if c.Seek != "" {
+		if _, _, err := parsePosition(c.Seek); err != nil {
+			return err
+		}
+	}
+
+	if _, err := Frontend.ParseSampleFormat(c.IQFormat); err != nil {
+		return err
+	}
+
+	if _, err := Frontend.ParseByteOrder(c.IQByteOrder); err != nil {
+		return err
+	}
+
+	if c.UDPSequence != 0 && c.UDPSequence != 4 && c.UDPSequence != 8 {
+		return fmt.Errorf("invalid UDP sequence number size %d, expected 0, 4 or 8", c.UDPSequence)
+	}
+
+	if c.CenterFrequency < 0 || c.CenterFrequency > math.MaxUint32 {
+		return fmt.Errorf("invalid center frequency %f", c.CenterFrequency)
+	}
+
+	if c.Gain < 0 || c.Gain > math.MaxUint8 {
+		return fmt.Errorf("invalid gain %f", c.Gain)
+	}
+
+	if c.StatsInterval.Duration <= 0 {
+		return fmt.Errorf("invalid statistics interval %s", c.StatsInterval)
+	}
+
+	if len(c.Channels) == 0 {
+		if err := c.Config.Validate(); err != nil {
+			return err
+		}
+
+		return c.OutputConfig.Validate()
+	}
+
+	// The channel receivers use the base config with their own symbol rate
+	for i, ch := range c.Channels {
+		if err := ch.Validate(c.Config, c.SampleRate); err != nil {
+			return fmt.Errorf("channel %d: %s", i+1, err)
+		}
+	}
+
+	if c.TSOutput != "" || c.UDPOutput != "" || c.HTTPOutput != "" {
+		return fmt.Errorf("-ts, -udp and -http are not used with channels, set the outputs of each channel")
+	}
+
+	return nil
+}
+
+type channelList []receiver.ChannelConfig
+
+func (l *channelList) String() string {
+	return fmt.Sprintf("%d channels", len(*l))
+}
+
+func (l *channelList) Set(value string) error {
+	parts := strings.SplitN(value, ":", 4)
+	if len(parts) < 2 {
+		return fmt.Errorf("expected offset:symbolrate[:coderate[:tsfile]]")
+	}
+
+	frequency, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return fmt.Errorf("invalid channel offset %q", parts[0])
+	}
+
+	symbolRate, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid channel symbol rate %q", parts[1])
+	}
+
+	ch := receiver.ChannelConfig{
+		Frequency:  frequency,
+		SymbolRate: symbolRate,
+		Outputs:    receiver.DefaultOutputConfig(),
+	}
+
+	if len(parts) > 2 {
+		ch.CodeRate = parts[2]
+	}
+
+	if len(parts) > 3 {
+		ch.Outputs.TSOutput = parts[3]
+	}
+
+	*l = append(*l, ch)
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadTestConfig(t *testing.T, data string, args ...string) (*Config, error) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	return LoadConfig(append([]string{"-config", f.Name()}, args...))
+}
+
+func TestConfigDurations(t *testing.T) {
+	cfg, err := loadTestConfig(t, `{"input": "test.cfile", "statsInterval": "1m30s", "tsRotateTime": "2h"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.StatsInterval.Duration != 90*time.Second || cfg.TSRotateTime.Duration != 2*time.Hour {
+		t.Errorf("durations %s and %s", cfg.StatsInterval, cfg.TSRotateTime)
+	}
+
+	// Integer nanoseconds are still read, the flags override the file
+	cfg, err = loadTestConfig(t, `{"input": "test.cfile", "statsInterval": 2000000000, "tsRotateTime": 0}`, "-ts-rotate-time", "10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.StatsInterval.Duration != 2*time.Second || cfg.TSRotateTime.Duration != 10*time.Minute {
+		t.Errorf("durations %s and %s", cfg.StatsInterval, cfg.TSRotateTime)
+	}
+
+	for _, data := range []string{
+		`{"input": "test.cfile", "statsInterval": "5 minutes"}`,
+		`{"input": "test.cfile", "tsRotateTime": true}`,
+		`{"input": "test.cfile", "statsInterval": "0s"}`,
+		`{"input": "test.cfile", "tsRotateTime": "-1h"}`,
+	} {
+		if _, err := loadTestConfig(t, data); err == nil {
+			t.Errorf("invalid config %s accepted", data)
+		}
+	}
+}
+
+func TestConfigChannels(t *testing.T) {
+	// The base symbol rate is not used with channels
+	cfg, err := loadTestConfig(t, `{"input": "test.cfile", "sampleRate": 1e6, "symbolRate": 1e6}`,
+		"-channel", "-200e3:250e3", "-channel", "200e3:100e3:3/4:out.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Channels) != 2 || cfg.Channels[1].Outputs.TSOutput != "out.ts" {
+		t.Errorf("channels %+v", cfg.Channels)
+	}
+
+	_, err = loadTestConfig(t, `{"input": "test.cfile", "sampleRate": 1e6}`, "-channel", "0:100e3", "-channel", "100e3:600e3")
+	if err == nil || !strings.HasPrefix(err.Error(), "channel 2:") {
+		t.Errorf("channel with less than 2 samples per symbol accepted: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package dvbs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// https://www.etsi.org/deliver/etsi_en/300400_300499/300421/01.01.02_60/en_300421v010102p.pdf page 13
+
+type CodeRate int
+
+const (
+	CodeRateAuto CodeRate = iota - 1
+	CodeRate1_2
+	CodeRate2_3
+	CodeRate3_4
+	CodeRate5_6
+	CodeRate7_8
+)
+
+// CodeRates are the punctured rates of the inner code
+var CodeRates = []CodeRate{CodeRate1_2, CodeRate2_3, CodeRate3_4, CodeRate5_6, CodeRate7_8}
+
+type puncturePattern struct {
+	x []byte
+	y []byte
+}
+
+// EN 300 421 Table 2. 1 = transmitted, 0 = punctured
+var puncturePatterns = map[CodeRate]puncturePattern{
+	CodeRate1_2: {x: []byte{1}, y: []byte{1}},
+	CodeRate2_3: {x: []byte{1, 0}, y: []byte{1, 1}},
+	CodeRate3_4: {x: []byte{1, 0, 1}, y: []byte{1, 1, 0}},
+	CodeRate5_6: {x: []byte{1, 0, 1, 0, 1}, y: []byte{1, 1, 0, 1, 0}},
+	CodeRate7_8: {x: []byte{1, 0, 0, 0, 1, 0, 1}, y: []byte{1, 1, 1, 1, 0, 1, 0}},
+}
+
+func (cr CodeRate) String() string {
+	switch cr {
+	case CodeRateAuto:
+		return "Auto"
+	case CodeRate1_2:
+		return "1/2"
+	case CodeRate2_3:
+		return "2/3"
+	case CodeRate3_4:
+		return "3/4"
+	case CodeRate5_6:
+		return "5/6"
+	case CodeRate7_8:
+		return "7/8"
+	}
+
+	return fmt.Sprintf("CodeRate(%d)", int(cr))
+}
+
+func ParseCodeRate(s string) (CodeRate, error) {
+	if strings.ToLower(s) == "auto" {
+		return CodeRateAuto, nil
+	}
+
+	for _, cr := range CodeRates {
+		if cr.String() == s {
+			return cr, nil
+		}
+	}
+
+	return CodeRateAuto, fmt.Errorf("invalid code rate %q", s)
+}
+
+// Period returns the number of decoded bits in one puncturing period
+func (cr CodeRate) Period() int {
+	return len(puncturePatterns[cr].x)
+}
+
+// Pattern returns the puncturing pattern of the X and Y bits of each period, 1 = transmitted, 0 = punctured
+func (cr CodeRate) Pattern() (x, y []byte) {
+	p := puncturePatterns[cr]
+	return p.x, p.y
+}
+
+// Puncture removes the punctured bits of the mother code bits (X0 Y0 X1 Y1...) starting at the specified phase.
+// Returns the number of bits written to output.
+func Puncture(rate CodeRate, phase int, input, output []byte) int {
+	p := puncturePatterns[rate]
+	period := len(p.x)
+	k := 0
+
+	for i := 0; i < len(input)/2; i++ {
+		pos := (phase + i) % period
+
+		if p.x[pos] == 1 {
+			output[k] = input[i*2]
+			k++
+		}
+
+		if p.y[pos] == 1 {
+			output[k] = input[i*2+1]
+			k++
+		}
+	}
+
+	return k
+}
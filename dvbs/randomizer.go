@@ -0,0 +1,48 @@
+package dvbs
+
+import (
+	"log"
+)
+
+const PacketSize = 188
+
+// ScanPackets is the number of packets in one period of the energy dispersal sequence, the first one has the
+// inverted sync byte
+const ScanPackets = 8
+
+var derandomizerLut []byte
+
+func init() {
+	derandomizerLut = make([]byte, PacketSize*ScanPackets)
+	derandomizerLut[0] = 0xFF
+
+	st := uint16(169)
+
+	for i := 1; i < PacketSize*ScanPackets; i++ {
+		out := byte(0)
+		for n := 0; n < 8; n++ {
+			bit := ((uint(st) >> 13) ^ (uint(st) >> 14)) & 1
+			out = byte((uint(out) << 1) | bit) // MSB first
+			st = uint16((uint(st) << 1) | bit) // Feedback
+		}
+
+		if i%PacketSize != 0 {
+			derandomizerLut[i] = out
+		} else {
+			derandomizerLut[i] = 0x00 // Sync bytes are not xored
+		}
+	}
+}
+
+// DeRandomize removes the energy dispersal of ScanPackets packets, EN 300 421 4.4.1. It is a xor with the PRBS,
+// so it also randomizes.
+func DeRandomize(frame []byte) {
+	if len(frame) != PacketSize*ScanPackets {
+		log.Printf("Expected %d got %d for DeRandomize Size", PacketSize*ScanPackets, len(frame))
+		return
+	}
+
+	for i := 0; i < len(frame); i++ {
+		frame[i] ^= derandomizerLut[i]
+	}
+}
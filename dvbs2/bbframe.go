@@ -0,0 +1,195 @@
+package dvbs2
+
+import "fmt"
+
+const bbHeaderSize = 10
+const tsPacketSize = 188
+const tsSyncByte = 0x47
+
+// SYNCD when no user packet starts in the data field
+const noSync = 0xFFFF
+
+var crc8Table [256]byte
+
+func init() {
+	// g(X) = X^8 + X^7 + X^6 + X^4 + X^2 + 1, EN 302 307 5.1.4
+	for i := 0; i < 256; i++ {
+		crc := byte(i)
+		for n := 0; n < 8; n++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0xD5
+			} else {
+				crc <<= 1
+			}
+		}
+		crc8Table[i] = crc
+	}
+}
+
+func crc8(data []byte) byte {
+	crc := byte(0)
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+
+	return crc
+}
+
+// BBHeader is the BBFRAME header, EN 302 307 5.1.6
+type BBHeader struct {
+	MaType1 byte
+	MaType2 byte
+	UPL     int // User packet length in bits
+	DFL     int // Data field length in bits
+	Sync    byte
+	SyncD   int // Bits from the start of the data field to the first user packet
+}
+
+// IsTransportStream is true for single or multiple transport stream input
+func (h BBHeader) IsTransportStream() bool {
+	return h.MaType1>>6 == 3
+}
+
+// RollOff returns the transmitted roll-off
+func (h BBHeader) RollOff() float64 {
+	switch h.MaType1 & 3 {
+	case 0:
+		return 0.35
+	case 1:
+		return 0.25
+	case 2:
+		return 0.20
+	}
+
+	return 0
+}
+
+// ParseBBHeader parses and checks the header of a descrambled BBFRAME. Only normal mode (CRC-8 not XORed with
+// the mode) is accepted.
+func ParseBBHeader(frame []byte) (BBHeader, error) {
+	if len(frame) < bbHeaderSize {
+		return BBHeader{}, fmt.Errorf("BBFRAME too short")
+	}
+
+	if crc := crc8(frame[:bbHeaderSize-1]); crc != frame[bbHeaderSize-1] {
+		return BBHeader{}, fmt.Errorf("BBHEADER CRC error, expected %02x got %02x", crc, frame[bbHeaderSize-1])
+	}
+
+	h := BBHeader{
+		MaType1: frame[0],
+		MaType2: frame[1],
+		UPL:     int(frame[2])<<8 | int(frame[3]),
+		DFL:     int(frame[4])<<8 | int(frame[5]),
+		Sync:    frame[6],
+		SyncD:   int(frame[7])<<8 | int(frame[8]),
+	}
+
+	if h.DFL > (len(frame)-bbHeaderSize)*8 {
+		return h, fmt.Errorf("invalid data field length %d", h.DFL)
+	}
+
+	return h, nil
+}
+
+// TSRecovery rebuilds the transport stream from the BBFRAME data fields. The sync byte of each user packet carries
+// the CRC-8 of the previous one, packets that don't match get the transport_error_indicator bit set. So each packet
+// is delivered when the next one is received.
+type TSRecovery struct {
+	packet  [tsPacketSize]byte
+	fill    int
+	synced  bool
+	last    [tsPacketSize]byte
+	hasLast bool
+
+	crcErrors int64
+}
+
+func MakeTSRecovery() *TSRecovery {
+	return &TSRecovery{}
+}
+
+// Reset drops the partial packet, used when a BBFRAME was lost. The pending packet is returned unchecked.
+func (t *TSRecovery) Reset() [][]byte {
+	packets := make([][]byte, 0, 1)
+	if t.hasLast {
+		packets = append(packets, t.takeLast(false))
+	}
+
+	t.synced = false
+	t.fill = 0
+
+	return packets
+}
+
+// CRCErrors returns the number of packets that failed the CRC-8 check
+func (t *TSRecovery) CRCErrors() int64 {
+	return t.crcErrors
+}
+
+// PutBBFrame takes a descrambled BBFRAME with a valid header and returns the completed transport stream packets
+func (t *TSRecovery) PutBBFrame(frame []byte, h BBHeader) [][]byte {
+	packets := make([][]byte, 0)
+
+	if !h.IsTransportStream() || h.UPL != tsPacketSize*8 || h.DFL%8 != 0 {
+		return append(packets, t.Reset()...)
+	}
+
+	data := frame[bbHeaderSize : bbHeaderSize+h.DFL/8]
+
+	if h.SyncD != noSync {
+		start := h.SyncD / 8
+		if h.SyncD%8 != 0 || start > len(data) {
+			return append(packets, t.Reset()...)
+		}
+
+		if t.synced && (t.fill == 0 && start != 0 || t.fill > 0 && tsPacketSize-t.fill != start) {
+			// The user packets of this frame don't continue the previous ones
+			packets = append(packets, t.Reset()...)
+		}
+
+		if !t.synced {
+			data = data[start:]
+			t.synced = true
+			t.fill = 0
+		}
+	}
+
+	if !t.synced {
+		return packets
+	}
+
+	for len(data) > 0 {
+		n := copy(t.packet[t.fill:], data)
+		t.fill += n
+		data = data[n:]
+
+		if t.fill < tsPacketSize {
+			break
+		}
+
+		if t.hasLast {
+			packets = append(packets, t.takeLast(crc8(t.last[1:]) != t.packet[0]))
+		}
+
+		t.last = t.packet
+		t.last[0] = tsSyncByte
+		t.hasLast = true
+		t.fill = 0
+	}
+
+	return packets
+}
+
+func (t *TSRecovery) takeLast(crcError bool) []byte {
+	p := make([]byte, tsPacketSize)
+	copy(p, t.last[:])
+
+	if crcError {
+		p[1] |= 0x80
+		t.crcErrors++
+	}
+
+	t.hasLast = false
+
+	return p
+}
@@ -0,0 +1,205 @@
+package dvbs2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const testPID = 0x100
+
+func makeTestPacket(n int) []byte {
+	p := make([]byte, tsPacketSize)
+	p[0] = tsSyncByte
+	p[1] = byte(testPID >> 8)
+	p[2] = byte(testPID & 0xFF)
+	p[3] = 0x10 | byte(n&0xF)
+	binary.BigEndian.PutUint32(p[4:], uint32(n))
+	for i := 8; i < tsPacketSize; i++ {
+		p[i] = byte(n*7 + i)
+	}
+	return p
+}
+
+// makeBBFrames splits the packets into BBFRAMEs (header and data field) of dataBytes, the sync byte of each packet
+// carries the CRC-8 of the previous one, EN 302 307 5.1.5
+func makeBBFrames(packets [][]byte, dataBytes int) [][]byte {
+	stream := make([]byte, 0, len(packets)*tsPacketSize)
+	crc := byte(0)
+	for _, p := range packets {
+		stream = append(stream, crc)
+		stream = append(stream, p[1:]...)
+		crc = crc8(p[1:])
+	}
+
+	frames := make([][]byte, 0)
+	for start := 0; start+dataBytes <= len(stream); start += dataBytes {
+		syncD := (tsPacketSize - start%tsPacketSize) % tsPacketSize * 8
+		if syncD >= dataBytes*8 {
+			syncD = noSync
+		}
+
+		h := []byte{
+			0xF0, 0, // Single transport stream, CCM, roll-off 0.35
+			byte(tsPacketSize * 8 >> 8), byte(tsPacketSize * 8 & 0xFF),
+			byte(dataBytes * 8 >> 8), byte(dataBytes * 8 & 0xFF),
+			tsSyncByte,
+			byte(syncD >> 8), byte(syncD & 0xFF),
+		}
+		h = append(h, crc8(h))
+
+		frames = append(frames, append(h, stream[start:start+dataBytes]...))
+	}
+
+	return frames
+}
+
+func TestParseBBHeader(t *testing.T) {
+	frame := makeBBFrames([][]byte{makeTestPacket(0), makeTestPacket(1)}, 300)[0]
+
+	h, err := ParseBBHeader(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.IsTransportStream() || h.UPL != tsPacketSize*8 || h.DFL != 300*8 || h.Sync != tsSyncByte || h.SyncD != 0 {
+		t.Errorf("wrong header %+v", h)
+	}
+
+	if h.RollOff() != 0.35 {
+		t.Errorf("roll-off %g, expected 0.35", h.RollOff())
+	}
+
+	frame[4] ^= 1
+	if _, err := ParseBBHeader(frame); err == nil {
+		t.Error("header CRC error not detected")
+	}
+
+	if _, err := ParseBBHeader(frame[:bbHeaderSize-1]); err == nil {
+		t.Error("short frame accepted")
+	}
+
+	// Data field longer than the frame
+	short := makeBBFrames([][]byte{makeTestPacket(0)}, 100)[0]
+	if _, err := ParseBBHeader(short[:bbHeaderSize+50]); err == nil {
+		t.Error("data field length beyond the frame accepted")
+	}
+}
+
+// recoverPackets feeds the frames to a TSRecovery and returns the packets
+func recoverPackets(ts *TSRecovery, frames [][]byte) ([][]byte, error) {
+	packets := make([][]byte, 0)
+	for _, frame := range frames {
+		h, err := ParseBBHeader(frame)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, ts.PutBBFrame(frame, h)...)
+	}
+	return packets, nil
+}
+
+func TestTSRecovery(t *testing.T) {
+	const numPackets = 40
+
+	packets := make([][]byte, numPackets)
+	for i := range packets {
+		packets[i] = makeTestPacket(i)
+	}
+
+	// Data fields shorter and longer than a packet, the short ones have frames without a packet start
+	for _, dataBytes := range []int{100, 500, 869} {
+		frames := makeBBFrames(packets, dataBytes)
+		complete := len(frames) * dataBytes / tsPacketSize
+
+		ts := MakeTSRecovery()
+		recovered, err := recoverPackets(ts, frames)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The last packet waits for the CRC in the next one
+		if len(recovered) != complete-1 {
+			t.Fatalf("data field %d: recovered %d packets, expected %d", dataBytes, len(recovered), complete-1)
+		}
+
+		for i, p := range recovered {
+			if !bytes.Equal(p, packets[i]) {
+				t.Fatalf("data field %d: packet %d differs", dataBytes, i)
+			}
+		}
+
+		if ts.CRCErrors() != 0 {
+			t.Errorf("data field %d: %d CRC errors", dataBytes, ts.CRCErrors())
+		}
+
+		// Reset returns the pending packet unchecked
+		if pending := ts.Reset(); len(pending) != 1 || !bytes.Equal(pending[0], packets[complete-1]) {
+			t.Errorf("data field %d: pending packet not returned by Reset", dataBytes)
+		}
+	}
+}
+
+func TestTSRecoveryErrors(t *testing.T) {
+	const numPackets = 20
+	const dataBytes = 500
+
+	packets := make([][]byte, numPackets)
+	for i := range packets {
+		packets[i] = makeTestPacket(i)
+	}
+
+	// A corrupted payload byte fails the CRC carried by the next packet
+	frames := makeBBFrames(packets, dataBytes)
+	frames[0][bbHeaderSize+2*tsPacketSize-10] ^= 0x40
+
+	ts := MakeTSRecovery()
+	recovered, err := recoverPackets(ts, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range recovered {
+		tei := p[1]&0x80 != 0
+		if tei != (i == 1) {
+			t.Errorf("packet %d: transport error indicator %v", i, tei)
+		}
+	}
+
+	if ts.CRCErrors() != 1 {
+		t.Errorf("%d CRC errors, expected 1", ts.CRCErrors())
+	}
+
+	// After a lost frame the recovery restarts at the first packet of the next one
+	frames = makeBBFrames(packets, dataBytes)
+	ts = MakeTSRecovery()
+	if _, err := recoverPackets(ts, frames[:1]); err != nil {
+		t.Fatal(err)
+	}
+	ts.Reset()
+
+	recovered, err = recoverPackets(ts, frames[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := (2*dataBytes + tsPacketSize - 1) / tsPacketSize
+	if len(recovered) == 0 {
+		t.Fatal("no packet after the lost frame")
+	}
+
+	for i, p := range recovered {
+		if !bytes.Equal(p, packets[first+i]) {
+			t.Fatalf("packet %d after the lost frame differs", first+i)
+		}
+	}
+
+	// Other streams are ignored
+	frame := makeBBFrames(packets, dataBytes)[0]
+	frame[0] = 0x70
+	frame[bbHeaderSize-1] = crc8(frame[:bbHeaderSize-1])
+	h, _ := ParseBBHeader(frame)
+	if p := MakeTSRecovery().PutBBFrame(frame, h); len(p) != 0 {
+		t.Error("generic stream recovered as transport stream")
+	}
+}
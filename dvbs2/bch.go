@@ -0,0 +1,185 @@
+package dvbs2
+
+// Primitive polynomials of the BCH fields, g1(x) of EN 302 307 Tables 6a and 6b
+const bchNormalPoly = 0x1002D // 1 + x^2 + x^3 + x^5 + x^16
+const bchShortPoly = 0x402B   // 1 + x + x^3 + x^5 + x^14
+
+// galoisField holds the exp and log tables of GF(2^m)
+type galoisField struct {
+	size int // 2^m - 1
+	exp  []uint16
+	log  []int32
+}
+
+func makeGaloisField(m uint, poly int) *galoisField {
+	size := 1<<m - 1
+	gf := &galoisField{
+		size: size,
+		exp:  make([]uint16, 2*size),
+		log:  make([]int32, size+1),
+	}
+
+	x := 1
+	for i := 0; i < 2*size; i++ {
+		gf.exp[i] = uint16(x)
+		x <<= 1
+		if x&(1<<m) != 0 {
+			x ^= poly
+		}
+	}
+
+	for i := 0; i < size; i++ {
+		gf.log[gf.exp[i]] = int32(i)
+	}
+
+	return gf
+}
+
+func (gf *galoisField) mul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gf.exp[int(gf.log[a])+int(gf.log[b])]
+}
+
+func (gf *galoisField) div(a, b uint16) uint16 {
+	if a == 0 {
+		return 0
+	}
+
+	return gf.exp[(int(gf.log[a])-int(gf.log[b])+gf.size)%gf.size]
+}
+
+// pow returns alpha^e
+func (gf *galoisField) pow(e int) uint16 {
+	e %= gf.size
+	if e < 0 {
+		e += gf.size
+	}
+
+	return gf.exp[e]
+}
+
+// bchDecoder corrects the shortened narrow sense BCH codes of DVB-S2. The code polynomial has roots
+// alpha^1 to alpha^2t, so the generator polynomial is not needed for decoding.
+type bchDecoder struct {
+	normal *galoisField
+	short  *galoisField
+}
+
+func makeBCHDecoder() *bchDecoder {
+	return &bchDecoder{
+		normal: makeGaloisField(16, bchNormalPoly),
+		short:  makeGaloisField(14, bchShortPoly),
+	}
+}
+
+// Decode corrects the codeword bits (one per byte, first transmitted bit is the highest degree) in place.
+// It returns the corrected bits or -1 when there are more than t errors.
+func (b *bchDecoder) Decode(bits []byte, t int, short bool) int {
+	gf := b.normal
+	if short {
+		gf = b.short
+	}
+
+	n := len(bits)
+
+	// Syndromes S(j) = c(alpha^j), the even ones are the squares S(2j) = S(j)^2
+	syndromes := make([]uint16, 2*t+1)
+	errors := false
+	for j := 1; j <= 2*t; j += 2 {
+		s := uint16(0)
+		alpha := gf.pow(j)
+		for _, bit := range bits {
+			s = gf.mul(s, alpha) ^ uint16(bit)
+		}
+		syndromes[j] = s
+		errors = errors || s != 0
+	}
+
+	if !errors {
+		return 0
+	}
+
+	for j := 2; j <= 2*t; j += 2 {
+		syndromes[j] = gf.mul(syndromes[j/2], syndromes[j/2])
+	}
+
+	// Berlekamp Massey
+	lambda := make([]uint16, t+2)
+	prev := make([]uint16, t+2)
+	tmp := make([]uint16, t+2)
+	lambda[0] = 1
+	prev[0] = 1
+	l := 0
+	shift := 1
+	prevDiscrepancy := uint16(1)
+
+	for k := 1; k <= 2*t; k++ {
+		discrepancy := syndromes[k]
+		for i := 1; i <= l; i++ {
+			discrepancy ^= gf.mul(lambda[i], syndromes[k-i])
+		}
+
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+
+		factor := gf.div(discrepancy, prevDiscrepancy)
+		copy(tmp, lambda)
+
+		for i := 0; i+shift < len(lambda); i++ {
+			lambda[i+shift] ^= gf.mul(factor, prev[i])
+		}
+
+		if 2*l <= k-1 {
+			l = k - l
+			copy(prev, tmp)
+			prevDiscrepancy = discrepancy
+			shift = 1
+		} else {
+			shift++
+		}
+	}
+
+	if l > t {
+		return -1
+	}
+
+	// Chien search over the shortened code: an error at degree d is a root at alpha^-d
+	terms := make([]uint16, l+1)
+	steps := make([]uint16, l+1)
+	copy(terms, lambda[:l+1])
+	for i := 1; i <= l; i++ {
+		steps[i] = gf.pow(-i)
+	}
+
+	positions := make([]int, 0, l)
+	for d := 0; d < n && len(positions) < l; d++ {
+		sum := uint16(0)
+		for i := 0; i <= l; i++ {
+			sum ^= terms[i]
+		}
+
+		if sum == 0 {
+			positions = append(positions, n-1-d)
+		}
+
+		for i := 1; i <= l; i++ {
+			terms[i] = gf.mul(terms[i], steps[i])
+		}
+	}
+
+	if len(positions) != l {
+		// Some roots are outside of the shortened code
+		return -1
+	}
+
+	for _, p := range positions {
+		bits[p] ^= 1
+	}
+
+	return l
+}
@@ -0,0 +1,126 @@
+package dvbs2
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// bchGenerator returns the generator polynomial (highest degree first) with the roots alpha^1 to alpha^2t and their
+// conjugates
+func bchGenerator(gf *galoisField, t int) []byte {
+	roots := make(map[int]bool)
+	for j := 1; j <= 2*t; j++ {
+		for e := j % gf.size; !roots[e]; e = e * 2 % gf.size {
+			roots[e] = true
+		}
+	}
+
+	// Lowest degree first while multiplying by (x + root)
+	g := []uint16{1}
+	for e := range roots {
+		root := gf.pow(e)
+		next := make([]uint16, len(g)+1)
+		for i, c := range g {
+			next[i+1] ^= c
+			next[i] ^= gf.mul(c, root)
+		}
+		g = next
+	}
+
+	generator := make([]byte, len(g))
+	for i, c := range g {
+		if c > 1 {
+			panic("generator coefficient outside of GF(2)")
+		}
+		generator[len(g)-1-i] = byte(c)
+	}
+
+	return generator
+}
+
+// bchEncode returns the systematic codeword of the message bits
+func bchEncode(message, generator []byte) []byte {
+	parityBits := len(generator) - 1
+	codeword := make([]byte, len(message)+parityBits)
+	copy(codeword, message)
+
+	remainder := make([]byte, len(codeword))
+	copy(remainder, codeword)
+	for i := range message {
+		if remainder[i] == 1 {
+			for j, g := range generator {
+				remainder[i+j] ^= g
+			}
+		}
+	}
+
+	copy(codeword[len(message):], remainder[len(message):])
+
+	return codeword
+}
+
+func randomBits(n int, rng *rand.Rand) []byte {
+	bits := make([]byte, n)
+	for i := range bits {
+		bits[i] = byte(rng.Intn(2))
+	}
+	return bits
+}
+
+func TestBCHDecode(t *testing.T) {
+	tests := []struct {
+		rate  string
+		short bool
+	}{
+		{"1/2", false},
+		{"2/3", false},
+		{"9/10", false},
+		{"1/2", true},
+		{"8/9", true},
+	}
+
+	dec := makeBCHDecoder()
+	rng := rand.New(rand.NewSource(1))
+
+	for _, tt := range tests {
+		params, _ := getCodeParams(tt.rate, tt.short)
+		gf := dec.normal
+		if tt.short {
+			gf = dec.short
+		}
+
+		generator := bchGenerator(gf, params.t)
+		if len(generator)-1 != params.kldpc-params.kbch {
+			t.Fatalf("%s short %v: generator degree %d, expected %d", tt.rate, tt.short, len(generator)-1, params.kldpc-params.kbch)
+		}
+
+		codeword := bchEncode(randomBits(params.kbch, rng), generator)
+
+		for errors := 0; errors <= params.t; errors += params.t / 2 {
+			bits := make([]byte, len(codeword))
+			copy(bits, codeword)
+			for _, p := range rng.Perm(len(bits))[:errors] {
+				bits[p] ^= 1
+			}
+
+			if n := dec.Decode(bits, params.t, tt.short); n != errors {
+				t.Errorf("%s short %v: %d errors, decoder corrected %d", tt.rate, tt.short, errors, n)
+			}
+
+			if !bytes.Equal(bits, codeword) {
+				t.Errorf("%s short %v: codeword with %d errors not corrected", tt.rate, tt.short, errors)
+			}
+		}
+
+		bits := make([]byte, len(codeword))
+		copy(bits, codeword)
+		for _, p := range rng.Perm(len(bits))[:params.t+1] {
+			bits[p] ^= 1
+		}
+
+		if n := dec.Decode(bits, params.t, tt.short); n != -1 {
+			t.Errorf("%s short %v: %d errors not detected, decoder corrected %d", tt.rate, tt.short, params.t+1, n)
+		}
+	}
+}
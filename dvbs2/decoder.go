@@ -0,0 +1,93 @@
+package dvbs2
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupported = errors.New("unsupported MODCOD")
+var ErrLDPC = errors.New("LDPC decoding failed")
+var ErrBCH = errors.New("BCH decoding failed")
+
+// Decoder turns the PLFRAME symbols into descrambled BBFRAMEs
+type Decoder struct {
+	codes *Codes
+	ldpc  *LDPCDecoder
+	bch   *bchDecoder
+
+	llr  []float32
+	bits []byte
+}
+
+func MakeDecoder(codes *Codes, ldpcIterations int) *Decoder {
+	return &Decoder{
+		codes: codes,
+		ldpc:  MakeLDPCDecoder(ldpcIterations),
+		bch:   makeBCHDecoder(),
+	}
+}
+
+// DecodeResult has the error correction statistics of a frame
+type DecodeResult struct {
+	Iterations   int
+	BCHCorrected int
+}
+
+// Decode returns the descrambled BBFRAME of the frame
+func (d *Decoder) Decode(frame *Frame) ([]byte, DecodeResult, error) {
+	res := DecodeResult{}
+
+	m, ok := GetModCod(frame.PLS.ModCod)
+	if !ok || frame.Symbols == nil {
+		return nil, res, ErrUnsupported
+	}
+
+	params, ok := getCodeParams(m.Rate, frame.PLS.Short)
+	if !ok {
+		return nil, res, ErrUnsupported
+	}
+
+	// Also unsupported when the LDPC table wasn't loaded
+	code := d.codes.Get(m.Rate, frame.PLS.Short)
+	if code == nil {
+		return nil, res, ErrUnsupported
+	}
+
+	if len(frame.Symbols)*m.Modulation.BitsPerSymbol() != code.N() {
+		return nil, res, fmt.Errorf("expected %d bits, got %d symbols", code.N(), len(frame.Symbols))
+	}
+
+	if cap(d.llr) < code.N() {
+		d.llr = make([]float32, code.N())
+		d.bits = make([]byte, code.N())
+	}
+	llr := d.llr[:code.N()]
+	bits := d.bits[:code.K()]
+
+	demap(m.Modulation, m.Rate, frame.Symbols, frame.N0, llr)
+
+	iterations, ok := d.ldpc.Decode(code, llr, bits)
+	res.Iterations = iterations
+	if !ok {
+		return nil, res, ErrLDPC
+	}
+
+	corrected := d.bch.Decode(bits, params.t, frame.PLS.Short)
+	if corrected < 0 {
+		return nil, res, ErrBCH
+	}
+	res.BCHCorrected = corrected
+
+	bbFrame := make([]byte, params.kbch/8)
+	for i := range bbFrame {
+		b := byte(0)
+		for _, bit := range bits[i*8 : i*8+8] {
+			b = b<<1 | bit
+		}
+		bbFrame[i] = b
+	}
+
+	bbDescramble(bbFrame)
+
+	return bbFrame, res, nil
+}
@@ -0,0 +1,105 @@
+package dvbs2
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestDecoder runs short QPSK 1/2 FECFRAMEs through BCH and LDPC encoding, noise, decoding and TS recovery
+func TestDecoder(t *testing.T) {
+	const numFrames = 4
+	const modCod = 4 // QPSK 1/2
+
+	groups := testGroups()
+	code, err := ReadLDPCCode(strings.NewReader(testTable(groups)), testRate, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := &Codes{
+		codes: map[string]*LDPCCode{LDPCTableName(testRate, true): code},
+	}
+
+	params, _ := getCodeParams(testRate, true)
+	generator := bchGenerator(makeBCHDecoder().short, params.t)
+	dataBytes := (params.kbch/8 - bbHeaderSize)
+
+	packets := make([][]byte, numFrames*dataBytes/tsPacketSize+1)
+	for i := range packets {
+		packets[i] = makeTestPacket(i)
+	}
+
+	rng := rand.New(rand.NewSource(9))
+	dec := MakeDecoder(codes, 50)
+	ts := MakeTSRecovery()
+	recovered := make([][]byte, 0)
+
+	// Es/N0 of 4 dB
+	n0 := float32(math.Pow(10, -0.4))
+	sigma := math.Sqrt(float64(n0) / 2)
+
+	for _, bbFrame := range makeBBFrames(packets, dataBytes) {
+		// The scrambling is a XOR, descrambling scrambles
+		scrambled := make([]byte, params.kbch/8)
+		copy(scrambled, bbFrame)
+		bbDescramble(scrambled)
+
+		message := make([]byte, params.kbch)
+		for i := range message {
+			message[i] = scrambled[i/8] >> uint(7-i%8) & 1
+		}
+
+		codeword := ldpcEncode(bchEncode(message, generator), groups, shortFrameBits)
+
+		symbols := make([]complex64, len(codeword)/2)
+		for i := range symbols {
+			re := float64(qpskAmplitude)*(1-2*float64(codeword[2*i])) + rng.NormFloat64()*sigma
+			im := float64(qpskAmplitude)*(1-2*float64(codeword[2*i+1])) + rng.NormFloat64()*sigma
+			symbols[i] = complex(float32(re), float32(im))
+		}
+
+		frame := &Frame{
+			PLS:     PLS{ModCod: modCod, Short: true},
+			Symbols: symbols,
+			N0:      n0,
+		}
+
+		decoded, res, err := dec.Decode(frame)
+		if err != nil {
+			t.Fatalf("decoding failed: %s", err)
+		}
+
+		if res.Iterations == 0 {
+			t.Error("frame without channel errors")
+		}
+
+		if !bytes.Equal(decoded[:len(bbFrame)], bbFrame) {
+			t.Fatal("decoded BBFRAME differs")
+		}
+
+		h, err := ParseBBHeader(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recovered = append(recovered, ts.PutBBFrame(decoded, h)...)
+	}
+
+	if len(recovered) < len(packets)-2 {
+		t.Fatalf("recovered %d packets, expected %d", len(recovered), len(packets)-2)
+	}
+
+	for i, p := range recovered {
+		if !bytes.Equal(p, packets[i]) {
+			t.Fatalf("packet %d differs", i)
+		}
+	}
+
+	// Codes without a table aren't supported
+	if _, _, err := dec.Decode(&Frame{PLS: PLS{ModCod: 6, Short: true}, Symbols: make([]complex64, 8100)}); err != ErrUnsupported {
+		t.Errorf("short QPSK 3/4 without a table: %v", err)
+	}
+}
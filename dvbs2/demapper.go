@@ -0,0 +1,91 @@
+package dvbs2
+
+import (
+	"math"
+)
+
+// 8PSK constellation of EN 302 307 Figure 10, indexed by the 3 bits of the symbol (first bit is the MSB)
+var constellation8PSK [8]complex64
+
+var qpskAmplitude = float32(math.Sqrt2 / 2)
+
+func init() {
+	angles := [8]float64{1, 0, 4, 5, 2, 7, 3, 6}
+	for i, a := range angles {
+		s, c := math.Sincos(a * math.Pi / 4)
+		constellation8PSK[i] = complex(float32(c), float32(s))
+	}
+}
+
+// demap writes the bit LLRs (positive for 0) of the unit energy symbols in codeword order.
+// n0 is the complex noise variance.
+func demap(m Modulation, rate string, symbols []complex64, n0 float32, llr []float32) {
+	switch m {
+	case ModulationQPSK:
+		scale := 4 * qpskAmplitude / n0
+		for i, s := range symbols {
+			llr[2*i] = real(s) * scale
+			llr[2*i+1] = imag(s) * scale
+		}
+	case Modulation8PSK:
+		// Column twist of the bit interleaver, EN 302 307 5.3.3
+		rows := len(symbols)
+		columns := [3]int{0, rows, 2 * rows}
+		if rate == "3/5" {
+			columns = [3]int{2 * rows, rows, 0}
+		}
+
+		var distances [8]float32
+		for i, s := range symbols {
+			for p, c := range constellation8PSK {
+				d := s - c
+				distances[p] = real(d)*real(d) + imag(d)*imag(d)
+			}
+
+			for bit := 0; bit < 3; bit++ {
+				mask := 4 >> uint(bit)
+				min0 := float32(math.MaxFloat32)
+				min1 := float32(math.MaxFloat32)
+				for p, d := range distances {
+					if p&mask == 0 {
+						if d < min0 {
+							min0 = d
+						}
+					} else if d < min1 {
+						min1 = d
+					}
+				}
+
+				llr[columns[bit]+i] = (min1 - min0) / n0
+			}
+		}
+	}
+}
+
+// decide returns the closest constellation point, used by the decision directed phase tracking
+func decide(m Modulation, s complex64) complex64 {
+	if m == Modulation8PSK {
+		best := 0
+		bestDistance := float32(math.MaxFloat32)
+		for p, c := range constellation8PSK {
+			d := s - c
+			if v := real(d)*real(d) + imag(d)*imag(d); v < bestDistance {
+				best = p
+				bestDistance = v
+			}
+		}
+
+		return constellation8PSK[best]
+	}
+
+	re := qpskAmplitude
+	im := qpskAmplitude
+	if real(s) < 0 {
+		re = -re
+	}
+	if imag(s) < 0 {
+		im = -im
+	}
+
+	return complex(re, im)
+}
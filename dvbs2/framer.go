@@ -0,0 +1,451 @@
+package dvbs2
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Normalized SOF correlation needed to acquire, and to keep the lock on the following frames
+const sofAcquireThreshold = 0.5
+const sofTrackThreshold = 0.3
+
+// Normalized differential correlation of the best PLSCODE
+const plsThreshold = 0.45
+
+// Missing SOFs before losing the lock
+const maxMisses = 3
+
+// Symbols the next SOF is searched around its expected position, for clock recovery slips
+const maxSlip = 2
+
+const headerFrequencyGain = 0.2
+const pilotFrequencyGain = 0.5
+
+// Decision directed phase tracking of the frames without pilots
+const pllAlpha = 0.02
+const pllBeta = pllAlpha * pllAlpha / 4
+
+var pilotSymbol = complex(qpskAmplitude, qpskAmplitude)
+
+// Frame is a PLFRAME with the carrier corrected and descrambled symbols
+type Frame struct {
+	PLS     PLS
+	Symbols []complex64 // Unit energy data symbols without the pilots, nil for the unsupported modulations
+	Known   []complex64 // Corrected PLHEADER and pilot symbols, all of them are QPSK points
+	N0      float32     // Noise variance of the unit energy symbols
+}
+
+// Framer finds the PLFRAMEs in the clock recovered symbols, corrects the carrier frequency and phase with the
+// PLHEADER and pilots and removes the PL scrambling
+type Framer struct {
+	scrambling []byte
+
+	buffer []complex64
+	pos    int // Start of the current PLHEADER in the buffer
+	locked bool
+	found  bool // pos is at a PLHEADER
+	misses int
+
+	frequency float64 // Carrier frequency offset in radians per symbol
+	pls       PLS
+}
+
+// MakeFramer creates a framer for the PL scrambling code n (0 is the default of EN 302 307)
+func MakeFramer(scramblingCode int) *Framer {
+	return &Framer{
+		scrambling: plScrambling(scramblingCode),
+	}
+}
+
+func (f *Framer) IsLocked() bool {
+	return f.locked
+}
+
+// GetPLS returns the PL signalling of the last frame
+func (f *Framer) GetPLS() PLS {
+	return f.pls
+}
+
+// GetFrequency returns the carrier frequency offset in radians per symbol
+func (f *Framer) GetFrequency() float64 {
+	return f.frequency
+}
+
+// Work adds clock recovered symbols and returns the completed frames
+func (f *Framer) Work(symbols []complex64) []*Frame {
+	f.buffer = append(f.buffer, symbols...)
+	frames := make([]*Frame, 0)
+
+	for {
+		if !f.found && !f.search() {
+			break
+		}
+
+		if len(f.buffer) < f.pos+headerLength {
+			break
+		}
+
+		hint := -1
+		if f.locked {
+			hint = f.pls.Code()
+		}
+
+		pls, metric := decodePLS(f.buffer[f.pos:f.pos+headerLength], hint)
+		if metric < plsThreshold || !pls.Valid() {
+			f.lost()
+			continue
+		}
+
+		length := pls.FrameLength()
+		if len(f.buffer) < f.pos+length+sofLength+maxSlip {
+			break
+		}
+
+		// The frame is only accepted if the next one starts where expected
+		threshold := sofTrackThreshold
+		if !f.locked {
+			threshold = sofAcquireThreshold
+		}
+
+		next, ok := f.findNext(f.pos+length, threshold)
+		if !ok {
+			if !f.locked {
+				f.lost()
+				continue
+			}
+
+			f.misses++
+			if f.misses > maxMisses {
+				f.lost()
+				continue
+			}
+		} else {
+			f.misses = 0
+		}
+
+		frame := f.processFrame(f.buffer[f.pos:f.pos+length], pls)
+		f.locked = true
+		f.pls = pls
+		f.pos = next
+
+		if pls.ModCod != ModCodDummy {
+			frames = append(frames, frame)
+		}
+	}
+
+	// Keep the symbols of the current frame
+	if f.pos > 0 {
+		f.buffer = f.buffer[:copy(f.buffer, f.buffer[f.pos:])]
+		f.pos = 0
+	}
+
+	return frames
+}
+
+// lost restarts the search after the current position
+func (f *Framer) lost() {
+	f.locked = false
+	f.found = false
+	f.misses = 0
+	f.pos++
+}
+
+// search looks for a SOF from pos. It's false when more symbols are needed.
+func (f *Framer) search() bool {
+	for ; f.pos+headerLength+maxSlip <= len(f.buffer); f.pos++ {
+		if c, _ := sofCorrelation(f.buffer[f.pos:]); c < sofAcquireThreshold {
+			continue
+		}
+
+		// Take the peak
+		best, _ := f.bestSOF(f.pos, maxSlip)
+		if best < f.pos {
+			best = f.pos
+		}
+		f.pos = best
+		f.found = true
+
+		return true
+	}
+
+	return false
+}
+
+// findNext looks for the next SOF around the expected position
+func (f *Framer) findNext(expected int, threshold float64) (int, bool) {
+	best, c := f.bestSOF(expected, maxSlip)
+	if c < threshold {
+		return expected, false
+	}
+
+	return best, true
+}
+
+func (f *Framer) bestSOF(center, slip int) (int, float64) {
+	best := center
+	bestCorrelation := float64(-1)
+
+	for p := center - slip; p <= center+slip; p++ {
+		if p < 0 || p+sofLength > len(f.buffer) {
+			continue
+		}
+
+		if c, _ := sofCorrelation(f.buffer[p:]); c > bestCorrelation {
+			best = p
+			bestCorrelation = c
+		}
+	}
+
+	return best, bestCorrelation
+}
+
+// phaseAnchor is the carrier phase measured on the known symbols centered at pos
+type phaseAnchor struct {
+	pos   float64
+	phase float64
+}
+
+func (f *Framer) processFrame(symbols []complex64, pls PLS) *Frame {
+	ref := &headerSymbols[pls.Code()]
+	frame := &Frame{
+		PLS: pls,
+	}
+
+	// Frequency from the known PLHEADER
+	z := make([]complex128, headerLength)
+	for i := range z {
+		z[i] = complex128(symbols[i] * conj(ref[i]))
+	}
+
+	if w := estimateFrequency(z); !f.locked {
+		f.frequency = w
+	} else {
+		f.frequency += headerFrequencyGain * (w - f.frequency)
+	}
+
+	m, _ := GetModCod(pls.ModCod)
+	supported := pls.ModCod != ModCodDummy && (m.Modulation == ModulationQPSK || m.Modulation == Modulation8PSK)
+
+	// Remove the frequency offset, referenced to the center of the PLHEADER, and the PL scrambling
+	center := float64(headerLength-1) / 2
+	corrected := make([]complex128, len(symbols))
+	for i, s := range symbols {
+		if i >= headerLength {
+			s = descramble(s, f.scrambling[i-headerLength])
+		}
+		corrected[i] = complex128(s) * cmplx.Exp(complex(0, -f.frequency*(float64(i)-center)))
+	}
+
+	// The PLHEADER estimation is too coarse for the pilot spacing at low Es/N0, refine it over the whole frame
+	if supported {
+		order, delay := 4, 64
+		if m.Modulation == Modulation8PSK {
+			order, delay = 8, 32
+		}
+
+		residual := powerFrequency(corrected, order, delay)
+		f.frequency += residual
+
+		for i := range corrected {
+			corrected[i] *= cmplx.Exp(complex(0, -residual*(float64(i)-center)))
+		}
+	}
+
+	var h complex128
+	for i := 0; i < headerLength; i++ {
+		h += corrected[i] * complex128(conj(ref[i]))
+	}
+
+	amplitude := cabs(h) / headerLength
+	if amplitude == 0 {
+		amplitude = 1
+	}
+
+	anchors := []phaseAnchor{{center, cmplx.Phase(h)}}
+	pilots := make([]int, 0)
+
+	for b := 1; b <= pls.PilotBlocks(); b++ {
+		start := headerLength + b*pilotPeriod*slotLength + (b-1)*pilotBlockLength
+		pilots = append(pilots, start)
+
+		var p complex128
+		for i := start; i < start+pilotBlockLength; i++ {
+			p += corrected[i] * complex128(conj(pilotSymbol))
+		}
+
+		last := anchors[len(anchors)-1].phase
+		anchors = append(anchors, phaseAnchor{
+			pos:   float64(start) + float64(pilotBlockLength-1)/2,
+			phase: last + wrapPhase(cmplx.Phase(p)-last),
+		})
+	}
+
+	// Residual frequency, also used after the last pilot block
+	slope := float64(0)
+	if len(anchors) > 1 {
+		slope = phaseSlope(anchors)
+		f.frequency += pilotFrequencyGain * slope
+	}
+
+	isPilot := func(i int) bool {
+		for _, start := range pilots {
+			if i >= start && i < start+pilotBlockLength {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Known symbols corrected with the interpolated phase
+	frame.Known = make([]complex64, 0, headerLength+len(pilots)*pilotBlockLength)
+	noise := float64(0)
+	for i := range corrected {
+		if i >= headerLength && !isPilot(i) {
+			continue
+		}
+
+		s := corrected[i] * cmplx.Exp(complex(0, -interpolatePhase(anchors, slope, float64(i)))) / complex(amplitude, 0)
+		expected := complex128(pilotSymbol)
+		if i < headerLength {
+			expected = complex128(ref[i])
+		}
+
+		noise += sqAbs(s - expected)
+		frame.Known = append(frame.Known, complex64(s))
+	}
+
+	frame.N0 = float32(noise / float64(len(frame.Known)))
+	if frame.N0 <= 0 {
+		frame.N0 = 1e-3
+	}
+
+	if !supported {
+		return frame
+	}
+
+	frame.Symbols = make([]complex64, 0, pls.Slots()*slotLength)
+
+	if len(pilots) > 0 {
+		for i := headerLength; i < len(corrected); i++ {
+			if isPilot(i) {
+				continue
+			}
+
+			s := corrected[i] * cmplx.Exp(complex(0, -interpolatePhase(anchors, slope, float64(i)))) / complex(amplitude, 0)
+			frame.Symbols = append(frame.Symbols, complex64(s))
+		}
+
+		return frame
+	}
+
+	// Decision directed tracking from the PLHEADER phase
+	phase := anchors[0].phase
+	residual := float64(0)
+	for i := headerLength; i < len(corrected); i++ {
+		s := corrected[i] * cmplx.Exp(complex(0, -phase)) / complex(amplitude, 0)
+		d := decide(m.Modulation, complex64(s))
+		e := cmplx.Phase(s * complex128(conj(d)))
+
+		residual += pllBeta * e
+		phase += residual + pllAlpha*e
+
+		frame.Symbols = append(frame.Symbols, complex64(s))
+	}
+
+	f.frequency += pilotFrequencyGain * residual
+
+	return frame
+}
+
+// estimateFrequency returns the frequency in radians per symbol of the modulation removed symbols. A one symbol
+// delay estimation is refined with Luise and Reggiannini over half of the symbols.
+func estimateFrequency(z []complex128) float64 {
+	var c complex128
+	for i := 1; i < len(z); i++ {
+		c += z[i] * cmplx.Conj(z[i-1])
+	}
+	coarse := cmplx.Phase(c)
+
+	derotated := make([]complex128, len(z))
+	for i := range z {
+		derotated[i] = z[i] * cmplx.Exp(complex(0, -coarse*float64(i)))
+	}
+
+	m := len(z) / 2
+	var r complex128
+	for d := 1; d <= m; d++ {
+		var acc complex128
+		for i := d; i < len(z); i++ {
+			acc += derotated[i] * cmplx.Conj(derotated[i-d])
+		}
+		r += acc / complex(float64(len(z)-d), 0)
+	}
+
+	return coarse + 2*cmplx.Phase(r)/float64(m+1)
+}
+
+// powerFrequency estimates the frequency of the symbols raised to the modulation order, which removes the PSK
+// modulation of the data, the PLHEADER and the pilots. The range is +-pi/(order*delay) radians per symbol.
+func powerFrequency(symbols []complex128, order, delay int) float64 {
+	powered := make([]complex128, len(symbols))
+	for i, s := range symbols {
+		p := s
+		for n := 1; n < order; n++ {
+			p *= s
+		}
+		powered[i] = p
+	}
+
+	var c complex128
+	for i := delay; i < len(powered); i++ {
+		c += powered[i] * cmplx.Conj(powered[i-delay])
+	}
+
+	return cmplx.Phase(c) / float64(order*delay)
+}
+
+// phaseSlope is the least squares slope of the anchor phases in radians per symbol
+func phaseSlope(anchors []phaseAnchor) float64 {
+	n := float64(len(anchors))
+	sx, sy, sxx, sxy := 0.0, 0.0, 0.0, 0.0
+	for _, a := range anchors {
+		sx += a.pos
+		sy += a.phase
+		sxx += a.pos * a.pos
+		sxy += a.pos * a.phase
+	}
+
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0
+	}
+
+	return (n*sxy - sx*sy) / d
+}
+
+// interpolatePhase interpolates the anchors linearly and extrapolates with the slope after the last anchor
+func interpolatePhase(anchors []phaseAnchor, slope, pos float64) float64 {
+	if pos <= anchors[0].pos {
+		return anchors[0].phase
+	}
+
+	for i := 1; i < len(anchors); i++ {
+		if pos <= anchors[i].pos {
+			a := anchors[i-1]
+			b := anchors[i]
+			return a.phase + (b.phase-a.phase)*(pos-a.pos)/(b.pos-a.pos)
+		}
+	}
+
+	last := anchors[len(anchors)-1]
+
+	return last.phase + slope*(pos-last.pos)
+}
+
+func wrapPhase(p float64) float64 {
+	return math.Remainder(p, 2*math.Pi)
+}
+
+func sqAbs(c complex128) float64 {
+	return real(c)*real(c) + imag(c)*imag(c)
+}
@@ -0,0 +1,216 @@
+//go:build ignore
+// +build ignore
+
+// gen_ldpc_tables writes the LDPC address tables of EN 302 307 Annex B and C into ldpc_tables.go.
+//
+// The sources are either gr-dtv dvb_ldpc_bb_impl.cc (or any C file with the same ldpc_tab_<a>_<b><N|S> arrays) or
+// directories with the tables as text files named like LDPCTableName, normal_3_4.txt.
+//
+//	go run gen_ldpc_tables.go -o ldpc_tables.go path/to/dvb_ldpc_bb_impl.cc
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type table struct {
+	rate   string
+	short  bool
+	groups [][]int
+}
+
+var textTableName = regexp.MustCompile(`^(normal|short)_(\d+)_(\d+)\.txt$`)
+var cArrayStart = regexp.MustCompile(`ldpc_tab_(\d+)_(\d+)([NS])\s*\[[^\]]*\]\s*\[[^\]]*\]\s*=\s*\{`)
+var cRow = regexp.MustCompile(`\{([^{}]*)\}`)
+
+func main() {
+	output := flag.String("o", "ldpc_tables.go", "Output file")
+	flag.Parse()
+
+	tables := make(map[string]table)
+	sources := make([]string, 0)
+
+	for _, source := range flag.Args() {
+		info, err := os.Stat(source)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var found []table
+		if info.IsDir() {
+			found, err = readTextTables(source)
+		} else {
+			found, err = readCTables(source)
+		}
+		if err != nil {
+			log.Fatalf("%s: %s", source, err)
+		}
+		if len(found) == 0 {
+			log.Fatalf("%s: no LDPC tables found", source)
+		}
+
+		for _, t := range found {
+			tables[key(t)] = t
+		}
+		sources = append(sources, filepath.Base(source))
+	}
+
+	if err := ioutil.WriteFile(*output, generate(tables, sources), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("%d tables written to %s", len(tables), *output)
+}
+
+func key(t table) string {
+	size := "normal"
+	if t.short {
+		size = "short"
+	}
+	return fmt.Sprintf("%s_%s", size, strings.Replace(t.rate, "/", "_", 1))
+}
+
+func parseAddresses(s string) ([]int, error) {
+	addresses := make([]int, 0)
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == ',' || r == '\n' || r == '\r' }) {
+		v, err := strconv.Atoi(f)
+		if err != nil || v < 0 || v > 0xFFFF {
+			return nil, fmt.Errorf("invalid parity address %q", f)
+		}
+		addresses = append(addresses, v)
+	}
+	return addresses, nil
+}
+
+func readTextTables(dir string) ([]table, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]table, 0)
+	for _, fi := range files {
+		m := textTableName.FindStringSubmatch(fi.Name())
+		if m == nil {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		t := table{rate: m[2] + "/" + m[3], short: m[1] == "short"}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			addresses, err := parseAddresses(line)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %s", fi.Name(), err)
+			}
+			t.groups = append(t.groups, addresses)
+		}
+		f.Close()
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, t)
+	}
+
+	return tables, nil
+}
+
+// readCTables reads the arrays of gr-dtv, each row is the number of addresses followed by the addresses
+func readCTables(path string) ([]table, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src := string(data)
+	tables := make([]table, 0)
+
+	for _, loc := range cArrayStart.FindAllStringSubmatchIndex(src, -1) {
+		name := src[loc[0]:loc[1]]
+		end := strings.Index(src[loc[1]:], "};")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated array %s", name)
+		}
+
+		t := table{
+			rate:  src[loc[2]:loc[3]] + "/" + src[loc[4]:loc[5]],
+			short: src[loc[6]:loc[7]] == "S",
+		}
+
+		for _, row := range cRow.FindAllStringSubmatch(src[loc[1]:loc[1]+end], -1) {
+			values, err := parseAddresses(row[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			if len(values) == 0 || values[0] != len(values)-1 {
+				return nil, fmt.Errorf("%s: row count doesn't match its addresses", name)
+			}
+			t.groups = append(t.groups, values[1:])
+		}
+
+		tables = append(tables, t)
+	}
+
+	return tables, nil
+}
+
+func generate(tables map[string]table, sources []string) []byte {
+	keys := make([]string, 0, len(tables))
+	for k := range tables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "// Code generated by gen_ldpc_tables.go; DO NOT EDIT.\n\n")
+	if len(sources) == 0 {
+		fmt.Fprintf(b, "// No table sources were given, the LDPC tables are read from the tables directory.\n\n")
+	} else {
+		fmt.Fprintf(b, "// Sources: %s\n\n", strings.Join(sources, ", "))
+	}
+	fmt.Fprintf(b, "package dvbs2\n\n")
+	fmt.Fprintf(b, "var ldpcTables = []ldpcTable{\n")
+	for _, k := range keys {
+		t := tables[k]
+		fmt.Fprintf(b, "{rate: %q, short: %v, groups: [][]uint16{\n", t.rate, t.short)
+		for _, g := range t.groups {
+			s := make([]string, len(g))
+			for i, x := range g {
+				s[i] = strconv.Itoa(x)
+			}
+			fmt.Fprintf(b, "{%s},\n", strings.Join(s, ", "))
+		}
+		fmt.Fprintf(b, "}},\n")
+	}
+	fmt.Fprintf(b, "}\n")
+
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return out
+}
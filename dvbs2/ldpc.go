@@ -0,0 +1,341 @@
+package dvbs2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run gen_ldpc_tables.go -o ldpc_tables.go $LDPC_TABLES_SOURCE
+
+const ldpcGroupSize = 360
+
+// Normalization of the min-sum check node messages
+const minSumScale = 0.75
+
+// Codes are the LDPC codes built from the parity bit address tables
+type Codes struct {
+	codes map[string]*LDPCCode
+}
+
+// LDPCTableName is the file name of the address table of a code rate, like normal_3_4.txt or short_1_2.txt
+func LDPCTableName(rate string, short bool) string {
+	size := "normal"
+	if short {
+		size = "short"
+	}
+
+	return fmt.Sprintf("%s_%s.txt", size, strings.Replace(rate, "/", "_", 1))
+}
+
+// LoadCodes returns the embedded codes and the ones of the tables available in dir, which replace them.
+// dir can be empty. The codes without a table can't be decoded.
+func LoadCodes(dir string) (*Codes, error) {
+	c, err := EmbeddedCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		for _, short := range []bool{false, true} {
+			table := normalCodes
+			if short {
+				table = shortCodes
+			}
+
+			for rate := range table {
+				name := LDPCTableName(rate, short)
+				f, err := os.Open(filepath.Join(dir, name))
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				code, err := ReadLDPCCode(f, rate, short)
+				f.Close()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", name, err)
+				}
+
+				c.codes[name] = code
+			}
+		}
+	}
+
+	if len(c.codes) == 0 {
+		if dir == "" {
+			return nil, fmt.Errorf("no LDPC tables embedded (see go generate in dvbs2), a tables directory is needed")
+		}
+		return nil, fmt.Errorf("no LDPC tables found in %s", dir)
+	}
+
+	return c, nil
+}
+
+// ldpcTable is an address table of EN 302 307 Annex B or C, ldpc_tables.go has the embedded ones
+type ldpcTable struct {
+	rate   string
+	short  bool
+	groups [][]uint16
+}
+
+// HasEmbeddedCodes is true when LDPC tables were generated into the binary
+func HasEmbeddedCodes() bool {
+	return len(ldpcTables) > 0
+}
+
+// EmbeddedCodes returns the codes of the tables generated into ldpc_tables.go
+func EmbeddedCodes() (*Codes, error) {
+	c := &Codes{
+		codes: make(map[string]*LDPCCode),
+	}
+
+	for _, t := range ldpcTables {
+		groups := make([][]int, len(t.groups))
+		for i, addresses := range t.groups {
+			groups[i] = make([]int, len(addresses))
+			for j, x := range addresses {
+				groups[i][j] = int(x)
+			}
+		}
+
+		name := LDPCTableName(t.rate, t.short)
+		code, err := makeLDPCCode(groups, t.rate, t.short)
+		if err != nil {
+			return nil, fmt.Errorf("embedded %s: %s", name, err)
+		}
+
+		c.codes[name] = code
+	}
+
+	return c, nil
+}
+
+// Get returns the LDPC code for the rate and frame size or nil when its table wasn't loaded
+func (c *Codes) Get(rate string, short bool) *LDPCCode {
+	return c.codes[LDPCTableName(rate, short)]
+}
+
+// LDPCCode is a DVB-S2 LDPC code. The parity check matrix is stored by check node.
+type LDPCCode struct {
+	n int
+	k int
+
+	checkStart []int32 // Edges of check i are checkStart[i]:checkStart[i+1]
+	checkVars  []int32
+}
+
+// ReadLDPCCode reads an address table of EN 302 307 Annex B or C: one line for each group of 360 information bits
+// with the addresses of the parity bit accumulators of its first bit.
+func ReadLDPCCode(r io.Reader, rate string, short bool) (*LDPCCode, error) {
+	groups := make([][]int, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		addresses := make([]int, 0)
+		for _, f := range strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' || r == ',' }) {
+			v, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parity address %q", f)
+			}
+			addresses = append(addresses, v)
+		}
+
+		groups = append(groups, addresses)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return makeLDPCCode(groups, rate, short)
+}
+
+// makeLDPCCode builds the parity check matrix from the parity bit addresses of each group of 360 information bits
+func makeLDPCCode(groups [][]int, rate string, short bool) (*LDPCCode, error) {
+	params, ok := getCodeParams(rate, short)
+	if !ok {
+		return nil, fmt.Errorf("invalid code rate %s", rate)
+	}
+
+	n := normalFrameBits
+	if short {
+		n = shortFrameBits
+	}
+
+	nParity := n - params.kldpc
+	q := nParity / ldpcGroupSize
+
+	if len(groups) != params.kldpc/ldpcGroupSize {
+		return nil, fmt.Errorf("expected %d rows, got %d", params.kldpc/ldpcGroupSize, len(groups))
+	}
+
+	// Information bit connections of each check
+	checks := make([][]int32, nParity)
+	for g, addresses := range groups {
+		for _, x := range addresses {
+			if x < 0 || x >= nParity {
+				return nil, fmt.Errorf("invalid parity address %d", x)
+			}
+		}
+
+		for m := 0; m < ldpcGroupSize; m++ {
+			bit := int32(g*ldpcGroupSize + m)
+			for _, x := range addresses {
+				check := (x + m*q) % nParity
+				checks[check] = append(checks[check], bit)
+			}
+		}
+	}
+
+	code := &LDPCCode{
+		n:          n,
+		k:          params.kldpc,
+		checkStart: make([]int32, nParity+1),
+	}
+
+	// The parity bits are accumulated, p(j) = p(j) + p(j-1)
+	for j := 0; j < nParity; j++ {
+		code.checkVars = append(code.checkVars, checks[j]...)
+		if j > 0 {
+			code.checkVars = append(code.checkVars, int32(params.kldpc+j-1))
+		}
+		code.checkVars = append(code.checkVars, int32(params.kldpc+j))
+		code.checkStart[j+1] = int32(len(code.checkVars))
+	}
+
+	return code, nil
+}
+
+func (c *LDPCCode) N() int {
+	return c.n
+}
+
+func (c *LDPCCode) K() int {
+	return c.k
+}
+
+// LDPCDecoder is a layered normalized min-sum decoder. It keeps the message buffers between frames.
+type LDPCDecoder struct {
+	maxIterations int
+	total         []float32
+	messages      []float32
+}
+
+func MakeLDPCDecoder(maxIterations int) *LDPCDecoder {
+	return &LDPCDecoder{
+		maxIterations: maxIterations,
+	}
+}
+
+// Decode corrects the codeword LLRs (positive for 0) and writes the K information bits, one per byte, to output.
+// It returns the iterations used and false if the parity checks still fail after the last iteration.
+func (d *LDPCDecoder) Decode(code *LDPCCode, llr []float32, output []byte) (int, bool) {
+	if cap(d.total) < code.n {
+		d.total = make([]float32, code.n)
+	}
+	d.total = d.total[:code.n]
+	copy(d.total, llr)
+
+	if cap(d.messages) < len(code.checkVars) {
+		d.messages = make([]float32, len(code.checkVars))
+	}
+	d.messages = d.messages[:len(code.checkVars)]
+	for i := range d.messages {
+		d.messages[i] = 0
+	}
+
+	ok := code.check(d.total)
+	iterations := 0
+
+	for ; iterations < d.maxIterations && !ok; iterations++ {
+		for j := 0; j+1 < len(code.checkStart); j++ {
+			start := code.checkStart[j]
+			end := code.checkStart[j+1]
+
+			min1 := float32(math.MaxFloat32)
+			min2 := float32(math.MaxFloat32)
+			minPos := int32(-1)
+			sign := false
+
+			for e := start; e < end; e++ {
+				v := d.total[code.checkVars[e]] - d.messages[e]
+				d.total[code.checkVars[e]] = v
+
+				a := v
+				if a < 0 {
+					a = -a
+					sign = !sign
+				}
+
+				if a < min1 {
+					min2 = min1
+					min1 = a
+					minPos = e
+				} else if a < min2 {
+					min2 = a
+				}
+			}
+
+			for e := start; e < end; e++ {
+				v := d.total[code.checkVars[e]]
+
+				m := min1
+				if e == minPos {
+					m = min2
+				}
+				m *= minSumScale
+
+				// Sign of the product of the other messages
+				if sign != (v < 0) {
+					m = -m
+				}
+
+				d.messages[e] = m
+				d.total[code.checkVars[e]] = v + m
+			}
+		}
+
+		ok = code.check(d.total)
+	}
+
+	for i := 0; i < code.k; i++ {
+		if d.total[i] < 0 {
+			output[i] = 1
+		} else {
+			output[i] = 0
+		}
+	}
+
+	return iterations, ok
+}
+
+// check is true when the hard decisions satisfy all parity checks
+func (c *LDPCCode) check(llr []float32) bool {
+	for j := 0; j+1 < len(c.checkStart); j++ {
+		parity := false
+		for e := c.checkStart[j]; e < c.checkStart[j+1]; e++ {
+			if llr[c.checkVars[e]] < 0 {
+				parity = !parity
+			}
+		}
+
+		if parity {
+			return false
+		}
+	}
+
+	return true
+}
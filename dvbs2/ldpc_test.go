@@ -0,0 +1,283 @@
+package dvbs2
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The tests use a synthetic short 1/2 table with the structure of Annex C: the real tables are generated from their
+// source and aren't needed to check the decoder.
+const testRate = "1/2"
+
+// testGroups returns the parity bit addresses of each group of 360 information bits of the short test code
+func testGroups() [][]int {
+	params, _ := getCodeParams(testRate, true)
+	nParity := shortFrameBits - params.kldpc
+	rng := rand.New(rand.NewSource(7))
+
+	groups := make([][]int, params.kldpc/ldpcGroupSize)
+	for i := range groups {
+		degree := 3
+		if i < 5 {
+			degree = 8
+		}
+		groups[i] = rng.Perm(nParity)[:degree]
+	}
+
+	return groups
+}
+
+func testTable(groups [][]int) string {
+	b := &strings.Builder{}
+	for _, addresses := range groups {
+		for i, x := range addresses {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, "%d", x)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ldpcEncode returns the codeword of the information bits, EN 302 307 5.3.2
+func ldpcEncode(info []byte, groups [][]int, n int) []byte {
+	k := len(info)
+	nParity := n - k
+	q := nParity / ldpcGroupSize
+
+	codeword := make([]byte, n)
+	copy(codeword, info)
+	parity := codeword[k:]
+
+	for i, bit := range info {
+		if bit == 0 {
+			continue
+		}
+		m := i % ldpcGroupSize
+		for _, x := range groups[i/ldpcGroupSize] {
+			parity[(x+m*q)%nParity] ^= 1
+		}
+	}
+
+	for j := 1; j < nParity; j++ {
+		parity[j] ^= parity[j-1]
+	}
+
+	return codeword
+}
+
+// bpskLLR returns the LLRs (positive for 0) of the codeword bits with white gaussian noise of sigma
+func bpskLLR(codeword []byte, sigma float64, rng *rand.Rand) ([]float32, int) {
+	llr := make([]float32, len(codeword))
+	errors := 0
+	for i, bit := range codeword {
+		y := 1 - 2*float64(bit) + rng.NormFloat64()*sigma
+		llr[i] = float32(2 * y / (sigma * sigma))
+		if (y < 0) != (bit == 1) {
+			errors++
+		}
+	}
+	return llr, errors
+}
+
+// cleanLLR returns the LLRs of the codeword bits without noise
+func cleanLLR(codeword []byte) []float32 {
+	llr := make([]float32, len(codeword))
+	for i, bit := range codeword {
+		llr[i] = float32(1 - 2*float64(bit))
+	}
+	return llr
+}
+
+func TestLDPCDecode(t *testing.T) {
+	groups := testGroups()
+	code, err := ReadLDPCCode(strings.NewReader(testTable(groups)), testRate, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(8))
+	info := randomBits(code.K(), rng)
+	codeword := ldpcEncode(info, groups, code.N())
+
+	if !code.check(cleanLLR(codeword)) {
+		t.Fatal("encoded codeword fails the parity checks")
+	}
+
+	dec := MakeLDPCDecoder(50)
+	output := make([]byte, code.K())
+
+	iterations, ok := dec.Decode(code, cleanLLR(codeword), output)
+	if !ok || iterations != 0 || !bytes.Equal(output, info) {
+		t.Fatalf("clean codeword: ok %v after %d iterations", ok, iterations)
+	}
+
+	llr, errors := bpskLLR(codeword, 0.6, rng)
+	if errors == 0 {
+		t.Fatal("no channel errors")
+	}
+
+	iterations, ok = dec.Decode(code, llr, output)
+	if !ok || !bytes.Equal(output, info) {
+		t.Fatalf("%d channel errors not corrected after %d iterations", errors, iterations)
+	}
+
+	// Random LLRs can't satisfy the checks
+	llr, _ = bpskLLR(randomBits(code.N(), rng), 0.6, rng)
+	if _, ok := dec.Decode(code, llr, output); ok {
+		t.Fatal("random word decoded")
+	}
+}
+
+func TestReadLDPCCodeErrors(t *testing.T) {
+	groups := testGroups()
+
+	if _, err := ReadLDPCCode(strings.NewReader(testTable(groups[1:])), testRate, true); err == nil {
+		t.Error("missing row accepted")
+	}
+
+	bad := testTable(groups) + "1 2 3\n"
+	if _, err := ReadLDPCCode(strings.NewReader(bad), testRate, true); err == nil {
+		t.Error("extra row accepted")
+	}
+
+	groups[3] = []int{1, math.MaxInt16}
+	if _, err := ReadLDPCCode(strings.NewReader(testTable(groups)), testRate, true); err == nil {
+		t.Error("parity address out of range accepted")
+	}
+
+	if _, err := ReadLDPCCode(strings.NewReader("1 x 3\n"), testRate, true); err == nil {
+		t.Error("invalid address accepted")
+	}
+
+	if _, err := ReadLDPCCode(strings.NewReader(testTable(testGroups())), "7/8", true); err == nil {
+		t.Error("invalid rate accepted")
+	}
+}
+
+func TestLoadCodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := LDPCTableName(testRate, true)
+	if name != "short_1_2.txt" {
+		t.Fatalf("table name %s", name)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(testTable(testGroups())), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := LoadCodes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := codes.Get(testRate, true)
+	if code == nil || code.N() != shortFrameBits || code.K() != 7200 {
+		t.Fatal("short 1/2 code not loaded from the tables directory")
+	}
+
+	if !HasEmbeddedCodes() && codes.Get("3/4", false) != nil {
+		t.Error("normal 3/4 code without a table")
+	}
+
+	empty, err := ioutil.TempDir("", "ldpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(empty)
+
+	if _, err := LoadCodes(empty); err == nil && !HasEmbeddedCodes() {
+		t.Error("no tables accepted")
+	}
+
+	if _, err := LoadCodes(""); (err == nil) != HasEmbeddedCodes() {
+		t.Errorf("embedded tables %v, error %v", HasEmbeddedCodes(), err)
+	}
+}
+
+func TestEmbeddedCodes(t *testing.T) {
+	if !HasEmbeddedCodes() {
+		t.Skip("no LDPC tables embedded, run go generate with LDPC_TABLES_SOURCE")
+	}
+
+	codes, err := EmbeddedCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range ldpcTables {
+		code := codes.Get(table.rate, table.short)
+		params, _ := getCodeParams(table.rate, table.short)
+		if code == nil || code.K() != params.kldpc {
+			t.Errorf("embedded %s not built", LDPCTableName(table.rate, table.short))
+		}
+	}
+}
+
+// TestEmbeddedDecode encodes and decodes with the first embedded normal and short codes
+func TestEmbeddedDecode(t *testing.T) {
+	if !HasEmbeddedCodes() {
+		t.Skip("no LDPC tables embedded, run go generate with LDPC_TABLES_SOURCE")
+	}
+
+	codes, err := EmbeddedCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(10))
+	dec := MakeLDPCDecoder(50)
+
+	for _, short := range []bool{false, true} {
+		var table *ldpcTable
+		for i := range ldpcTables {
+			if ldpcTables[i].short == short {
+				table = &ldpcTables[i]
+				break
+			}
+		}
+		if table == nil {
+			t.Errorf("no embedded table with short %v", short)
+			continue
+		}
+
+		groups := make([][]int, len(table.groups))
+		for i, addresses := range table.groups {
+			groups[i] = make([]int, len(addresses))
+			for j, x := range addresses {
+				groups[i][j] = int(x)
+			}
+		}
+
+		name := LDPCTableName(table.rate, table.short)
+		code := codes.Get(table.rate, table.short)
+		info := randomBits(code.K(), rng)
+		codeword := ldpcEncode(info, groups, code.N())
+
+		if !code.check(cleanLLR(codeword)) {
+			t.Errorf("%s: encoded codeword fails the parity checks", name)
+			continue
+		}
+
+		llr, errors := bpskLLR(codeword, 0.5, rng)
+		output := make([]byte, code.K())
+		iterations, ok := dec.Decode(code, llr, output)
+		if !ok || !bytes.Equal(output, info) {
+			t.Errorf("%s: %d channel errors not corrected after %d iterations", name, errors, iterations)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package dvbs2
+
+import "fmt"
+
+type Modulation int
+
+const (
+	ModulationQPSK Modulation = iota
+	Modulation8PSK
+	Modulation16APSK
+	Modulation32APSK
+)
+
+func (m Modulation) String() string {
+	switch m {
+	case ModulationQPSK:
+		return "QPSK"
+	case Modulation8PSK:
+		return "8PSK"
+	case Modulation16APSK:
+		return "16APSK"
+	case Modulation32APSK:
+		return "32APSK"
+	}
+
+	return "Unknown"
+}
+
+func (m Modulation) BitsPerSymbol() int {
+	return int(m) + 2
+}
+
+// ModCod is a MODCOD of EN 302 307 Table 12
+type ModCod struct {
+	Modulation Modulation
+	Rate       string
+	EsN0QEF    float64 // Ideal Es/N0 for QEF reception of normal frames in AWGN, EN 302 307 Table 13
+}
+
+// ModCodDummy is the MODCOD of the dummy PLFRAME
+const ModCodDummy = 0
+
+var modCods = map[int]ModCod{
+	1:  {ModulationQPSK, "1/4", -2.35},
+	2:  {ModulationQPSK, "1/3", -1.24},
+	3:  {ModulationQPSK, "2/5", -0.30},
+	4:  {ModulationQPSK, "1/2", 1.00},
+	5:  {ModulationQPSK, "3/5", 2.23},
+	6:  {ModulationQPSK, "2/3", 3.10},
+	7:  {ModulationQPSK, "3/4", 4.03},
+	8:  {ModulationQPSK, "4/5", 4.68},
+	9:  {ModulationQPSK, "5/6", 5.18},
+	10: {ModulationQPSK, "8/9", 6.20},
+	11: {ModulationQPSK, "9/10", 6.42},
+	12: {Modulation8PSK, "3/5", 5.50},
+	13: {Modulation8PSK, "2/3", 6.62},
+	14: {Modulation8PSK, "3/4", 7.91},
+	15: {Modulation8PSK, "5/6", 9.35},
+	16: {Modulation8PSK, "8/9", 10.69},
+	17: {Modulation8PSK, "9/10", 10.98},
+	18: {Modulation16APSK, "2/3", 8.97},
+	19: {Modulation16APSK, "3/4", 10.21},
+	20: {Modulation16APSK, "4/5", 11.03},
+	21: {Modulation16APSK, "5/6", 11.61},
+	22: {Modulation16APSK, "8/9", 12.89},
+	23: {Modulation16APSK, "9/10", 13.13},
+	24: {Modulation32APSK, "3/4", 12.73},
+	25: {Modulation32APSK, "4/5", 13.64},
+	26: {Modulation32APSK, "5/6", 14.28},
+	27: {Modulation32APSK, "8/9", 15.69},
+	28: {Modulation32APSK, "9/10", 16.05},
+}
+
+func GetModCod(id int) (ModCod, bool) {
+	m, ok := modCods[id]
+	return m, ok
+}
+
+func (m ModCod) String() string {
+	return fmt.Sprintf("%s %s", m.Modulation, m.Rate)
+}
+
+// codeParams are the BCH and LDPC parameters of a code rate, EN 302 307 Tables 5a and 5b
+type codeParams struct {
+	kbch  int
+	kldpc int
+	t     int // BCH correctable errors
+}
+
+const normalFrameBits = 64800
+const shortFrameBits = 16200
+
+var normalCodes = map[string]codeParams{
+	"1/4":  {16008, 16200, 12},
+	"1/3":  {21408, 21600, 12},
+	"2/5":  {25728, 25920, 12},
+	"1/2":  {32208, 32400, 12},
+	"3/5":  {38688, 38880, 12},
+	"2/3":  {43040, 43200, 10},
+	"3/4":  {48408, 48600, 12},
+	"4/5":  {51648, 51840, 12},
+	"5/6":  {53840, 54000, 10},
+	"8/9":  {57472, 57600, 8},
+	"9/10": {58192, 58320, 8},
+}
+
+var shortCodes = map[string]codeParams{
+	"1/4": {3072, 3240, 12},
+	"1/3": {5232, 5400, 12},
+	"2/5": {6312, 6480, 12},
+	"1/2": {7032, 7200, 12},
+	"3/5": {9552, 9720, 12},
+	"2/3": {10632, 10800, 12},
+	"3/4": {11712, 11880, 12},
+	"4/5": {12432, 12600, 12},
+	"5/6": {13152, 13320, 12},
+	"8/9": {14232, 14400, 12},
+}
+
+func getCodeParams(rate string, short bool) (codeParams, bool) {
+	if short {
+		p, ok := shortCodes[rate]
+		return p, ok
+	}
+
+	p, ok := normalCodes[rate]
+	return p, ok
+}
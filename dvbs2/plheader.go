@@ -0,0 +1,266 @@
+package dvbs2
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+const sofBits = 0x18D2E82
+const sofLength = 26
+const plsLength = 64
+const headerLength = sofLength + plsLength
+
+const slotLength = 90
+const pilotBlockLength = 36
+const pilotPeriod = 16 // Slots between pilot blocks
+const dummySlots = 36
+
+// PLSCODE scrambling sequence, EN 302 307 5.5.2.4
+const plsScrambling = 0x719D83C953422DFA
+
+// Reed Muller generator of the PLSCODE, one row for each of the first 6 PLS bits
+var plsGenerator = [6]uint32{0x55555555, 0x33333333, 0x0F0F0F0F, 0x00FF00FF, 0x0000FFFF, 0xFFFFFFFF}
+
+// PLS is the PL signalling of the PLHEADER
+type PLS struct {
+	ModCod int
+	Short  bool
+	Pilots bool
+}
+
+func plsFromCode(code int) PLS {
+	return PLS{
+		ModCod: code >> 2,
+		Short:  code&2 != 0,
+		Pilots: code&1 != 0,
+	}
+}
+
+// Code returns the 7 bits of MODCOD and TYPE
+func (p PLS) Code() int {
+	code := p.ModCod << 2
+	if p.Short {
+		code |= 2
+	}
+	if p.Pilots {
+		code |= 1
+	}
+
+	return code
+}
+
+func (p PLS) String() string {
+	size := "normal"
+	if p.Short {
+		size = "short"
+	}
+
+	if p.ModCod == ModCodDummy {
+		return "Dummy"
+	}
+
+	m, ok := GetModCod(p.ModCod)
+	if !ok {
+		return fmt.Sprintf("Reserved MODCOD %d", p.ModCod)
+	}
+
+	if p.Pilots {
+		return fmt.Sprintf("%s %s pilots", m, size)
+	}
+
+	return fmt.Sprintf("%s %s", m, size)
+}
+
+// Valid is false for the reserved MODCODs
+func (p PLS) Valid() bool {
+	_, ok := GetModCod(p.ModCod)
+	return ok || p.ModCod == ModCodDummy
+}
+
+// Slots is the number of 90 symbols slots after the PLHEADER
+func (p PLS) Slots() int {
+	if p.ModCod == ModCodDummy {
+		return dummySlots
+	}
+
+	m, _ := GetModCod(p.ModCod)
+	bits := normalFrameBits
+	if p.Short {
+		bits = shortFrameBits
+	}
+
+	return bits / m.Modulation.BitsPerSymbol() / slotLength
+}
+
+// PilotBlocks is the number of pilot blocks in the frame
+func (p PLS) PilotBlocks() int {
+	if !p.Pilots || p.ModCod == ModCodDummy {
+		return 0
+	}
+
+	return (p.Slots() - 1) / pilotPeriod
+}
+
+// FrameLength is the PLFRAME length in symbols including the PLHEADER
+func (p PLS) FrameLength() int {
+	return headerLength + p.Slots()*slotLength + p.PilotBlocks()*pilotBlockLength
+}
+
+// encodePLS returns the 64 scrambled PLSCODE bits, first transmitted bit first
+func encodePLS(code int) [plsLength]byte {
+	y := uint32(0)
+	for i := 0; i < 6; i++ {
+		if code&(0x40>>uint(i)) != 0 {
+			y ^= plsGenerator[i]
+		}
+	}
+
+	var bits [plsLength]byte
+	for i := 0; i < 32; i++ {
+		b := byte(y>>uint(31-i)) & 1
+		bits[2*i] = b
+		bits[2*i+1] = b ^ byte(code&1)
+	}
+
+	for i := 0; i < plsLength; i++ {
+		bits[i] ^= byte(uint64(plsScrambling)>>uint(63-i)) & 1
+	}
+
+	return bits
+}
+
+// pi/2 BPSK mapping of the PLHEADER, EN 302 307 5.5.2.5
+func mapHeaderBit(i int, b byte) complex64 {
+	v := float32(math.Sqrt2 / 2)
+	if b != 0 {
+		v = -v
+	}
+
+	if i%2 == 0 {
+		return complex(v, v)
+	}
+
+	return complex(-v, v)
+}
+
+var sofSymbols [sofLength]complex64
+
+// Reference PLHEADER for each PLS code
+var headerSymbols [128][headerLength]complex64
+
+func init() {
+	for i := 0; i < sofLength; i++ {
+		sofSymbols[i] = mapHeaderBit(i, byte(uint32(sofBits)>>uint(sofLength-1-i))&1)
+	}
+
+	for code := 0; code < 128; code++ {
+		copy(headerSymbols[code][:], sofSymbols[:])
+		bits := encodePLS(code)
+		for i, b := range bits {
+			headerSymbols[code][sofLength+i] = mapHeaderBit(sofLength+i, b)
+		}
+	}
+}
+
+// sofCorrelation is the normalized differential correlation of the SOF starting at symbols[0]. It doesn't depend
+// on the carrier phase and tolerates frequency offsets. The phase of the result is the rotation per symbol.
+func sofCorrelation(symbols []complex64) (float64, complex128) {
+	var c complex128
+	power := float64(0)
+
+	for i := 1; i < sofLength; i++ {
+		d := complex128(symbols[i] * conj(symbols[i-1]))
+		ref := complex128(sofSymbols[i] * conj(sofSymbols[i-1]))
+		c += d * complex(real(ref), -imag(ref))
+		power += cabs(d)
+	}
+
+	if power == 0 {
+		return 0, 0
+	}
+
+	return cabs(c) / power, c
+}
+
+// Candidates of the differential PLS decoding checked with the coherent correlation
+const plsCandidates = 16
+
+// decodePLS decodes the PLSCODE of the header. The differential correlation doesn't need the carrier but it can't
+// tell apart codes that differ by complemented runs of bits, like the FECFRAME size bit that complements the whole
+// PLSCODE. So the best differential candidates are compared by the coherent correlation with the whole header.
+// The returned metric is the normalized differential correlation. hint is also checked when it's not negative,
+// usually the code of the previous frame.
+func decodePLS(header []complex64, hint int) (PLS, float64) {
+	var diff [headerLength]complex128
+	power := float64(0)
+	for i := sofLength; i < headerLength; i++ {
+		diff[i] = complex128(header[i] * conj(header[i-1]))
+		power += cabs(diff[i])
+	}
+
+	var metrics [128]float64
+	for code := 0; code < 128; code++ {
+		ref := &headerSymbols[code]
+		for i := sofLength; i < headerLength; i++ {
+			r := complex128(ref[i] * conj(ref[i-1]))
+			metrics[code] += real(diff[i])*real(r) + imag(diff[i])*imag(r)
+		}
+	}
+
+	codes := make([]int, 128)
+	for i := range codes {
+		codes[i] = i
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return metrics[codes[i]] > metrics[codes[j]]
+	})
+
+	candidates := codes[:plsCandidates]
+	if hint >= 0 {
+		candidates = append([]int{hint}, candidates...)
+	}
+
+	best := codes[0]
+	bestCorrelation := float64(-1)
+	for _, code := range candidates {
+		if c := headerCorrelation(header, code); c > bestCorrelation {
+			best = code
+			bestCorrelation = c
+		}
+	}
+
+	if power == 0 {
+		return plsFromCode(best), 0
+	}
+
+	return plsFromCode(best), metrics[codes[0]] / power
+}
+
+// headerCorrelation is the magnitude of the correlation with the header of the PLS code, after removing the
+// frequency offset
+func headerCorrelation(header []complex64, code int) float64 {
+	ref := &headerSymbols[code]
+	z := make([]complex128, headerLength)
+	for i := range z {
+		z[i] = complex128(header[i] * conj(ref[i]))
+	}
+
+	w := estimateFrequency(z)
+
+	var c complex128
+	for i, v := range z {
+		c += v * cmplx.Exp(complex(0, -w*float64(i)))
+	}
+
+	return cabs(c)
+}
+
+func conj(c complex64) complex64 {
+	return complex(real(c), -imag(c))
+}
+
+func cabs(c complex128) float64 {
+	return math.Hypot(real(c), imag(c))
+}
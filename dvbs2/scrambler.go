@@ -0,0 +1,78 @@
+package dvbs2
+
+const goldPeriod = 1<<18 - 1
+
+// Longest PLFRAME after the header: QPSK normal frame with pilots
+const maxScrambledLength = 360*slotLength + 22*pilotBlockLength
+
+var goldX []byte
+var goldY []byte
+
+func init() {
+	goldX = make([]byte, goldPeriod)
+	goldY = make([]byte, goldPeriod)
+
+	goldX[0] = 1
+	for i := 0; i < 18; i++ {
+		goldY[i] = 1
+	}
+
+	for i := 0; i+18 < goldPeriod; i++ {
+		goldX[i+18] = goldX[i+7] ^ goldX[i]
+		goldY[i+18] = goldY[i+10] ^ goldY[i+7] ^ goldY[i+5] ^ goldY[i]
+	}
+}
+
+// plScrambling returns the R sequence of the PL scrambling code n, EN 302 307 5.5.4.
+// Each symbol after the PLHEADER is multiplied by j^R.
+func plScrambling(n int) []byte {
+	z := func(i int) byte {
+		return goldX[(i+n)%goldPeriod] ^ goldY[i]
+	}
+
+	r := make([]byte, maxScrambledLength)
+	for i := range r {
+		r[i] = 2*z((i+131072)%goldPeriod) + z(i)
+	}
+
+	return r
+}
+
+// descramble multiplies the symbol by j^-r
+func descramble(c complex64, r byte) complex64 {
+	switch r & 3 {
+	case 1:
+		return complex(imag(c), -real(c))
+	case 2:
+		return -c
+	case 3:
+		return complex(-imag(c), real(c))
+	}
+
+	return c
+}
+
+// Energy dispersal of the BBFRAME, 1 + X^14 + X^15 initialized with 100101010000000 (EN 302 307 5.2.2)
+var bbScramblingLut []byte
+
+func init() {
+	bbScramblingLut = make([]byte, normalCodes["9/10"].kbch/8)
+
+	st := uint16(169)
+
+	for i := range bbScramblingLut {
+		out := byte(0)
+		for n := 0; n < 8; n++ {
+			bit := ((uint(st) >> 13) ^ (uint(st) >> 14)) & 1
+			out = byte((uint(out) << 1) | bit) // MSB first
+			st = uint16((uint(st) << 1) | bit) // Feedback
+		}
+		bbScramblingLut[i] = out
+	}
+}
+
+func bbDescramble(frame []byte) {
+	for i := range frame {
+		frame[i] ^= bbScramblingLut[i]
+	}
+}
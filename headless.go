@@ -0,0 +1,90 @@
+//go:build headless
+// +build headless
+
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	cfg, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+
+	frontend, receivers, recorder, err := setupReceivers(cfg)
+	if err != nil {
+		log.Fatalf("Cannot create receiver: %s", err)
+	}
+	defer closeReceivers(receivers)
+	defer recorder.Close()
+
+	var collectors []prometheus.Collector
+
+	file := fileFrontend(frontend)
+	if file != nil {
+		collectors = append(collectors, playbackCollector{frontend: file})
+	}
+
+	network := networkFrontend(frontend)
+	if network != nil {
+		collectors = append(collectors, networkCollector{frontend: network})
+	}
+
+	if cfg.Metrics != "" {
+		l, err := startMetrics(cfg.Metrics, receivers, collectors...)
+		if err != nil {
+			log.Fatalf("Cannot start metrics server: %s", err)
+		}
+		defer l.Close()
+	}
+
+	exitC := make(chan os.Signal, 1)
+	signal.Notify(exitC, os.Interrupt, syscall.SIGTERM)
+
+	statsTicker := time.NewTicker(cfg.StatsInterval.Duration)
+	defer statsTicker.Stop()
+
+	started := time.Now()
+	frontend.Start()
+	for _, rx := range receivers {
+		rx.Start(context.Background())
+	}
+	eof := endOfInput(frontend)
+
+	if file != nil && cfg.Controls {
+		go readPlaybackCommands(os.Stdin, file)
+	}
+
+	for {
+		select {
+		case <-exitC:
+			log.Println("Got SIGTERM!")
+			frontend.Stop()
+			logStats(receivers)
+			return
+		case <-eof:
+			drainReceivers(frontend, receivers, started)
+			return
+		case <-statsTicker.C:
+			if file != nil {
+				log.Printf("Playback: %s, %s", playbackStatus(file), pacingStatus(file))
+			}
+			if network != nil {
+				log.Printf("Network input: %s", networkStatus(network))
+			}
+			logStats(receivers)
+		}
+	}
+}
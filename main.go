@@ -1,87 +1,39 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
-	"github.com/OpenSatelliteProject/libsathelper"
+	"context"
+	"flag"
 	"github.com/go-gl/gl/v3.2-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/golang-ui/nuklear/nk"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/racerxdl/go.fifo"
-	"github.com/racerxdl/kissdvb/Frontend"
-	"github.com/racerxdl/kissdvb/Frontend/CFileFrontend"
-	"github.com/racerxdl/segdsp/dsp"
+	"github.com/racerxdl/kissdvb/receiver"
 	"log"
 	"os"
 	"os/signal"
 	"runtime"
-	"sync"
 	"syscall"
 	"time"
 )
 
-const PllAlpha float32 = 0.0001
-
-const ClockAlpha float32 = 0.05
-const ClockMu float32 = 0.5
-const ClockOmegaLimit float32 = 0.005
-const ClockGainOmega = (ClockAlpha * ClockAlpha) / 4.0
-
-var filter *dsp.FirFilter
-var costasNew dsp.CostasLoop
-
-var buffer0 []complex64
-var buffer1 []complex64
-
-var mmOld SatHelper.ClockRecovery
-
-var videoPlayer = MakeVideoPlayer()
-
-func checkAndResizeBuffers(length int) {
-	if len(buffer0) < length {
-		buffer0 = make([]complex64, length)
-	}
-	if len(buffer1) < length {
-		buffer1 = make([]complex64, length)
-	}
-}
-
-func swapBuffers(a *[]complex64, b *[]complex64) {
-	c := *b
-	*b = *a
-	*a = c
-}
+var videoPlayer *VideoPlayer
+var rx *receiver.Receiver
 
-var lock = sync.Mutex{}
 var lastConstellationUpdate time.Time
 
 var constellationSymbolFifo *fifo.Queue
 
-func sampleLoop(data []complex64) {
-	lock.Lock()
-	defer lock.Unlock()
-
-	checkAndResizeBuffers(len(data))
-
-	copy(buffer0, data)
-
-	ba := buffer0
-	bb := buffer1
-
-	s := filter.WorkBuffer(ba, bb)
-	swapBuffers(&ba, &bb)
-
-	s = costasNew.WorkBuffer(ba, bb)
-	swapBuffers(&ba, &bb)
-
-	//s = mmNew.WorkBuffer(ba, bb)
-	s = mmOld.Work(&ba[0], &bb[0], s)
-	swapBuffers(&ba, &bb)
-
+func putConstellationSymbols(symbols []complex64) {
 	constellationSymbolFifo.UnsafeLock()
-	for i := 0; i < s; i++ {
+	for i := 0; i < len(symbols); i++ {
 		if constellationSymbolFifo.UnsafeLen() > 2048 {
 			break
 		}
-		constellationSymbolFifo.UnsafeAdd(ba[i])
+		constellationSymbolFifo.UnsafeAdd(symbols[i])
 	}
 	constellationSymbolFifo.UnsafeUnlock()
 
@@ -89,33 +41,55 @@ func sampleLoop(data []complex64) {
 		go UpdateConstellation()
 		lastConstellationUpdate = time.Now()
 	}
-
-	DecodePut(ba[:s])
 }
 
 func main() {
-	rsErrors.Store(int(0))
-	packetCount.Store(int(0))
-	frontend := CFileFrontend.NewCFileFrontend("/media/ELTN/Baseband Records/DVB-S/dvbs-2e6.cfile")
-	frontend.SetSampleRate(2e6)
-
-	sampleRate := 2e6
-	symbolRate := 1e6
-	sps := sampleRate / symbolRate
-
-	rrcTaps := dsp.MakeRRC(1, sampleRate, symbolRate, 0.35, 15)
-	filter = dsp.MakeFirFilter(rrcTaps)
-	costasNew = dsp.MakeCostasLoop4(PllAlpha)
-
-	//mmOld = SatHelper.NewClockRecovery(float32(sps), 0.25*0.175*0.175, 0.5, 0.175, 0.005)
-	mmOld = SatHelper.NewClockRecovery(float32(sps), ClockGainOmega, ClockMu, ClockAlpha, ClockOmegaLimit)
-	//mmNew = digital.NewComplexClockRecovery(float32(sps), 0.25*0.175*0.175, 0.5, 0.175, 0.005)
+	cfg, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+
+	frontend, receivers, recorder, err := setupReceivers(cfg)
+	if err != nil {
+		log.Fatalf("Cannot create receiver: %s", err)
+	}
+	defer closeReceivers(receivers)
+	defer recorder.Close()
+
+	// The UI shows the first channel
+	rx = receivers[0]
+	playback = fileFrontend(frontend)
+
+	var collectors []prometheus.Collector
+
+	if cfg.Video {
+		videoPlayer = MakeVideoPlayer()
+		rx.AddTSSink(videoPlayer)
+		collectors = append(collectors, videoCollector{vp: videoPlayer})
+	}
+
+	if playback != nil {
+		collectors = append(collectors, playbackCollector{frontend: playback})
+	}
+
+	if network := networkFrontend(frontend); network != nil {
+		collectors = append(collectors, networkCollector{frontend: network})
+	}
+
+	if cfg.Metrics != "" {
+		l, err := startMetrics(cfg.Metrics, receivers, collectors...)
+		if err != nil {
+			log.Fatalf("Cannot start metrics server: %s", err)
+		}
+		defer l.Close()
+	}
+
 	lastConstellationUpdate = time.Now()
 	constellationSymbolFifo = fifo.NewQueue()
-
-	frontend.SetSamplesAvailableCallback(func(data Frontend.SampleCallbackData) {
-		sampleLoop(data.ComplexArray)
-	})
+	rx.SetSymbolsCallback(putConstellationSymbols)
 
 	runtime.LockOSThread()
 	if err := glfw.Init(); err != nil {
@@ -145,10 +119,16 @@ func main() {
 
 	signal.Notify(exitC, os.Interrupt, syscall.SIGTERM)
 
+	started := time.Now()
+
 	go func() {
-		<-exitC
-		log.Println("Got SIGTERM!")
-		frontend.Stop()
+		select {
+		case <-exitC:
+			log.Println("Got SIGTERM!")
+			frontend.Stop()
+		case <-endOfInput(frontend):
+			drainReceivers(frontend, receivers, started)
+		}
 		win.SetShouldClose(true)
 		<-doneC
 	}()
@@ -161,9 +141,13 @@ func main() {
 	nk.NkStyleSetFont(ctx, fonts["sans16"].Handle())
 
 	frontend.Start()
-	videoPlayer.Start()
+	if videoPlayer != nil {
+		videoPlayer.Start()
+	}
 
-	go DecodeLoop()
+	for _, r := range receivers {
+		r.Start(context.Background())
+	}
 
 	for {
 		select {
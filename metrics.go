@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/racerxdl/kissdvb/receiver"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// startMetrics serves the receivers statistics and the extra collectors at http://addr/metrics
+func startMetrics(addr string, receivers []*receiver.Receiver, collectors ...prometheus.Collector) (net.Listener, error) {
+	registry := prometheus.NewRegistry()
+
+	rxCollector := receiver.MakeMetricsCollector()
+	for i, rx := range receivers {
+		rxCollector.AddReceiver(strconv.Itoa(i), rx)
+	}
+
+	if err := registry.Register(rxCollector); err != nil {
+		return nil, err
+	}
+
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		// Closing the listener is the normal way to stop
+		if err := http.Serve(l, mux); !errors.Is(err, net.ErrClosed) {
+			log.Printf("Metrics server stopped: %s", err)
+		}
+	}()
+
+	log.Printf("Serving metrics at http://%s/metrics", l.Addr())
+
+	return l, nil
+}
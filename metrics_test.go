@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/kissdvb/Frontend/NetworkFrontend"
+	"github.com/racerxdl/kissdvb/receiver"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsScrape(t *testing.T) {
+	cfg := receiver.DefaultConfig()
+	cfg.Viterbi = receiver.ViterbiGo
+	rx, err := receiver.MakeReceiver(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+
+	extra := prometheus.NewCounter(prometheus.CounterOpts{Name: "kissdvb_test_total", Help: "Test counter"})
+	extra.Add(3)
+
+	network, err := NetworkFrontend.NewNetworkFrontend("udp", "127.0.0.1:0", Frontend.FormatCF32, binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &bytes.Buffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	l, err := startMetrics("127.0.0.1:0", []*receiver.Receiver{rx}, extra, networkCollector{frontend: network})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Get("http://" + l.Addr().String() + "/metrics")
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+
+	for _, metric := range []string{`kissdvb_locked{channel="0"} 0`, `kissdvb_packets_total{channel="0"} 0`, "kissdvb_test_total 3", "kissdvb_network_lost_packets_total 0"} {
+		if !strings.Contains(string(body), metric) {
+			t.Errorf("metric %s not scraped", metric)
+		}
+	}
+
+	// Closing the listener stops the server without an error
+	l.Close()
+	time.Sleep(50 * time.Millisecond)
+	if strings.Contains(logs.String(), "stopped") {
+		t.Errorf("closing logged %q", logs.String())
+	}
+}
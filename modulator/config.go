@@ -0,0 +1,76 @@
+package modulator
+
+import (
+	"flag"
+	"fmt"
+	"github.com/racerxdl/kissdvb/dvbs"
+	"math"
+)
+
+type Config struct {
+	SampleRate float64 `json:"sampleRate"`
+	SymbolRate float64 `json:"symbolRate"`
+	RollOff    float64 `json:"rollOff"`
+	RRCTaps    int     `json:"rrcTaps"`
+	CodeRate   string  `json:"codeRate"`
+	Level      float64 `json:"level"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SampleRate: 2e6,
+		SymbolRate: 1e6,
+		RollOff:    0.35,
+		RRCTaps:    65,
+		CodeRate:   dvbs.CodeRate1_2.String(),
+		Level:      0.5,
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.SampleRate, "samplerate", c.SampleRate, "Output sample rate in Hz")
+	fs.Float64Var(&c.SymbolRate, "symbolrate", c.SymbolRate, "Symbol rate in Hz")
+	fs.Float64Var(&c.RollOff, "rolloff", c.RollOff, "RRC filter roll-off")
+	fs.IntVar(&c.RRCTaps, "rrctaps", c.RRCTaps, "RRC filter number of taps")
+	fs.StringVar(&c.CodeRate, "coderate", c.CodeRate, "Inner code rate: 1/2, 2/3, 3/4, 5/6 or 7/8")
+	fs.Float64Var(&c.Level, "level", c.Level, "Output RMS level")
+}
+
+// SamplesPerSymbol returns the integer interpolation factor
+func (c *Config) SamplesPerSymbol() int {
+	return int(math.Round(c.SampleRate / c.SymbolRate))
+}
+
+func (c *Config) Validate() error {
+	if c.SampleRate <= 0 || c.SymbolRate <= 0 {
+		return fmt.Errorf("invalid sample rate %f or symbol rate %f", c.SampleRate, c.SymbolRate)
+	}
+
+	sps := c.SampleRate / c.SymbolRate
+	if sps < 2 || math.Abs(sps-math.Round(sps)) > 1e-9 {
+		return fmt.Errorf("sample rate should be an integer multiple (at least 2) of the symbol rate, got %f", sps)
+	}
+
+	if c.RollOff <= 0 || c.RollOff > 1 {
+		return fmt.Errorf("roll-off should be in (0, 1], got %f", c.RollOff)
+	}
+
+	if c.RRCTaps < 1 {
+		return fmt.Errorf("invalid number of RRC taps %d", c.RRCTaps)
+	}
+
+	cr, err := dvbs.ParseCodeRate(c.CodeRate)
+	if err != nil {
+		return err
+	}
+
+	if cr == dvbs.CodeRateAuto {
+		return fmt.Errorf("the modulator needs a fixed code rate")
+	}
+
+	if c.Level <= 0 {
+		return fmt.Errorf("invalid output level %f", c.Level)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package modulator
+
+// https://www.etsi.org/deliver/etsi_en/300400_300499/300421/01.01.02_60/en_300421v010102p.pdf page 10
+
+// Interleaver is the convolutional (Forney) interleaver, I = 12 branches with M = 17 bytes per delay step.
+// It is the inverse of receiver.Deinterleaver.
+type Interleaver struct {
+	I int
+	M int
+
+	branches  [][]byte
+	positions []int
+	comutator int
+}
+
+func MakeInterleaver() *Interleaver {
+	I := 12
+	M := 17
+
+	branches := make([][]byte, I)
+	for i := 0; i < I; i++ {
+		branches[i] = make([]byte, M*i) // Branch i delays M * i bytes, starts with zeros
+	}
+
+	return &Interleaver{
+		I:         I,
+		M:         M,
+		branches:  branches,
+		positions: make([]int, I),
+	}
+}
+
+// PutData interleaves d in place
+func (il *Interleaver) PutData(d []byte) {
+	for i := 0; i < len(d); i++ {
+		branch := il.branches[il.comutator]
+
+		if len(branch) > 0 {
+			pos := il.positions[il.comutator]
+			b := branch[pos]
+			branch[pos] = d[i]
+			d[i] = b
+			il.positions[il.comutator] = (pos + 1) % len(branch)
+		}
+
+		il.comutator = (il.comutator + 1) % il.I
+	}
+}
@@ -0,0 +1,213 @@
+package modulator
+
+import (
+	"encoding/binary"
+	"github.com/racerxdl/kissdvb/dvbs"
+	"github.com/racerxdl/kissdvb/viterbi"
+	"github.com/racerxdl/segdsp/dsp"
+	"io"
+	"math"
+	"sync"
+)
+
+const tsPacketSize = 188
+const dvbsPacketSize = 204
+
+// Energy dispersal restarts every 8 packets
+const randomizerPackets = dvbs.ScanPackets
+
+var nullPacket = func() []byte {
+	p := make([]byte, tsPacketSize)
+	p[0] = 0x47
+	p[1] = 0x1F
+	p[2] = 0xFF
+	p[3] = 0x10
+	for i := 4; i < tsPacketSize; i++ {
+		p[i] = 0xFF
+	}
+	return p
+}()
+
+// Modulator is a DVB-S transmitter: energy dispersal, RS(204,188), interleaving, convolutional code with puncturing,
+// QPSK mapping and RRC shaping. The complex64 samples are written to out, in the same format CFileFrontend reads.
+type Modulator struct {
+	sync.Mutex
+	cfg      Config
+	codeRate dvbs.CodeRate
+	out      io.Writer
+
+	group       []byte
+	rs          *reedSolomonEncoder
+	interleaver *Interleaver
+	encoder     *viterbi.Encoder27
+	phase       int
+	pendingBit  int
+
+	packetBuffer  []byte
+	motherBits    []byte
+	puncturedBits []byte
+	symbols       []complex64
+	history       []complex64
+	taps          []float32
+	sps           int
+	samples       []complex64
+
+	packets int64
+	err     error
+}
+
+func MakeModulator(cfg Config, out io.Writer) (*Modulator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	codeRate, _ := dvbs.ParseCodeRate(cfg.CodeRate)
+	sps := cfg.SamplesPerSymbol()
+	taps := dsp.MakeRRC(1, cfg.SampleRate, cfg.SymbolRate, cfg.RollOff, cfg.RRCTaps)
+
+	// Unit energy taps times sqrt(sps) give the same average power as the symbols
+	energy := float64(0)
+	for _, t := range taps {
+		energy += float64(t) * float64(t)
+	}
+	scale := float32(math.Sqrt(float64(sps)/energy) * cfg.Level)
+	for i := range taps {
+		taps[i] *= scale
+	}
+
+	return &Modulator{
+		cfg:           cfg,
+		codeRate:      codeRate,
+		out:           out,
+		group:         make([]byte, 0, tsPacketSize*randomizerPackets),
+		rs:            makeReedSolomonEncoder(),
+		interleaver:   MakeInterleaver(),
+		encoder:       viterbi.MakeEncoder27(),
+		pendingBit:    -1,
+		packetBuffer:  make([]byte, dvbsPacketSize),
+		motherBits:    make([]byte, dvbsPacketSize*16),
+		puncturedBits: make([]byte, dvbsPacketSize*16),
+		history:       make([]complex64, (len(taps)+sps-1)/sps),
+		taps:          taps,
+		sps:           sps,
+	}, nil
+}
+
+// PutTSFrame adds a 188 bytes transport stream packet. Packets are modulated in groups of 8.
+func (m *Modulator) PutTSFrame(ts []byte) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.group = append(m.group, ts...)
+	if len(m.group) == cap(m.group) {
+		m.modulateGroup()
+	}
+}
+
+// Flush completes the current group with null packets and pushes the interleaver content out,
+// so all the packets received so far can be decoded from the output. Returns the first write error.
+func (m *Modulator) Flush() error {
+	m.Lock()
+	defer m.Unlock()
+
+	for i := 0; i < 2; i++ {
+		for len(m.group) < cap(m.group) {
+			m.group = append(m.group, nullPacket...)
+		}
+		m.modulateGroup()
+	}
+
+	return m.err
+}
+
+// GetPackets returns how many packets were modulated, including null packets added by Flush
+func (m *Modulator) GetPackets() int64 {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.packets
+}
+
+func (m *Modulator) modulateGroup() {
+	// Energy dispersal is a xor with the same sequence, so DeRandomize also randomizes
+	dvbs.DeRandomize(m.group)
+
+	for i := 0; i < randomizerPackets; i++ {
+		m.rs.Encode(m.group[i*tsPacketSize:(i+1)*tsPacketSize], m.packetBuffer)
+		m.interleaver.PutData(m.packetBuffer)
+		m.modulatePacket(m.packetBuffer)
+		m.packets++
+	}
+
+	m.group = m.group[:0]
+}
+
+func (m *Modulator) modulatePacket(packet []byte) {
+	m.encoder.Encode(packet, m.motherBits)
+	n := dvbs.Puncture(m.codeRate, m.phase, m.motherBits, m.puncturedBits)
+	m.phase = (m.phase + len(packet)*8) % m.codeRate.Period()
+
+	bits := m.puncturedBits[:n]
+	m.symbols = m.symbols[:0]
+
+	// Transmitted bits alternate between I and Q, a punctured block can end in the middle of a symbol
+	if m.pendingBit != -1 && len(bits) > 0 {
+		m.symbols = append(m.symbols, mapQPSK(byte(m.pendingBit), bits[0]))
+		bits = bits[1:]
+		m.pendingBit = -1
+	}
+
+	for i := 0; i+1 < len(bits); i += 2 {
+		m.symbols = append(m.symbols, mapQPSK(bits[i], bits[i+1]))
+	}
+
+	if len(bits)%2 == 1 {
+		m.pendingBit = int(bits[len(bits)-1])
+	}
+
+	m.shape(m.symbols)
+}
+
+// Gray mapping from EN 300 421 Figure 5
+func mapQPSK(i, q byte) complex64 {
+	const a = float32(math.Sqrt2 / 2)
+	re := a
+	im := a
+	if i == 1 {
+		re = -a
+	}
+	if q == 1 {
+		im = -a
+	}
+
+	return complex(re, im)
+}
+
+// shape interpolates the symbols by sps with the RRC filter and writes the samples
+func (m *Modulator) shape(symbols []complex64) {
+	m.samples = m.samples[:0]
+	nh := len(m.history)
+
+	for _, s := range symbols {
+		copy(m.history[1:], m.history[:nh-1])
+		m.history[0] = s
+
+		for k := 0; k < m.sps; k++ {
+			re := float32(0)
+			im := float32(0)
+			for j := 0; j < nh; j++ {
+				t := k + j*m.sps
+				if t >= len(m.taps) {
+					break
+				}
+				re += m.taps[t] * real(m.history[j])
+				im += m.taps[t] * imag(m.history[j])
+			}
+			m.samples = append(m.samples, complex(re, im))
+		}
+	}
+
+	if m.err == nil {
+		m.err = binary.Write(m.out, binary.LittleEndian, m.samples)
+	}
+}
@@ -0,0 +1,176 @@
+package modulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/racerxdl/kissdvb/dvbs"
+	"github.com/racerxdl/kissdvb/viterbi"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func makeTestPackets(n int, rng *rand.Rand) []byte {
+	data := make([]byte, n*tsPacketSize)
+	rng.Read(data)
+	for i := 0; i < len(data); i += tsPacketSize {
+		data[i] = 0x47
+	}
+	return data
+}
+
+func TestReedSolomon(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	rs := makeReedSolomonEncoder()
+	packet := makeTestPackets(1, rng)
+	codeword := make([]byte, dvbsPacketSize)
+	rs.Encode(packet, codeword)
+
+	if !bytes.Equal(codeword[:tsPacketSize], packet) {
+		t.Fatal("the code isn't systematic")
+	}
+
+	// The codeword is a multiple of the generator, it is zero at its roots λ^0..λ^15
+	for i := 0; i < rsParityBytes; i++ {
+		v := byte(0)
+		for _, c := range codeword {
+			v = gfMul(v, gfExp[i]) ^ c
+		}
+		if v != 0 {
+			t.Errorf("syndrome %d is %02x", i, v)
+		}
+	}
+}
+
+func TestInterleaver(t *testing.T) {
+	const numPackets = 20
+
+	rng := rand.New(rand.NewSource(2))
+	input := make([]byte, numPackets*dvbsPacketSize)
+	rng.Read(input)
+
+	output := append([]byte{}, input...)
+	il := MakeInterleaver()
+	for i := 0; i < numPackets; i++ {
+		il.PutData(output[i*dvbsPacketSize : (i+1)*dvbsPacketSize])
+	}
+
+	// The byte of branch b comes out b * M * I bytes later, the sync bytes are on branch 0
+	for n, b := range input {
+		delay := n % il.I * il.M * il.I
+		if n+delay < len(output) && output[n+delay] != b {
+			t.Fatalf("byte %d not found %d bytes later", n, delay)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func(c *Config){
+		func(c *Config) { c.CodeRate = "auto" },
+		func(c *Config) { c.CodeRate = "4/5" },
+		func(c *Config) { c.SampleRate = 1.5e6 },
+		func(c *Config) { c.SampleRate = 2.5e6 },
+		func(c *Config) { c.RollOff = 0 },
+		func(c *Config) { c.Level = 0 },
+	} {
+		c := DefaultConfig()
+		change(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("invalid config %+v accepted", c)
+		}
+	}
+}
+
+// expectedSymbols returns the QPSK symbols of the packets: energy dispersal, RS, interleaving, the convolutional
+// code over the whole stream and the puncturing from phase 0
+func expectedSymbols(data []byte, rate dvbs.CodeRate) []complex64 {
+	data = append([]byte{}, data...)
+	rs := makeReedSolomonEncoder()
+	il := MakeInterleaver()
+
+	coded := make([]byte, 0)
+	packet := make([]byte, dvbsPacketSize)
+	for g := 0; g < len(data); g += tsPacketSize * randomizerPackets {
+		dvbs.DeRandomize(data[g : g+tsPacketSize*randomizerPackets])
+		for i := g; i < g+tsPacketSize*randomizerPackets; i += tsPacketSize {
+			rs.Encode(data[i:i+tsPacketSize], packet)
+			il.PutData(packet)
+			coded = append(coded, packet...)
+		}
+	}
+
+	mother := make([]byte, len(coded)*16)
+	viterbi.Encode27(coded, mother)
+	punctured := make([]byte, len(mother))
+	n := dvbs.Puncture(rate, 0, mother, punctured)
+
+	symbols := make([]complex64, n/2)
+	for i := range symbols {
+		symbols[i] = mapQPSK(punctured[2*i], punctured[2*i+1])
+	}
+	return symbols
+}
+
+func TestModulator(t *testing.T) {
+	// The punctured bits of a packet are odd with 5/6 and 7/8, the symbols span packets
+	for _, rate := range []dvbs.CodeRate{dvbs.CodeRate1_2, dvbs.CodeRate5_6, dvbs.CodeRate7_8} {
+		cfg := DefaultConfig()
+		cfg.CodeRate = rate.String()
+		cfg.RRCTaps = 1 // The samples at the symbol instants are the symbols
+
+		out := &bytes.Buffer{}
+		m, err := MakeModulator(cfg, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rng := rand.New(rand.NewSource(int64(rate)))
+		data := makeTestPackets(2*randomizerPackets+3, rng)
+		for i := 0; i < len(data); i += tsPacketSize {
+			m.PutTSFrame(data[i : i+tsPacketSize])
+		}
+		if err := m.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Flush completes the group with null packets and adds a group of them
+		if m.GetPackets() != 4*randomizerPackets {
+			t.Fatalf("rate %s: %d packets modulated", rate, m.GetPackets())
+		}
+		for len(data) < 4*randomizerPackets*tsPacketSize {
+			data = append(data, nullPacket...)
+		}
+
+		samples := make([]complex64, out.Len()/8)
+		if err := binary.Read(out, binary.LittleEndian, samples); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := expectedSymbols(data, rate)
+		if len(samples) != len(expected)*m.sps {
+			t.Fatalf("rate %s: %d samples for %d symbols", rate, len(samples), len(expected))
+		}
+
+		scale := complex(float32(math.Sqrt(float64(m.sps))*cfg.Level), 0)
+		power := float64(0)
+		for i, s := range expected {
+			got := samples[i*m.sps]
+			if d := got - s*scale; real(d)*real(d)+imag(d)*imag(d) > 1e-9 {
+				t.Fatalf("rate %s: symbol %d is %v, expected %v", rate, i, got, s*scale)
+			}
+			for _, x := range samples[i*m.sps : (i+1)*m.sps] {
+				power += float64(real(x)*real(x) + imag(x)*imag(x))
+			}
+		}
+
+		// The level is the RMS of the samples
+		if rms := math.Sqrt(power / float64(len(samples))); math.Abs(rms-cfg.Level) > 1e-3 {
+			t.Errorf("rate %s: RMS level %g, expected %g", rate, rms, cfg.Level)
+		}
+	}
+}
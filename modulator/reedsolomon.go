@@ -0,0 +1,78 @@
+package modulator
+
+// Shortened RS(204, 188, T=8) from RS(255, 239). EN 300 421 4.4.2:
+// field generator x^8 + x^4 + x^3 + x^2 + 1 and code generator (x+λ^0)(x+λ^1)...(x+λ^15) with λ = 02h.
+
+const rsFieldPoly = 0x11D
+const rsParityBytes = 16
+
+var gfExp [512]byte
+var gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= rsFieldPoly
+		}
+	}
+
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+type reedSolomonEncoder struct {
+	generator []byte // Highest degree first, without the leading 1
+}
+
+func makeReedSolomonEncoder() *reedSolomonEncoder {
+	g := []byte{1}
+
+	for i := 0; i < rsParityBytes; i++ {
+		// g = g * (x + λ^i)
+		next := make([]byte, len(g)+1)
+		for j, c := range g {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, gfExp[i])
+		}
+		g = next
+	}
+
+	return &reedSolomonEncoder{
+		generator: g[1:],
+	}
+}
+
+// Encode writes the 188 bytes packet followed by its 16 parity bytes to out
+func (rs *reedSolomonEncoder) Encode(packet, out []byte) {
+	parity := out[len(packet) : len(packet)+rsParityBytes]
+	for i := range parity {
+		parity[i] = 0
+	}
+
+	for _, b := range packet {
+		feedback := b ^ parity[0]
+		copy(parity, parity[1:])
+		parity[rsParityBytes-1] = 0
+
+		if feedback != 0 {
+			for j, g := range rs.generator {
+				parity[j] ^= gfMul(feedback, g)
+			}
+		}
+	}
+
+	copy(out, packet)
+}
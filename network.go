@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/kissdvb/Frontend/NetworkFrontend"
+)
+
+// networkFrontend returns the frontend when it receives samples from the network, nil otherwise
+func networkFrontend(frontend Frontend.BaseFrontend) *NetworkFrontend.NetworkFrontend {
+	f, _ := frontend.(*NetworkFrontend.NetworkFrontend)
+	return f
+}
+
+// networkStatus returns the UDP datagrams lost by the network input
+func networkStatus(frontend *NetworkFrontend.NetworkFrontend) string {
+	return fmt.Sprintf("%d lost packets", frontend.GetLostPackets())
+}
+
+var metricNetworkLostPackets = prometheus.NewDesc("kissdvb_network_lost_packets_total", "UDP sample datagrams missing from the sequence numbers", nil, nil)
+
+type networkCollector struct {
+	frontend *NetworkFrontend.NetworkFrontend
+}
+
+func (c networkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricNetworkLostPackets
+}
+
+func (c networkCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(metricNetworkLostPackets, prometheus.CounterValue, float64(c.frontend.GetLostPackets()))
+}
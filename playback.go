@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/kissdvb/Frontend/CFileFrontend"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsePosition parses a file position as a time like 1m30s or a sample number. The sample is -1 for times.
+func parsePosition(position string) (time.Duration, int64, error) {
+	if t, err := time.ParseDuration(position); err == nil && t >= 0 {
+		return t, -1, nil
+	}
+
+	sample, err := strconv.ParseInt(position, 10, 64)
+	if err != nil || sample < 0 {
+		return 0, 0, fmt.Errorf("invalid position %q, expected a time like 1m30s or a sample number", position)
+	}
+
+	return 0, sample, nil
+}
+
+func seekTo(frontend *CFileFrontend.CFileFrontend, position string) error {
+	t, sample, err := parsePosition(position)
+	if err != nil {
+		return err
+	}
+
+	if sample >= 0 {
+		frontend.SeekSample(sample)
+	} else {
+		frontend.SeekTime(t)
+	}
+
+	return nil
+}
+
+// setupPlayback applies the playback options to a file frontend
+func setupPlayback(frontend *CFileFrontend.CFileFrontend, cfg *Config) {
+	frontend.SetSpeed(cfg.Speed)
+	frontend.SetLoop(cfg.Loop)
+
+	if cfg.Seek != "" {
+		_ = seekTo(frontend, cfg.Seek)
+	}
+}
+
+// fileFrontend returns the frontend when it plays a file, nil otherwise
+func fileFrontend(frontend Frontend.BaseFrontend) *CFileFrontend.CFileFrontend {
+	f, _ := frontend.(*CFileFrontend.CFileFrontend)
+	return f
+}
+
+func playbackStatus(frontend *CFileFrontend.CFileFrontend) string {
+	state := "playing"
+	if frontend.IsPaused() {
+		state = "paused"
+	}
+	if frontend.IsLooping() {
+		state += ", looping"
+	}
+
+	progress := 0.0
+	if length := frontend.GetLength(); length > 0 {
+		progress = 100 * float64(frontend.GetPosition()) / float64(length)
+	}
+
+	return fmt.Sprintf("%s / %s (%.1f%%) x%g %s", frontend.GetTime().Round(time.Second), frontend.GetDuration().Round(time.Second), progress, frontend.GetSpeed(), state)
+}
+
+// pacingStatus returns the measured throughput and the late reads
+func pacingStatus(frontend *CFileFrontend.CFileFrontend) string {
+	return fmt.Sprintf("%.3f MS/s, %d underruns, %d overruns", frontend.GetThroughput()/1e6, frontend.GetUnderruns(), frontend.GetOverruns())
+}
+
+var (
+	metricPlaybackThroughput = prometheus.NewDesc("kissdvb_playback_throughput_samples", "Measured input file samples per second", nil, nil)
+	metricPlaybackUnderruns  = prometheus.NewDesc("kissdvb_playback_underruns_total", "Input file reads late by more than a buffer", nil, nil)
+	metricPlaybackOverruns   = prometheus.NewDesc("kissdvb_playback_overruns_total", "Input file reads too late to catch up, the playback clock was restarted", nil, nil)
+)
+
+type playbackCollector struct {
+	frontend *CFileFrontend.CFileFrontend
+}
+
+func (c playbackCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricPlaybackThroughput
+	ch <- metricPlaybackUnderruns
+	ch <- metricPlaybackOverruns
+}
+
+func (c playbackCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(metricPlaybackThroughput, prometheus.GaugeValue, c.frontend.GetThroughput())
+	ch <- prometheus.MustNewConstMetric(metricPlaybackUnderruns, prometheus.CounterValue, float64(c.frontend.GetUnderruns()))
+	ch <- prometheus.MustNewConstMetric(metricPlaybackOverruns, prometheus.CounterValue, float64(c.frontend.GetOverruns()))
+}
+
+// readPlaybackCommands drives the playback with the commands read from r, one per line:
+// pause, resume, seek <position>, speed <multiplier>, loop on|off and status
+func readPlaybackCommands(r io.Reader, frontend *CFileFrontend.CFileFrontend) {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		arg := ""
+		if len(fields) > 1 {
+			arg = fields[1]
+		}
+
+		switch fields[0] {
+		case "pause":
+			frontend.Pause()
+		case "resume":
+			frontend.Resume()
+		case "seek":
+			if err := seekTo(frontend, arg); err != nil {
+				log.Println(err)
+				continue
+			}
+		case "speed":
+			speed, err := strconv.ParseFloat(arg, 64)
+			if err != nil || speed <= 0 {
+				log.Printf("Invalid speed %q", arg)
+				continue
+			}
+			frontend.SetSpeed(speed)
+		case "loop":
+			frontend.SetLoop(arg != "off")
+		case "status":
+		default:
+			log.Printf("Unknown command %q, expected pause, resume, seek <position>, speed <multiplier>, loop on|off or status", fields[0])
+			continue
+		}
+
+		log.Printf("Playback: %s, %s", playbackStatus(frontend), pacingStatus(frontend))
+	}
+}
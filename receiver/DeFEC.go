@@ -0,0 +1,413 @@
+package receiver
+
+import (
+	"log"
+	"math/bits"
+	"sync"
+)
+
+const numLastFrameBits = 32
+const numLastFrameBitsInBytes = numLastFrameBits / 8
+
+// Decoded bits past the frame end. They give the Viterbi traceback of the last frame bits some lookahead and are
+// decoded again as the start of the next frame.
+const numLookaheadBits = numLastFrameBits
+
+// How many decoded bits past the expected position are searched for the sync pattern when not locked
+const syncSearchBits = dvbsFrameBits*4 - 64
+
+// Soft bits dropped when no sync was found. It is odd and not a multiple of 3 so the buffer start
+// eventually falls in every position of the puncturing patterns.
+const syncSearchShift = syncSearchBits - 1
+
+type fecHypothesis struct {
+	rate     CodeRate
+	phase    int
+	rotation int
+}
+
+type DeFEC struct {
+	sync.Mutex
+	viterbi27        []ViterbiDecoder
+	codeRate         CodeRate
+	lockedRate       CodeRate
+	hypotheses       []fecHypothesis
+	encodedSize      int
+	encodedBuffer    []byte
+	encodedBufferPos int
+	consumedBits     int64
+	decodedBuffer    [][]byte
+	extraBits        []byte
+	tmpBuffers       [][]byte
+	motherBuffers    [][]byte
+
+	lock        bool
+	lockedFrame int
+	lockedPhase int
+	readyFrame  int
+	bitErrors   []int
+	frameReady  bool
+}
+
+// MakeDeFEC creates a DeFEC for the specified code rate. With CodeRateAuto all code rates are tried during acquisition.
+// newViterbi creates the Viterbi decoder of each hypothesis.
+func MakeDeFEC(codeRate CodeRate, newViterbi ViterbiFactory) *DeFEC {
+	decodedBits := numLastFrameBits + scanBits + numLookaheadBits
+	rates := []CodeRate{codeRate}
+	if codeRate == CodeRateAuto {
+		rates = codeRates
+	}
+
+	encodedSize := 0
+	hypotheses := make([]fecHypothesis, 0)
+
+	for _, rate := range rates {
+		if n := maxPuncturedLength(rate, decodedBits); n > encodedSize {
+			encodedSize = n
+		}
+		for phase := 0; phase < rate.Period(); phase++ {
+			for rotation := 0; rotation < 8; rotation++ {
+				hypotheses = append(hypotheses, fecHypothesis{
+					rate:     rate,
+					phase:    phase,
+					rotation: rotation,
+				})
+			}
+		}
+	}
+
+	numHypotheses := len(hypotheses)
+	viterbi := make([]ViterbiDecoder, numHypotheses)
+	decodedBuffer := make([][]byte, numHypotheses)
+	tmpBuffers := make([][]byte, numHypotheses)
+	motherBuffers := make([][]byte, numHypotheses)
+
+	for i := 0; i < numHypotheses; i++ {
+		viterbi[i] = newViterbi(decodedBits)
+		decodedBuffer[i] = make([]byte, viterbi[0].DecodedSize())
+		tmpBuffers[i] = make([]byte, encodedSize)
+		motherBuffers[i] = make([]byte, decodedBits*2)
+	}
+
+	encodedBuffer := make([]byte, encodedSize)
+
+	for i := 0; i < encodedSize; i++ {
+		encodedBuffer[i] = softErasure
+	}
+
+	return &DeFEC{
+		codeRate:         codeRate,
+		lockedRate:       rates[0],
+		hypotheses:       hypotheses,
+		encodedSize:      encodedSize,
+		viterbi27:        viterbi,
+		encodedBuffer:    encodedBuffer,
+		decodedBuffer:    decodedBuffer,
+		encodedBufferPos: numLastFrameBits * 2,
+		extraBits:        make([]byte, 0),
+		tmpBuffers:       tmpBuffers,
+		motherBuffers:    motherBuffers,
+		lock:             false,
+		bitErrors:        make([]int, numHypotheses),
+	}
+}
+
+func (fec *DeFEC) PutSoftBits(bits []byte) {
+	fec.Lock()
+	fec.extraBits = append(fec.extraBits, bits...)
+	fec.addBitsToBuffer()
+	fec.Unlock()
+}
+
+func (fec *DeFEC) shiftNBits(n int) {
+	if n == 0 {
+		return
+	}
+
+	fec.Lock()
+
+	if fec.encodedBufferPos > n {
+		copy(fec.encodedBuffer, fec.encodedBuffer[n:])
+		fec.encodedBufferPos -= n
+	} else {
+		fec.encodedBufferPos = numLastFrameBits * 2
+		for i := 0; i < numLastFrameBits*2; i++ {
+			fec.encodedBuffer[i] = softErasure
+		}
+	}
+
+	fec.addBitsToBuffer()
+
+	fec.Unlock()
+}
+
+func (fec *DeFEC) addBitsToBuffer() {
+	bitsToAdd := len(fec.extraBits)
+	missingBytes := fec.encodedSize - fec.encodedBufferPos
+	if bitsToAdd > missingBytes {
+		bitsToAdd = missingBytes
+	}
+
+	if bitsToAdd > 0 {
+		copy(fec.encodedBuffer[fec.encodedBufferPos:], fec.extraBits[:bitsToAdd])
+		fec.extraBits = fec.extraBits[bitsToAdd:]
+		fec.encodedBufferPos += bitsToAdd
+		fec.consumedBits += int64(bitsToAdd)
+	}
+}
+
+// iqOffset returns the position of the first I soft bit in the encoded buffer
+func (fec *DeFEC) iqOffset() int {
+	return int((int64(fec.encodedBufferPos) - fec.consumedBits) & 1)
+}
+
+func (fec *DeFEC) hypothesisIndex(rate CodeRate, phase, rotation int) int {
+	for i, h := range fec.hypotheses {
+		if h.rate == rate && h.phase == phase && h.rotation == rotation {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (fec *DeFEC) lockedHypothesis() int {
+	return fec.hypothesisIndex(fec.lockedRate, fec.lockedPhase, fec.lockedFrame)
+}
+
+func (fec *DeFEC) decodeHypothesis(n int) {
+	h := fec.hypotheses[n]
+	buff := fec.tmpBuffers[n]
+	copy(buff, fec.encodedBuffer)
+
+	// Rotate the whole I/Q pairs, a soft bit without its pair at either end is erased
+	if h.rotation > 0 {
+		rot := buff
+		if fec.iqOffset() == 1 {
+			rot[0] = softErasure
+			rot = rot[1:]
+		}
+		pairs := len(rot) &^ 1
+		rotateSoftBufferBytes(rot[:pairs], h.rotation, int(h.rotation/4) > 0)
+		if pairs < len(rot) {
+			rot[pairs] = softErasure
+		}
+	}
+
+	depuncture(h.rate, h.phase, buff, fec.motherBuffers[n])
+	fec.viterbi27[n].Decode(fec.motherBuffers[n], fec.decodedBuffer[n])
+	fec.bitErrors[n] = countBitErrors(h.rate, h.phase, buff, fec.settledBits(n), numLastFrameBits)
+}
+
+// settledBits returns the decoded bits of hypothesis n without the lookahead
+func (fec *DeFEC) settledBits(n int) []byte {
+	return fec.decodedBuffer[n][:(numLastFrameBits+scanBits)/8]
+}
+
+func (fec *DeFEC) UpdateOut() bool {
+	fec.Lock()
+	defer fec.Unlock()
+
+	if fec.encodedBufferPos != fec.encodedSize && len(fec.extraBits) > 0 { // Add remaining bits if needed
+		fec.addBitsToBuffer()
+	}
+
+	if fec.encodedBufferPos != fec.encodedSize { // If not enough bits in the buffer, break
+		return false
+	}
+
+	if fec.lock { // We had already locked last frame, let's just retry that
+		i := fec.lockedHypothesis()
+		fec.decodeHypothesis(i)
+
+		if !fec.syncPresentN(i) {
+			// Lost lock
+			log.Printf("Lost lock at %d (rate %s, phase %d)!", fec.lockedFrame, fec.lockedRate, fec.lockedPhase)
+			fec.lock = false
+		}
+	}
+
+	if !fec.lock {
+		// Do all rotation and puncturing phase decode
+		fec.updateViterbis()
+	}
+
+	return true
+}
+
+func (fec *DeFEC) updateViterbis() {
+	// Update all viterbis in parallel
+	wg := sync.WaitGroup{}
+	wg.Add(len(fec.hypotheses))
+
+	for i := 0; i < len(fec.hypotheses); i++ {
+		go func(n int) {
+			fec.decodeHypothesis(n)
+			wg.Done()
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func (fec *DeFEC) TryFindSync() int {
+	for fec.UpdateOut() {
+		dvbSync := fec.syncPresent()
+		if dvbSync != -1 {
+			h := fec.hypotheses[dvbSync]
+			if fec.lock == false {
+				log.Printf("Got lock at %d (rate %s, phase %d)\n", h.rotation, h.rate, h.phase)
+			}
+			fec.lock = true
+			fec.lockedRate = h.rate
+			fec.lockedFrame = h.rotation
+			fec.lockedPhase = h.phase
+			fec.readyFrame = dvbSync
+			fec.frameReady = true
+			fec.ResetBuffer()
+			return dvbSync
+		}
+
+		// Look for the sync further in the decoded hypotheses
+		if n, offset := fec.findSyncOffset(); n != -1 {
+			h := fec.hypotheses[n]
+			fec.shiftNBits(puncturedLength(h.rate, h.phase, offset))
+		} else {
+			// Every code rate uses more than one soft bit per decoded bit, so this never skips a sync
+			fec.shiftNBits(syncSearchShift)
+		}
+	}
+
+	return -1
+}
+
+func (fec *DeFEC) ResetBuffer() {
+	// Drop the decoded frame and keep the last bits and the lookahead for the next decode
+	n := puncturedLength(fec.lockedRate, fec.lockedPhase, scanBits)
+	copy(fec.encodedBuffer, fec.encodedBuffer[n:fec.encodedBufferPos])
+	fec.encodedBufferPos -= n
+	fec.lockedPhase = (fec.lockedPhase + scanBits) % fec.lockedRate.Period()
+}
+
+func byteAt(buff []byte, bitOffset int) byte {
+	i := bitOffset / 8
+	r := uint(bitOffset % 8)
+
+	if r == 0 {
+		return buff[i]
+	}
+
+	return buff[i]<<r | buff[i+1]>>(8-r)
+}
+
+func (fec *DeFEC) syncPresentAt(n, bitOffset int) bool {
+	return syncPatternAt(fec.decodedBuffer[n], bitOffset)
+}
+
+// syncPatternAt checks the inverted and normal sync bytes of 5 packets starting at bitOffset, allowing a few bit errors
+func syncPatternAt(buff []byte, bitOffset int) bool {
+	// Soft Sync Present
+
+	v := 0
+	v += bits.OnesCount8(byteAt(buff, bitOffset) ^ 0xB8)
+	v += bits.OnesCount8(byteAt(buff, bitOffset+dvbsFrameBits) ^ 0x47)
+	v += bits.OnesCount8(byteAt(buff, bitOffset+dvbsFrameBits*2) ^ 0x47)
+	v += bits.OnesCount8(byteAt(buff, bitOffset+dvbsFrameBits*3) ^ 0x47)
+	v += bits.OnesCount8(byteAt(buff, bitOffset+dvbsFrameBits*4) ^ 0x47)
+
+	return v < 5
+}
+
+func (fec *DeFEC) syncPresentN(n int) bool {
+	return fec.syncPresentAt(n, numLastFrameBits)
+}
+
+// findSyncOffset returns the first hypothesis with a sync pattern after the expected position and its offset in bits
+func (fec *DeFEC) findSyncOffset() (int, int) {
+	for offset := 1; offset < syncSearchBits; offset++ {
+		for i := 0; i < len(fec.hypotheses); i++ {
+			if fec.syncPresentAt(i, numLastFrameBits+offset) {
+				return i, offset
+			}
+		}
+	}
+
+	return -1, 0
+}
+
+func (fec *DeFEC) syncPresent() int {
+	if fec.lock {
+		// Only the locked hypothesis has been decoded
+		n := fec.lockedHypothesis()
+		if fec.syncPresentN(n) {
+			return n
+		}
+		return -1
+	}
+
+	// Check All Constellation Rotations and Puncturing Phases
+	for i := 0; i < len(fec.hypotheses); i++ {
+		if fec.syncPresentN(i) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (fec *DeFEC) GetLockedFrame() []byte {
+	fec.Lock()
+	defer fec.Unlock()
+
+	if fec.lock && fec.frameReady {
+		fec.frameReady = false
+		return fec.settledBits(fec.readyFrame)[numLastFrameBitsInBytes:]
+	}
+
+	return nil
+}
+
+func (fec *DeFEC) IsFrameReady() bool {
+	return fec.frameReady
+}
+
+func (fec *DeFEC) IsLocked() bool {
+	return fec.lock
+}
+
+// GetCodeRate returns the locked code rate, or the configured one if not locked
+func (fec *DeFEC) GetCodeRate() CodeRate {
+	fec.Lock()
+	defer fec.Unlock()
+
+	if fec.lock {
+		return fec.lockedRate
+	}
+
+	return fec.codeRate
+}
+
+func (fec *DeFEC) GetRotation() int {
+	fec.Lock()
+	defer fec.Unlock()
+
+	return fec.lockedFrame
+}
+
+func (fec *DeFEC) GetPuncturePhase() int {
+	fec.Lock()
+	defer fec.Unlock()
+
+	return fec.lockedPhase
+}
+
+func (fec *DeFEC) GetBER() int {
+	fec.Lock()
+	defer fec.Unlock()
+
+	if fec.lock {
+		return fec.bitErrors[fec.readyFrame]
+	}
+
+	return -1
+}
@@ -0,0 +1,90 @@
+package receiver
+
+import (
+	"bytes"
+	"github.com/racerxdl/kissdvb/viterbi"
+	"math/rand"
+	"testing"
+)
+
+// makeDVBSFrames returns n frames of scanPackets packets with the sync bytes of the energy dispersal groups
+func makeDVBSFrames(n int, rng *rand.Rand) []byte {
+	data := make([]byte, n*scanBits/8)
+	rng.Read(data)
+
+	for i := 0; i < len(data); i += dvbsFrameSize {
+		data[i] = 0x47
+		if (i/dvbsFrameSize)%scanPackets == 0 {
+			data[i] = 0xB8
+		}
+	}
+
+	return data
+}
+
+// encodeSoftBits convolutionally encodes and punctures data into soft bits, rotating the QPSK symbols rotation times
+func encodeSoftBits(data []byte, rate CodeRate, rotation int) []byte {
+	encoded := make([]byte, len(data)*16)
+	viterbi.Encode27(data, encoded)
+
+	x, y := rate.Pattern()
+	soft := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded)/2; i++ {
+		pos := i % len(x)
+		if x[pos] == 1 {
+			soft = append(soft, 20+encoded[i*2]*214)
+		}
+		if y[pos] == 1 {
+			soft = append(soft, 20+encoded[i*2+1]*214)
+		}
+	}
+
+	rotateSoftBufferBytes(soft[:len(soft)&^1], rotation, false)
+
+	return soft
+}
+
+func TestDeFECRotations(t *testing.T) {
+	const numFrames = 12
+
+	for _, rate := range codeRates {
+		for rotation := 0; rotation < 4; rotation++ {
+			rng := rand.New(rand.NewSource(int64(rate)*4 + int64(rotation)))
+			data := makeDVBSFrames(numFrames, rng)
+			soft := encodeSoftBits(data, rate, rotation)
+
+			fec := MakeDeFEC(rate, GoViterbi(0))
+			decoded := make([]byte, 0, len(data))
+
+			// Odd chunks so the frames start at both I and Q soft bits
+			for i := 0; i < len(soft); i += 4099 {
+				end := i + 4099
+				if end > len(soft) {
+					end = len(soft)
+				}
+				fec.PutSoftBits(soft[i:end])
+
+				for fec.TryFindSync() != -1 {
+					decoded = append(decoded, fec.GetLockedFrame()...)
+				}
+			}
+
+			if len(decoded) < (numFrames-2)*scanBits/8 {
+				t.Errorf("rate %s rotation %d: decoded %d frames, expected at least %d", rate, rotation, len(decoded)/(scanBits/8), numFrames-2)
+				continue
+			}
+
+			// The 180 degree rotations invert the decoded bits
+			if decoded[0] == 0x47 {
+				for i := range decoded {
+					decoded[i] ^= 0xFF
+				}
+			}
+
+			start := bytes.Index(data, decoded[:dvbsFrameSize])
+			if start < 0 || !bytes.Equal(decoded, data[start:start+len(decoded)]) {
+				t.Errorf("rate %s rotation %d: decoded frames differ from the transmitted ones", rate, rotation)
+			}
+		}
+	}
+}
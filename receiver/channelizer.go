@@ -0,0 +1,175 @@
+package receiver
+
+import (
+	"fmt"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/segdsp/dsp"
+	"math"
+)
+
+// Minimum samples per symbol after decimation
+const channelMinSPS = 2
+
+// ChannelConfig describes one carrier inside a wideband input
+type ChannelConfig struct {
+	Frequency  float64      `json:"frequency"` // Offset from the input center frequency in Hz
+	SymbolRate float64      `json:"symbolRate"`
+	Modulation string       `json:"modulation"`
+	CodeRate   string       `json:"codeRate"`
+	Outputs    OutputConfig `json:"outputs"`
+}
+
+// Channel mixes one carrier of a wideband input to baseband, filters and decimates it into its own Receiver
+type Channel struct {
+	Config   ChannelConfig
+	Receiver *Receiver
+
+	phase         float64
+	phaseStep     float64
+	decimation    int
+	decimPhase    int
+	taps          []float32
+	history       []complex64
+	mixBuffer     []complex64
+	convertBuffer []complex64
+	workBuffer    []complex64
+	outBuffer     []complex64
+}
+
+// receiverConfig returns the config of the channel receiver: the base config with the channel symbol rate, modulation,
+// code rate and the decimated sample rate
+func (cfg *ChannelConfig) receiverConfig(base Config, inputSampleRate float64) (Config, int, error) {
+	if cfg.SymbolRate <= 0 {
+		return base, 0, fmt.Errorf("invalid channel symbol rate %f", cfg.SymbolRate)
+	}
+
+	bandwidth := cfg.SymbolRate * (1 + base.RollOff)
+	if math.Abs(cfg.Frequency)+bandwidth/2 > inputSampleRate/2 {
+		return base, 0, fmt.Errorf("channel at %f Hz with %f Hz bandwidth is outside the input", cfg.Frequency, bandwidth)
+	}
+
+	decimation := int(inputSampleRate / (cfg.SymbolRate * channelMinSPS))
+	if decimation < 1 {
+		return base, 0, fmt.Errorf("need at least %d samples per symbol for channel at %f Hz", channelMinSPS, cfg.Frequency)
+	}
+
+	rxCfg := base
+	rxCfg.SampleRate = inputSampleRate / float64(decimation)
+	rxCfg.SymbolRate = cfg.SymbolRate
+	if cfg.Modulation != "" {
+		rxCfg.Modulation = cfg.Modulation
+	}
+	if cfg.CodeRate != "" {
+		rxCfg.CodeRate = cfg.CodeRate
+	}
+
+	if err := rxCfg.Validate(); err != nil {
+		return base, 0, err
+	}
+
+	return rxCfg, decimation, nil
+}
+
+// Validate checks the channel inside an input at inputSampleRate and its receiver config made from base
+func (cfg *ChannelConfig) Validate(base Config, inputSampleRate float64) error {
+	if _, _, err := cfg.receiverConfig(base, inputSampleRate); err != nil {
+		return err
+	}
+
+	return cfg.Outputs.Validate()
+}
+
+// MakeChannel creates a channel and its receiver. The receiver uses the base config with the channel symbol rate, modulation,
+// code rate and the decimated sample rate.
+func MakeChannel(base Config, inputSampleRate float64, cfg ChannelConfig) (*Channel, error) {
+	rxCfg, decimation, err := cfg.receiverConfig(base, inputSampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Outputs.Validate(); err != nil {
+		return nil, err
+	}
+
+	rx, err := MakeReceiver(rxCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rx.SetupOutputs(cfg.Outputs); err != nil {
+		rx.Close()
+		return nil, err
+	}
+
+	// The event samples are counted at the input sample rate
+	rx.inputScale = float64(decimation)
+
+	bandwidth := cfg.SymbolRate * (1 + base.RollOff)
+	taps := dsp.MakeLowPass(1, inputSampleRate, bandwidth/2, cfg.SymbolRate*base.RollOff/2)
+
+	return &Channel{
+		Config:     cfg,
+		Receiver:   rx,
+		phaseStep:  -2 * math.Pi * cfg.Frequency / inputSampleRate,
+		decimation: decimation,
+		taps:       taps,
+		history:    make([]complex64, len(taps)-1),
+	}, nil
+}
+
+// mix translates the channel to baseband
+func (c *Channel) mix(data []complex64, out []complex64) {
+	for i, v := range data {
+		s, co := math.Sincos(c.phase)
+		out[i] = v * complex(float32(co), float32(s))
+		c.phase += c.phaseStep
+	}
+
+	c.phase = math.Mod(c.phase, 2*math.Pi)
+}
+
+// filterDecimate low pass filters the data and keeps one of each decimation samples
+func (c *Channel) filterDecimate(data []complex64) []complex64 {
+	ntaps := len(c.taps)
+	work := c.workBuffer[:0]
+	work = append(work, c.history...)
+	work = append(work, data...)
+
+	out := c.outBuffer[:0]
+	i := c.decimPhase
+
+	for ; i < len(data); i += c.decimation {
+		// data[i] is at work[i+ntaps-1]
+		w := work[i : i+ntaps]
+		re := float32(0)
+		im := float32(0)
+		for k := 0; k < ntaps; k++ {
+			t := c.taps[ntaps-1-k]
+			re += t * real(w[k])
+			im += t * imag(w[k])
+		}
+		out = append(out, complex(re, im))
+	}
+
+	c.decimPhase = i - len(data)
+	copy(c.history, work[len(work)-len(c.history):])
+	c.workBuffer = work
+	c.outBuffer = out
+
+	return out
+}
+
+// SamplesCallback receives the wideband samples, use Frontend.MakeFanOut to feed several channels
+func (c *Channel) SamplesCallback(data Frontend.SampleCallbackData) {
+	samples := data.ComplexSamples(&c.convertBuffer)
+	if len(c.mixBuffer) < len(samples) {
+		c.mixBuffer = make([]complex64, len(samples))
+		c.workBuffer = make([]complex64, 0, len(samples)+len(c.history))
+		c.outBuffer = make([]complex64, 0, len(samples)/c.decimation+1)
+	}
+
+	mixed := c.mixBuffer[:len(samples)]
+	c.mix(samples, mixed)
+
+	c.Receiver.PutSamples(c.filterDecimate(mixed))
+}
@@ -0,0 +1,113 @@
+package receiver
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestChannelizer(t *testing.T) {
+	const inputSampleRate = 1e6
+
+	base := DefaultConfig()
+	base.Viterbi = ViterbiGo
+
+	// Each channel has a tone a bit off its center
+	channels := []struct {
+		cfg  ChannelConfig
+		tone float64
+	}{
+		{ChannelConfig{Frequency: 200e3, SymbolRate: 100e3, Outputs: DefaultOutputConfig()}, 10e3},
+		{ChannelConfig{Frequency: -250e3, SymbolRate: 100e3, Outputs: DefaultOutputConfig()}, -5e3},
+	}
+
+	input := make([]complex64, 20000)
+	for i := range input {
+		for _, ch := range channels {
+			input[i] += complex64(cmplx.Rect(1, 2*math.Pi*(ch.cfg.Frequency+ch.tone)*float64(i)/inputSampleRate))
+		}
+	}
+
+	for _, ch := range channels {
+		c, err := MakeChannel(base, inputSampleRate, ch.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Receiver.cfg.SampleRate != 200e3 {
+			t.Errorf("channel at %g Hz: decimated to %g samples/s, expected 200000", ch.cfg.Frequency, c.Receiver.cfg.SampleRate)
+		}
+
+		// Blocks that don't line up with the decimation
+		c.mixBuffer = make([]complex64, len(input))
+		c.workBuffer = make([]complex64, 0, len(input)+len(c.history))
+		output := make([]complex64, 0)
+		for start := 0; start < len(input); start += 1237 {
+			end := start + 1237
+			if end > len(input) {
+				end = len(input)
+			}
+			mixed := c.mixBuffer[:end-start]
+			c.mix(input[start:end], mixed)
+			output = append(output, c.filterDecimate(mixed)...)
+		}
+		c.Receiver.Close()
+
+		if len(output) != len(input)/c.decimation {
+			t.Fatalf("channel at %g Hz: %d samples from %d", ch.cfg.Frequency, len(output), len(input))
+		}
+
+		// Past the filter delay the output is the channel tone at the decimated rate, the other one is rejected
+		omega := 2 * math.Pi * ch.tone / c.Receiver.cfg.SampleRate
+		output = output[len(c.taps):]
+		corr := complex128(0)
+		for i, v := range output {
+			corr += complex128(v) * cmplx.Rect(1, -omega*float64(i))
+		}
+		corr /= complex(float64(len(output)), 0)
+
+		residual := float64(0)
+		for i, v := range output {
+			d := complex128(v) - corr*cmplx.Rect(1, omega*float64(i))
+			residual += real(d)*real(d) + imag(d)*imag(d)
+		}
+		residual /= float64(len(output))
+
+		if a := cmplx.Abs(corr); math.Abs(a-1) > 0.05 {
+			t.Errorf("channel at %g Hz: tone amplitude %f, expected 1", ch.cfg.Frequency, a)
+		}
+		if db := 10 * math.Log10(residual); db > -30 {
+			t.Errorf("channel at %g Hz: other signals at %.1f dB", ch.cfg.Frequency, db)
+		}
+	}
+}
+
+func TestChannelValidate(t *testing.T) {
+	base := DefaultConfig()
+
+	valid := ChannelConfig{Frequency: 100e3, SymbolRate: 250e3, Outputs: DefaultOutputConfig()}
+	if err := valid.Validate(base, 1e6); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func(c *ChannelConfig){
+		func(c *ChannelConfig) { c.SymbolRate = 0 },
+		func(c *ChannelConfig) { c.SymbolRate = 600e3 }, // Less than 2 samples per symbol
+		func(c *ChannelConfig) { c.Frequency = 400e3 },  // Outside the input
+		func(c *ChannelConfig) { c.CodeRate = "4/5" },
+		func(c *ChannelConfig) { c.Modulation = "64QAM" },
+		func(c *ChannelConfig) { c.Outputs.UDPTTL = 256 },
+	} {
+		c := valid
+		change(&c)
+		if err := c.Validate(base, 1e6); err == nil {
+			t.Errorf("invalid channel %+v accepted", c)
+		}
+	}
+
+	// The channel symbol rate is used, not the base one
+	base.SymbolRate = 2e6
+	if err := valid.Validate(base, 1e6); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,253 @@
+package receiver
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/racerxdl/kissdvb/dvbs2"
+	"time"
+)
+
+const (
+	StandardDVBS  = "dvbs"
+	StandardDVBS2 = "dvbs2"
+)
+
+// Config holds the demodulator and FEC parameters
+type Config struct {
+	Standard string `json:"standard"`
+
+	SampleRate float64 `json:"sampleRate"`
+	SymbolRate float64 `json:"symbolRate"`
+	RollOff    float64 `json:"rollOff"`
+	RRCTaps    int     `json:"rrcTaps"`
+
+	PllAlpha        float64 `json:"pllAlpha"`
+	ClockAlpha      float64 `json:"clockAlpha"`
+	ClockMu         float64 `json:"clockMu"`
+	ClockOmegaLimit float64 `json:"clockOmegaLimit"`
+
+	Modulation string `json:"modulation"`
+	CodeRate   string `json:"codeRate"`
+
+	Viterbi          string `json:"viterbi"`
+	ViterbiTraceback int    `json:"viterbiTraceback"`
+
+	// DVB-S2
+	LDPCTables     string `json:"ldpcTables"`
+	LDPCIterations int    `json:"ldpcIterations"`
+	PLScrambling   int    `json:"plScrambling"`
+}
+
+// OutputConfig holds the transport stream outputs
+type OutputConfig struct {
+	TSOutput     string   `json:"tsOutput"`
+	TSRotateSize int64    `json:"tsRotateSize"`
+	TSRotateTime Duration `json:"tsRotateTime"`
+	TSPIDs       string   `json:"tsPids"`
+	UDPOutput    string   `json:"udpOutput"`
+	UDPTTL       int      `json:"udpTtl"`
+	UDPInterface string   `json:"udpInterface"`
+	UDPRTP       bool     `json:"udpRtp"`
+	HTTPOutput   string   `json:"httpOutput"`
+}
+
+// Duration is a time.Duration written in JSON as a string like "1m30s". Integer nanoseconds are also read.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		d.Duration = time.Duration(value)
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		d.Duration = duration
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	return nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Standard:        StandardDVBS,
+		SampleRate:      2e6,
+		SymbolRate:      1e6,
+		RollOff:         0.35,
+		RRCTaps:         15,
+		PllAlpha:        0.0001,
+		ClockAlpha:      0.05,
+		ClockMu:         0.5,
+		ClockOmegaLimit: 0.005,
+		Modulation:      ModulationQPSK,
+		CodeRate:        CodeRateAuto.String(),
+		Viterbi:         ViterbiSatHelper,
+		LDPCIterations:  50,
+	}
+}
+
+func DefaultOutputConfig() OutputConfig {
+	return OutputConfig{
+		UDPTTL: 1,
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Standard, "standard", c.Standard, "Transmission standard: dvbs or dvbs2")
+	fs.Float64Var(&c.SampleRate, "samplerate", c.SampleRate, "Input sample rate in Hz")
+	fs.Float64Var(&c.SymbolRate, "symbolrate", c.SymbolRate, "Symbol rate in Hz")
+	fs.Float64Var(&c.RollOff, "rolloff", c.RollOff, "RRC filter roll-off")
+	fs.IntVar(&c.RRCTaps, "rrctaps", c.RRCTaps, "RRC filter number of taps")
+	fs.Float64Var(&c.PllAlpha, "pllalpha", c.PllAlpha, "Costas loop alpha")
+	fs.Float64Var(&c.ClockAlpha, "clockalpha", c.ClockAlpha, "Clock recovery alpha")
+	fs.Float64Var(&c.ClockMu, "clockmu", c.ClockMu, "Clock recovery initial mu")
+	fs.Float64Var(&c.ClockOmegaLimit, "clockomegalimit", c.ClockOmegaLimit, "Clock recovery relative omega limit")
+	fs.StringVar(&c.Modulation, "modulation", c.Modulation, "Modulation of the dvbs standard: qpsk, or 8psk and 16qam for DVB-DSNG")
+	fs.StringVar(&c.CodeRate, "coderate", c.CodeRate, "Code rate: 1/2, 2/3, 3/4, 5/6, 7/8 for QPSK, 2/3, 5/6, 8/9 for 8PSK, 3/4, 7/8 for 16QAM or auto")
+	fs.StringVar(&c.Viterbi, "viterbi", c.Viterbi, "Viterbi decoder: sathelper or go")
+	fs.IntVar(&c.ViterbiTraceback, "viterbi-traceback", c.ViterbiTraceback, "Traceback length of the go Viterbi decoder in bits (0 for the whole frame)")
+	fs.StringVar(&c.LDPCTables, "ldpc-tables", c.LDPCTables, "Directory with DVB-S2 LDPC address tables (normal_1_2.txt, short_3_4.txt...) replacing the embedded ones")
+	fs.IntVar(&c.LDPCIterations, "ldpc-iterations", c.LDPCIterations, "Maximum LDPC decoder iterations")
+	fs.IntVar(&c.PLScrambling, "pl-scrambling", c.PLScrambling, "DVB-S2 PL scrambling code")
+}
+
+func (c *OutputConfig) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.TSOutput, "ts", c.TSOutput, "Write the transport stream to this file")
+	fs.Int64Var(&c.TSRotateSize, "ts-rotate-size", c.TSRotateSize, "Start a new TS file after this many bytes (0 disables)")
+	fs.DurationVar(&c.TSRotateTime.Duration, "ts-rotate-time", c.TSRotateTime.Duration, "Start a new TS file after this time (0 disables)")
+	fs.StringVar(&c.TSPIDs, "ts-pids", c.TSPIDs, "Comma separated PIDs to write to the TS file (default all)")
+	fs.StringVar(&c.UDPOutput, "udp", c.UDPOutput, "Send the transport stream over UDP to this address (host:port)")
+	fs.IntVar(&c.UDPTTL, "udp-ttl", c.UDPTTL, "Multicast TTL for the UDP output")
+	fs.StringVar(&c.UDPInterface, "udp-iface", c.UDPInterface, "Network interface for the multicast UDP output")
+	fs.BoolVar(&c.UDPRTP, "rtp", c.UDPRTP, "Add RTP headers to the UDP output")
+	fs.StringVar(&c.HTTPOutput, "http", c.HTTPOutput, "Serve the transport stream over HTTP at this address (like :8080)")
+}
+
+func (c *Config) Validate() error {
+	if c.Standard != StandardDVBS && c.Standard != StandardDVBS2 {
+		return fmt.Errorf("invalid standard %q, expected %s or %s", c.Standard, StandardDVBS, StandardDVBS2)
+	}
+
+	if c.Standard == StandardDVBS2 {
+		if c.LDPCTables == "" && !dvbs2.HasEmbeddedCodes() {
+			return fmt.Errorf("DVB-S2 needs the LDPC tables directory, none are embedded")
+		}
+
+		if c.LDPCIterations < 1 {
+			return fmt.Errorf("invalid LDPC iterations %d", c.LDPCIterations)
+		}
+
+		if c.PLScrambling < 0 || c.PLScrambling >= 1<<18-1 {
+			return fmt.Errorf("invalid PL scrambling code %d", c.PLScrambling)
+		}
+	}
+
+	if c.SampleRate <= 0 {
+		return fmt.Errorf("invalid sample rate %f", c.SampleRate)
+	}
+
+	if c.SymbolRate <= 0 {
+		return fmt.Errorf("invalid symbol rate %f", c.SymbolRate)
+	}
+
+	if sps := c.SampleRate / c.SymbolRate; sps < 2 {
+		return fmt.Errorf("need at least 2 samples per symbol, got %f (sample rate %f, symbol rate %f)", sps, c.SampleRate, c.SymbolRate)
+	}
+
+	if c.RollOff <= 0 || c.RollOff > 1 {
+		return fmt.Errorf("roll-off should be in (0, 1], got %f", c.RollOff)
+	}
+
+	if c.RRCTaps < 1 {
+		return fmt.Errorf("invalid number of RRC taps %d", c.RRCTaps)
+	}
+
+	if c.PllAlpha <= 0 || c.ClockAlpha <= 0 {
+		return fmt.Errorf("loop gains should be positive")
+	}
+
+	if c.ClockMu < 0 || c.ClockMu > 1 {
+		return fmt.Errorf("clock mu should be in [0, 1], got %f", c.ClockMu)
+	}
+
+	if c.ClockOmegaLimit < 0 {
+		return fmt.Errorf("invalid clock omega limit %f", c.ClockOmegaLimit)
+	}
+
+	switch c.Modulation {
+	case ModulationQPSK:
+		if _, err := ParseCodeRate(c.CodeRate); err != nil {
+			return err
+		}
+	case Modulation8PSK, Modulation16QAM:
+		if _, err := getDSNGModes(c.Modulation, c.CodeRate); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid modulation %q, expected %s, %s or %s", c.Modulation, ModulationQPSK, Modulation8PSK, Modulation16QAM)
+	}
+
+	if _, err := makeViterbiFactory(c.Viterbi, c.ViterbiTraceback); err != nil {
+		return err
+	}
+
+	if c.ViterbiTraceback < 0 {
+		return fmt.Errorf("invalid viterbi traceback length %d", c.ViterbiTraceback)
+	}
+
+	return nil
+}
+
+func (c *OutputConfig) Validate() error {
+	if c.TSRotateSize < 0 || c.TSRotateTime.Duration < 0 {
+		return fmt.Errorf("invalid TS rotation")
+	}
+
+	if c.TSRotateSize > 0 && c.TSRotateSize < mpegtsFrameSize {
+		return fmt.Errorf("TS rotation size should be at least one packet (%d bytes)", mpegtsFrameSize)
+	}
+
+	if _, err := parsePIDList(c.TSPIDs); err != nil {
+		return err
+	}
+
+	if c.UDPTTL < 0 || c.UDPTTL > 255 {
+		return fmt.Errorf("invalid UDP TTL %d", c.UDPTTL)
+	}
+
+	return nil
+}
+
+func (c *Config) GetCodeRate() CodeRate {
+	cr, _ := ParseCodeRate(c.CodeRate)
+	return cr
+}
+
+// IsDSNG is true for the trellis coded 8PSK and 16QAM modulations
+func (c *Config) IsDSNG() bool {
+	return c.Standard == StandardDVBS && c.Modulation != ModulationQPSK
+}
+
+func (c *Config) ViterbiFactory() ViterbiFactory {
+	f, _ := makeViterbiFactory(c.Viterbi, c.ViterbiTraceback)
+	return f
+}
+
+func (c *Config) ClockGainOmega() float64 {
+	return (c.ClockAlpha * c.ClockAlpha) / 4.0
+}
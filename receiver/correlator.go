@@ -1,4 +1,4 @@
-package main
+package receiver
 
 type Correlator struct {
 	words          [][]byte
@@ -0,0 +1,191 @@
+package receiver
+
+import (
+	"context"
+	"github.com/racerxdl/kissdvb/dvbs"
+	"github.com/racerxdl/kissdvb/dvbs2"
+	"log"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// TSSink receives the derandomized 188 bytes MPEG-TS packets
+type TSSink interface {
+	PutTSFrame(ts []byte)
+}
+
+func (r *Receiver) AddTSSink(sink TSSink) {
+	r.sinksLock.Lock()
+	r.sinks = append(r.sinks, sink)
+	r.sinksLock.Unlock()
+}
+
+func float2byte(v float32) byte {
+
+	v = v*127 + 127
+	if v > 255 {
+		v = 255
+	}
+
+	if v < 0 {
+		v = 0
+	}
+
+	return byte(v)
+}
+
+func (r *Receiver) DecodePut(samples []complex64) {
+	r.rotationLock.Lock()
+
+	data := r.reusableBuffer.Get().([]byte)
+
+	if len(data) < len(samples)*2 {
+		// The smaller buffers are dropped by DecodeLoop, the new ones have the larger size
+		if size := int32(len(samples) * 2); atomic.LoadInt32(&r.lastBufferSize) < size {
+			log.Printf("Growing the decoder buffers to %d bytes", size)
+			atomic.StoreInt32(&r.lastBufferSize, size)
+		}
+		data = make([]byte, len(samples)*2)
+	}
+
+	for i := 0; i < len(samples); i++ {
+		c := samples[i]
+		b0 := float2byte(real(c))
+		b1 := float2byte(imag(c))
+
+		data[i*2] = b0
+		data[i*2+1] = b1
+	}
+
+	r.queue(data[:len(samples)*2])
+	r.rotationLock.Unlock()
+}
+
+// queuedItem is an item for DecodeLoop with the input samples received until it was queued
+type queuedItem struct {
+	data   interface{}
+	sample int64
+}
+
+// queue adds an item for DecodeLoop, counted until it is decoded
+func (r *Receiver) queue(item interface{}) {
+	atomic.AddInt64(&r.queued, 1)
+	r.decoderFifo.Add(queuedItem{
+		data:   item,
+		sample: atomic.LoadInt64(&r.inputSamples),
+	})
+}
+
+// Drain waits until the decoder processed everything queued by PutSamples. The receiver must be started.
+func (r *Receiver) Drain() {
+	for atomic.LoadInt64(&r.queued) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (r *Receiver) DecodeLoop(ctx context.Context) {
+	for {
+		for r.decoderFifo.Len() > 0 {
+			item := r.decoderFifo.Next().(queuedItem)
+			// The events of the item are placed at its input samples, not after the queued ones
+			r.decodedSample = item.sample
+			r.decodeItem(item.data)
+			atomic.AddInt64(&r.queued, -1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		time.Sleep(time.Microsecond)
+		runtime.Gosched()
+	}
+}
+
+func (r *Receiver) decodeItem(item interface{}) {
+	switch v := item.(type) {
+	case *dvbs2.Frame:
+		r.decodePLFrame(v)
+		return
+	case []complex64:
+		r.decodeTCMSymbols(v)
+		r.updateLock(r.tcm.IsLocked(), r.decodedSample)
+		return
+	}
+
+	buffer := item.([]byte)
+	r.defec.PutSoftBits(buffer)
+	size := atomic.LoadInt32(&r.lastBufferSize)
+
+	if int32(len(buffer)) >= size {
+		r.reusableBuffer.Put(buffer)
+	}
+
+	// A buffer can hold several frames
+	for r.defec.TryFindSync() != -1 {
+		r.Decode(r.defec.GetLockedFrame())
+	}
+
+	r.updateLock(r.defec.IsLocked(), r.decodedSample)
+}
+
+func (r *Receiver) Decode(frame []byte) {
+	r.deinterleaver.PutData(frame)
+
+	if r.deinterleaver.NumStoredFrames() < scanPackets {
+		return
+	}
+
+	frames := make([][]byte, scanPackets)
+
+	for i := 0; i < scanPackets; i++ {
+		frames[i] = r.deinterleaver.GetFrame()
+		atomic.AddInt64(&r.packetCount, 1)
+	}
+
+	dvbFrame := make([]byte, mpegtsFrameSize*scanPackets)
+
+	rserrors := 0
+	uncorrectable := make([]bool, scanPackets)
+
+	for i := 0; i < scanPackets; i++ {
+		decoded, errors := r.rs.Decode(frames[i])
+		if errors < 0 {
+			// Keep the received data, the packet is flagged after the energy dispersal removal
+			copy(dvbFrame[i*mpegtsFrameSize:], frames[i][:mpegtsFrameSize])
+			uncorrectable[i] = true
+			atomic.AddInt64(&r.rsUncorrectable, 1)
+			continue
+		}
+
+		copy(dvbFrame[i*mpegtsFrameSize:], decoded)
+		rserrors += errors
+
+		if errors > 0 {
+			atomic.AddInt64(&r.rsCorrectedBytes, int64(errors))
+			atomic.AddInt64(&r.rsCorrectedPackets, 1)
+		}
+	}
+
+	atomic.StoreInt64(&r.rsErrors, int64(rserrors))
+
+	dvbs.DeRandomize(dvbFrame)
+
+	// EN 300 421 4.4.2: set the transport_error_indicator of packets that could not be corrected
+	for i := 0; i < scanPackets; i++ {
+		if uncorrectable[i] {
+			dvbFrame[i*mpegtsFrameSize+1] |= 0x80
+		}
+	}
+
+	r.sinksLock.Lock()
+	for _, sink := range r.sinks {
+		for i := 0; i < scanPackets; i++ {
+			sink.PutTSFrame(dvbFrame[i*mpegtsFrameSize : (i+1)*mpegtsFrameSize])
+		}
+	}
+	r.sinksLock.Unlock()
+}
@@ -1,4 +1,4 @@
-package main
+package receiver
 
 import (
 	"github.com/racerxdl/go.fifo"
@@ -56,7 +56,9 @@ func (di *Deinterleaver) PutData(d []byte) {
 		di.diFifo[di.comutator].Add(d[i])
 		b := di.diFifo[di.comutator].Next().(byte)
 
-		if b == 0x47 || b == 0xb8 { // Wait for first sync to start the DeInterleaver
+		// Wait for the inverted sync of the first packet of a randomizer group to start the DeInterleaver.
+		// Sync bytes always pass through the first branch, other branches carry data before the pre-state is flushed.
+		if di.comutator == 0 && b == 0xb8 {
 			di.gotSync = true
 		}
 
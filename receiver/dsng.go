@@ -0,0 +1,126 @@
+package receiver
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DVB-DSNG (EN 301 210) 8PSK and 16QAM use pragmatic trellis coding: the DVB-S convolutional code, punctured to the
+// inner rate, gives the two coded bits of each symbol that select a subset of the constellation. The other bits of
+// the symbol are sent uncoded and select the point inside the subset, where the points are far apart.
+
+const (
+	ModulationQPSK  = "qpsk"
+	Modulation8PSK  = "8psk"
+	Modulation16QAM = "16qam"
+)
+
+// DSNGMode is one of the trellis coded modulation and code rate combinations
+type DSNGMode struct {
+	Modulation string
+	Rate       string
+	inner      CodeRate
+	group      int     // Symbols in one puncturing period of the inner code
+	uncoded    int     // Uncoded bits per symbol
+	qefEbN0    float64 // EN 301 210 Table D.1
+}
+
+var dsngModes = []*DSNGMode{
+	{Modulation: Modulation8PSK, Rate: "2/3", inner: CodeRate1_2, group: 1, uncoded: 1, qefEbN0: 6.9},
+	{Modulation: Modulation8PSK, Rate: "5/6", inner: CodeRate3_4, group: 2, uncoded: 1, qefEbN0: 8.9},
+	{Modulation: Modulation8PSK, Rate: "8/9", inner: CodeRate5_6, group: 3, uncoded: 1, qefEbN0: 9.4},
+	{Modulation: Modulation16QAM, Rate: "3/4", inner: CodeRate1_2, group: 1, uncoded: 2, qefEbN0: 9.0},
+	{Modulation: Modulation16QAM, Rate: "7/8", inner: CodeRate3_4, group: 2, uncoded: 2, qefEbN0: 10.7},
+}
+
+func (m *DSNGMode) String() string {
+	return fmt.Sprintf("%s %s", strings.ToUpper(m.Modulation), m.Rate)
+}
+
+// groupBits is the number of data bits in a group: the encoder input bits followed by the uncoded bits of each symbol
+func (m *DSNGMode) groupBits() int {
+	return m.inner.Period() + m.uncoded*m.group
+}
+
+// EsN0QEF returns the Es/N0 needed for QEF reception
+func (m *DSNGMode) EsN0QEF() float64 {
+	bitsPerSymbol := float64(m.groupBits()) / float64(m.group)
+	return m.qefEbN0 + 10*math.Log10(bitsPerSymbol*mpegtsFrameSize/dvbsFrameSize)
+}
+
+// getDSNGModes returns the modes of the modulation with the code rate, or all of them for auto
+func getDSNGModes(modulation, rate string) ([]*DSNGMode, error) {
+	modes := make([]*DSNGMode, 0)
+	for _, m := range dsngModes {
+		if m.Modulation == modulation && (strings.ToLower(rate) == "auto" || m.Rate == rate) {
+			modes = append(modes, m)
+		}
+	}
+
+	if len(modes) == 0 {
+		return nil, fmt.Errorf("invalid code rate %q for %s", rate, modulation)
+	}
+
+	return modes, nil
+}
+
+// Gray coded 8PSK subsets, indexed by the coded bits C1 C0
+var subsets8PSK = [4]int{0, 1, 3, 2}
+
+// 16QAM amplitude levels indexed by the coded and uncoded bit of the axis
+var levels16QAM = [2][2]float64{{-3, 1}, {-1, 3}}
+
+// dsngConstellation returns the unit energy points indexed by the symbol value: the coded bits C1 C0 in the two LSBs
+// and the uncoded bits above them. The 8PSK subsets are the antipodal pairs, the uncoded bit selects the half.
+// The 16QAM coded bits select the levels of each axis, the uncoded bits the level inside them.
+func dsngConstellation(modulation string) []complex64 {
+	if modulation == Modulation16QAM {
+		points := make([]complex64, 16)
+		scale := 1 / math.Sqrt(10)
+		for v := range points {
+			i := levels16QAM[v>>1&1][v>>3&1]
+			q := levels16QAM[v&1][v>>2&1]
+			points[v] = complex(float32(i*scale), float32(q*scale))
+		}
+		return points
+	}
+
+	points := make([]complex64, 8)
+	for v := range points {
+		s, c := math.Sincos(float64(subsets8PSK[v&3]+4*(v>>2)) * math.Pi / 4)
+		points[v] = complex(float32(c), float32(s))
+	}
+
+	return points
+}
+
+// dsngSymmetry is the number of rotations that map the constellation on itself, the carrier loop locks to any of them
+func dsngSymmetry(modulation string) int {
+	if modulation == Modulation16QAM {
+		return 4
+	}
+
+	return 8
+}
+
+// putTCMSymbols queues the clock recovered DSNG symbols for the decoder
+func (r *Receiver) putTCMSymbols(symbols []complex64) {
+	r.quality.update(symbols)
+
+	if r.symbolsCallback != nil {
+		r.symbolsCallback(symbols)
+	}
+
+	buffer := make([]complex64, len(symbols))
+	copy(buffer, symbols)
+	r.queue(buffer)
+}
+
+func (r *Receiver) decodeTCMSymbols(symbols []complex64) {
+	r.tcm.PutSymbols(symbols)
+
+	for r.tcm.TryFindSync() {
+		r.Decode(r.tcm.GetLockedFrame())
+	}
+}
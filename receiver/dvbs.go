@@ -0,0 +1,19 @@
+package receiver
+
+import (
+	"github.com/racerxdl/gorrect/Codes"
+	"github.com/racerxdl/kissdvb/dvbs"
+	"github.com/racerxdl/segdsp/tools"
+	"math"
+)
+
+const reedSolomonPoly = Codes.ReedSolomonPrimitivePolynomial8_4_3_2_0
+const reedSolomonDistance = 8
+const mpegtsFrameSize = dvbs.PacketSize
+const dvbsFrameSize = 204
+const dvbsFrameBits = dvbsFrameSize * 8
+
+var rotation90 = complex(tools.Cos(float32(math.Pi/2)), tools.Sin(float32(math.Pi/2)))
+
+const scanPackets = dvbs.ScanPackets
+const scanBits = dvbsFrameBits * scanPackets
@@ -0,0 +1,93 @@
+package receiver
+
+import (
+	"github.com/racerxdl/kissdvb/dvbs2"
+	"log"
+	"sync/atomic"
+)
+
+// dvbs2Chain is the DVB-S2 part of the receiver. The PL framing runs with the DSP and the queued frames are
+// decoded by the decoder loop.
+type dvbs2Chain struct {
+	framer  *dvbs2.Framer
+	decoder *dvbs2.Decoder
+	ts      *dvbs2.TSRecovery
+
+	frames       int64
+	failed       int64
+	unsupported  int64
+	bchCorrected int64
+	crcErrors    int64
+
+	unsupportedLogged map[int]bool
+}
+
+func makeDVBS2Chain(cfg Config) (*dvbs2Chain, error) {
+	codes, err := dvbs2.LoadCodes(cfg.LDPCTables)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dvbs2Chain{
+		framer:            dvbs2.MakeFramer(cfg.PLScrambling),
+		decoder:           dvbs2.MakeDecoder(codes, cfg.LDPCIterations),
+		ts:                dvbs2.MakeTSRecovery(),
+		unsupportedLogged: make(map[int]bool),
+	}, nil
+}
+
+// putDVBS2Symbols finds the PLFRAMEs in the clock recovered symbols and queues them for the decoder
+func (r *Receiver) putDVBS2Symbols(symbols []complex64) {
+	for _, frame := range r.s2.framer.Work(symbols) {
+		r.quality.update(frame.Known)
+
+		if r.symbolsCallback != nil && frame.Symbols != nil {
+			r.symbolsCallback(frame.Symbols)
+		}
+
+		r.queue(frame)
+	}
+
+	r.updateLock(r.s2.framer.IsLocked(), atomic.LoadInt64(&r.inputSamples))
+}
+
+func (r *Receiver) decodePLFrame(frame *dvbs2.Frame) {
+	s2 := r.s2
+	atomic.AddInt64(&s2.frames, 1)
+
+	bbFrame, res, err := s2.decoder.Decode(frame)
+	if err == nil {
+		var h dvbs2.BBHeader
+		if h, err = dvbs2.ParseBBHeader(bbFrame); err == nil {
+			atomic.AddInt64(&s2.bchCorrected, int64(res.BCHCorrected))
+			r.putTSPackets(s2.ts.PutBBFrame(bbFrame, h))
+			atomic.StoreInt64(&s2.crcErrors, s2.ts.CRCErrors())
+			return
+		}
+	}
+
+	if err == dvbs2.ErrUnsupported {
+		atomic.AddInt64(&s2.unsupported, 1)
+		if code := frame.PLS.Code(); !s2.unsupportedLogged[code] {
+			log.Printf("Cannot decode %s frames", frame.PLS)
+			s2.unsupportedLogged[code] = true
+		}
+	} else {
+		atomic.AddInt64(&s2.failed, 1)
+	}
+
+	// The user packets don't continue in the next frame
+	r.putTSPackets(s2.ts.Reset())
+}
+
+func (r *Receiver) putTSPackets(packets [][]byte) {
+	atomic.AddInt64(&r.packetCount, int64(len(packets)))
+
+	r.sinksLock.Lock()
+	for _, sink := range r.sinks {
+		for _, p := range packets {
+			sink.PutTSFrame(p)
+		}
+	}
+	r.sinksLock.Unlock()
+}
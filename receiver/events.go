@@ -0,0 +1,73 @@
+package receiver
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Receiver event types
+const (
+	EventLockAcquired = "lock acquired"
+	EventLockLost     = "lock lost"
+	EventService      = "service" // A service name found in the SDT
+)
+
+type Event struct {
+	Type   string
+	Text   string // Mode of the lock events, service name and provider of EventService
+	Sample int64  // Input sample of the frontend where the data of the event was received
+}
+
+// SetEventCallback sets a function called with the lock changes and the service names found in the transport stream.
+// It must be set before Start, it is called by the decoder.
+func (r *Receiver) SetEventCallback(cb func(e Event)) {
+	r.eventCallback = cb
+	r.AddTSSink(makeSDTSink(func(serviceID uint16, name, provider string) {
+		log.Printf("Service %d: %s (%s)", serviceID, name, provider)
+		cb(Event{
+			Type:   EventService,
+			Text:   fmt.Sprintf("%s (%s)", name, provider),
+			Sample: r.frontendSample(r.decodedSample),
+		})
+	}))
+}
+
+// frontendSample converts a count of samples given to PutSamples to samples of the frontend
+func (r *Receiver) frontendSample(n int64) int64 {
+	return int64(float64(n) * r.inputScale)
+}
+
+// updateLock reports the changes of the lock state found in the data queued at input sample
+func (r *Receiver) updateLock(locked bool, sample int64) {
+	v := int32(0)
+	if locked {
+		v = 1
+	}
+
+	if atomic.SwapInt32(&r.locked, v) == v || r.eventCallback == nil {
+		return
+	}
+
+	if !locked {
+		r.eventCallback(Event{Type: EventLockLost, Sample: r.frontendSample(sample)})
+		return
+	}
+
+	r.eventCallback(Event{Type: EventLockAcquired, Text: r.lockedMode(), Sample: r.frontendSample(sample)})
+}
+
+// lockedMode returns the modulation and code rate found by the decoder
+func (r *Receiver) lockedMode() string {
+	switch {
+	case r.s2 != nil:
+		return r.s2.framer.GetPLS().String()
+	case r.tcm != nil:
+		if mode := r.tcm.GetMode(); mode != nil {
+			return mode.String()
+		}
+		return ""
+	}
+
+	return "QPSK " + r.defec.GetCodeRate().String()
+}
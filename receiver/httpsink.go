@@ -0,0 +1,165 @@
+package receiver
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// How many TS packets are queued for each client before it is considered too slow and dropped
+const httpClientQueueSize = 8192
+
+type httpClient struct {
+	packets chan []byte
+	pids    map[uint16]bool
+}
+
+// HTTPSink serves the live transport stream to any number of HTTP clients. Clients can filter PIDs with
+// a query parameter, like http://host:port/?pids=0,17,256
+type HTTPSink struct {
+	sync.Mutex
+	listener net.Listener
+	server   *http.Server
+	clients  map[*httpClient]bool
+}
+
+func MakeHTTPSink(address string) (*HTTPSink, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &HTTPSink{
+		listener: l,
+		clients:  make(map[*httpClient]bool),
+	}
+
+	s.server = &http.Server{Handler: s}
+
+	go func() {
+		if err := s.server.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server error: %s", err)
+		}
+	}()
+
+	log.Printf("Serving transport stream at http://%s/", l.Addr())
+
+	return s, nil
+}
+
+func (s *HTTPSink) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *HTTPSink) addClient(c *httpClient) {
+	s.Lock()
+	s.clients[c] = true
+	s.Unlock()
+}
+
+func (s *HTTPSink) removeClient(c *httpClient) {
+	s.Lock()
+	if s.clients[c] {
+		delete(s.clients, c)
+		close(c.packets)
+	}
+	s.Unlock()
+}
+
+func (s *HTTPSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var pids map[uint16]bool
+
+	if v := r.URL.Query().Get("pids"); v != "" {
+		list, err := parsePIDList(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pids = make(map[uint16]bool)
+		for _, pid := range list {
+			pids[pid] = true
+		}
+	}
+
+	c := &httpClient{
+		packets: make(chan []byte, httpClientQueueSize),
+		pids:    pids,
+	}
+
+	s.addClient(c)
+	defer s.removeClient(c)
+
+	log.Printf("HTTP client %s connected", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "video/MP2T")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		// Send the headers right away
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("HTTP client %s disconnected", r.RemoteAddr)
+			return
+		case ts, ok := <-c.packets:
+			if !ok {
+				log.Printf("HTTP client %s is too slow, dropping", r.RemoteAddr)
+				return
+			}
+
+			if _, err := w.Write(ts); err != nil {
+				return
+			}
+
+			if len(c.packets) == 0 && flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func (s *HTTPSink) PutTSFrame(ts []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.clients) == 0 {
+		return
+	}
+
+	packet := make([]byte, len(ts))
+	copy(packet, ts)
+	pid := tsPID(packet)
+
+	for c := range s.clients {
+		if c.pids != nil && !c.pids[pid] {
+			continue
+		}
+
+		select {
+		case c.packets <- packet:
+		default:
+			// Never block Decode, drop the client
+			delete(s.clients, c)
+			close(c.packets)
+		}
+	}
+}
+
+func (s *HTTPSink) Close() error {
+	err := s.server.Close()
+
+	s.Lock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c.packets)
+	}
+	s.Unlock()
+
+	return err
+}
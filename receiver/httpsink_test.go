@@ -0,0 +1,126 @@
+package receiver
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func makeTestHTTPSink() (*HTTPSink, *httptest.Server) {
+	s := &HTTPSink{
+		clients: make(map[*httpClient]bool),
+	}
+	return s, httptest.NewServer(s)
+}
+
+func (s *HTTPSink) numClients() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.clients)
+}
+
+// waitClients waits for the sink to have n clients
+func waitClients(t *testing.T, s *HTTPSink, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for s.numClients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d HTTP clients, expected %d", s.numClients(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	s, server := makeTestHTTPSink()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/?pids=0x10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "video/MP2T" {
+		t.Fatalf("status %d, content type %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitClients(t, s, 1)
+
+	// Odd packets on PID 0x10, even ones on PID 0x11
+	for i := 0; i < 20; i++ {
+		p := makeTestTSPacket(i)
+		p[2] = byte(0x10 + (i+1)%2)
+		s.PutTSFrame(p)
+	}
+
+	for i := 1; i < 20; i += 2 {
+		p := make([]byte, mpegtsFrameSize)
+		if _, err := io.ReadFull(resp.Body, p); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := makeTestTSPacket(i)
+		expected[2] = 0x10
+		if !bytes.Equal(p, expected) {
+			t.Fatalf("packet %d differs", i)
+		}
+	}
+
+	resp.Body.Close()
+	waitClients(t, s, 0)
+}
+
+func TestHTTPSinkBadPIDs(t *testing.T) {
+	_, server := makeTestHTTPSink()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/?pids=0x2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status %d, expected %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPSinkStalledClient(t *testing.T) {
+	s, server := makeTestHTTPSink()
+	defer server.Close()
+
+	// The body is never read
+	stalled, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Body.Close()
+	waitClients(t, s, 1)
+
+	// The socket buffers take some packets before the queue fills
+	done := make(chan int)
+	go func() {
+		n := 0
+		for ; n < 100*httpClientQueueSize && s.numClients() > 0; n++ {
+			s.PutTSFrame(makeTestTSPacket(n))
+		}
+		done <- n
+	}()
+
+	select {
+	case n := <-done:
+		if s.numClients() != 0 {
+			t.Fatalf("stalled client still served after %d packets", n)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("PutTSFrame blocked by the stalled client")
+	}
+
+	// The stream of the dropped client ends
+	if _, err := io.Copy(ioutil.Discard, stalled.Body); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,115 @@
+package receiver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+const metricsNamespace = "kissdvb"
+
+var (
+	metricLocked              = prometheus.NewDesc(metricsNamespace+"_locked", "1 when the FEC is locked", []string{"channel"}, nil)
+	metricCodeRate            = prometheus.NewDesc(metricsNamespace+"_code_rate_info", "Detected code rate", []string{"channel", "rate"}, nil)
+	metricRotation            = prometheus.NewDesc(metricsNamespace+"_rotation", "Detected constellation rotation", []string{"channel"}, nil)
+	metricBER                 = prometheus.NewDesc(metricsNamespace+"_viterbi_bit_errors", "Bit errors corrected by the Viterbi decoder in the last frame", []string{"channel"}, nil)
+	metricPackets             = prometheus.NewDesc(metricsNamespace+"_packets_total", "Received transport stream packets", []string{"channel"}, nil)
+	metricRSErrors            = prometheus.NewDesc(metricsNamespace+"_rs_errors", "Reed Solomon errors in the last packet group", []string{"channel"}, nil)
+	metricRSCorrectedBytes    = prometheus.NewDesc(metricsNamespace+"_rs_corrected_bytes_total", "Bytes corrected by Reed Solomon", []string{"channel"}, nil)
+	metricRSCorrectedPackets  = prometheus.NewDesc(metricsNamespace+"_rs_corrected_packets_total", "Packets with errors corrected by Reed Solomon", []string{"channel"}, nil)
+	metricRSUncorrectable     = prometheus.NewDesc(metricsNamespace+"_rs_uncorrectable_packets_total", "Packets Reed Solomon could not correct", []string{"channel"}, nil)
+	metricMER                 = prometheus.NewDesc(metricsNamespace+"_mer_db", "Modulation error ratio", []string{"channel"}, nil)
+	metricEsN0                = prometheus.NewDesc(metricsNamespace+"_esn0_db", "Estimated Es/N0", []string{"channel"}, nil)
+	metricEVM                 = prometheus.NewDesc(metricsNamespace+"_evm_percent", "RMS error vector magnitude", []string{"channel"}, nil)
+	metricLinkMargin          = prometheus.NewDesc(metricsNamespace+"_link_margin_db", "Es/N0 above the QEF threshold of the code rate", []string{"channel"}, nil)
+	metricFrequency           = prometheus.NewDesc(metricsNamespace+"_frequency_offset_hz", "Carrier frequency offset tracked by the Costas loop", []string{"channel"}, nil)
+	metricOmega               = prometheus.NewDesc(metricsNamespace+"_clock_omega", "Samples per symbol tracked by the clock recovery", []string{"channel"}, nil)
+	metricDecoderFifo         = prometheus.NewDesc(metricsNamespace+"_decoder_fifo_depth", "Soft bit buffers waiting for the decoder", []string{"channel"}, nil)
+	metricDeinterleaverFrames = prometheus.NewDesc(metricsNamespace+"_deinterleaver_frames", "Deinterleaved frames waiting for RS decoding", []string{"channel"}, nil)
+)
+
+// MetricsCollector exports the statistics of one or more receivers as Prometheus metrics.
+// The statistics are read on each scrape.
+type MetricsCollector struct {
+	sync.Mutex
+	channels  []string
+	receivers []*Receiver
+}
+
+func MakeMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{}
+}
+
+// AddReceiver adds a receiver, its metrics have the channel label set to channel
+func (c *MetricsCollector) AddReceiver(channel string, r *Receiver) {
+	c.Lock()
+	c.channels = append(c.channels, channel)
+	c.receivers = append(c.receivers, r)
+	c.Unlock()
+}
+
+func (c *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricLocked
+	ch <- metricCodeRate
+	ch <- metricRotation
+	ch <- metricBER
+	ch <- metricPackets
+	ch <- metricRSErrors
+	ch <- metricRSCorrectedBytes
+	ch <- metricRSCorrectedPackets
+	ch <- metricRSUncorrectable
+	ch <- metricMER
+	ch <- metricEsN0
+	ch <- metricEVM
+	ch <- metricLinkMargin
+	ch <- metricFrequency
+	ch <- metricOmega
+	ch <- metricDecoderFifo
+	ch <- metricDeinterleaverFrames
+}
+
+func (c *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+
+	for i, r := range c.receivers {
+		name := c.channels[i]
+		s := r.GetStats()
+
+		locked := 0.0
+		if s.Locked {
+			locked = 1
+		}
+
+		gauge := func(desc *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, name)
+		}
+
+		counter := func(desc *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v, name)
+		}
+
+		gauge(metricLocked, locked)
+		rate := s.CodeRate.String()
+		if s.ModCod != "" {
+			rate = s.ModCod
+		} else if s.Mode != "" {
+			rate = s.Mode
+		}
+		ch <- prometheus.MustNewConstMetric(metricCodeRate, prometheus.GaugeValue, 1, name, rate)
+		gauge(metricRotation, float64(s.Rotation))
+		gauge(metricBER, float64(s.BER))
+		counter(metricPackets, float64(s.Packets))
+		gauge(metricRSErrors, float64(s.RSErrors))
+		counter(metricRSCorrectedBytes, float64(s.RSCorrectedBytes))
+		counter(metricRSCorrectedPackets, float64(s.RSCorrectedPackets))
+		counter(metricRSUncorrectable, float64(s.RSUncorrectable))
+		gauge(metricMER, s.MER)
+		gauge(metricEsN0, s.EsN0)
+		gauge(metricEVM, s.EVM)
+		gauge(metricLinkMargin, s.LinkMargin)
+		gauge(metricFrequency, s.FrequencyOffset)
+		gauge(metricOmega, s.ClockOmega)
+		gauge(metricDecoderFifo, float64(s.DecoderFifo))
+		gauge(metricDeinterleaverFrames, float64(s.DeinterleaverFrames))
+	}
+}
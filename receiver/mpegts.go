@@ -0,0 +1,35 @@
+package receiver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const tsSyncByte = 0x47
+const tsMaxPID = 0x1FFF
+
+func tsPID(ts []byte) uint16 {
+	return uint16(ts[1]&0x1F)<<8 | uint16(ts[2])
+}
+
+// parsePIDList parses a comma separated list of PIDs in decimal or hex (0x prefixed)
+func parsePIDList(list string) ([]uint16, error) {
+	pids := make([]uint16, 0)
+
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		pid, err := strconv.ParseUint(v, 0, 16)
+		if err != nil || pid > tsMaxPID {
+			return nil, fmt.Errorf("invalid PID %q", v)
+		}
+
+		pids = append(pids, uint16(pid))
+	}
+
+	return pids, nil
+}
@@ -0,0 +1,66 @@
+package receiver
+
+import (
+	"io"
+	"log"
+)
+
+// SetupOutputs creates the TS sinks from the config and adds them to the receiver. They're closed by Close.
+func (r *Receiver) SetupOutputs(cfg OutputConfig) error {
+	if cfg.TSOutput != "" {
+		sink, err := MakeTSFileSink(cfg.TSOutput)
+		if err != nil {
+			return err
+		}
+		pids, _ := parsePIDList(cfg.TSPIDs)
+		sink.SetPIDFilter(pids)
+		sink.SetRotation(cfg.TSRotateSize, cfg.TSRotateTime.Duration)
+		r.addOutput(sink)
+	}
+
+	if cfg.UDPOutput != "" {
+		sink, err := MakeUDPSink(cfg.UDPOutput, cfg.UDPTTL, cfg.UDPInterface, cfg.UDPRTP)
+		if err != nil {
+			return err
+		}
+		r.addOutput(sink)
+	}
+
+	if cfg.HTTPOutput != "" {
+		sink, err := MakeHTTPSink(cfg.HTTPOutput)
+		if err != nil {
+			return err
+		}
+		r.addOutput(sink)
+	}
+
+	return nil
+}
+
+type tsOutput interface {
+	TSSink
+	io.Closer
+}
+
+func (r *Receiver) addOutput(sink tsOutput) {
+	r.AddTSSink(sink)
+	r.sinksLock.Lock()
+	r.outputs = append(r.outputs, sink)
+	r.sinksLock.Unlock()
+}
+
+// Close stops the receiver and closes the outputs created by SetupOutputs
+func (r *Receiver) Close() {
+	r.Stop()
+
+	r.sinksLock.Lock()
+	defer r.sinksLock.Unlock()
+
+	for _, o := range r.outputs {
+		if err := o.Close(); err != nil {
+			log.Printf("Error closing output: %s", err)
+		}
+	}
+
+	r.outputs = nil
+}
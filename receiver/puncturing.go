@@ -0,0 +1,137 @@
+package receiver
+
+import (
+	"github.com/racerxdl/kissdvb/dvbs"
+	"math/bits"
+)
+
+// CodeRate is the inner code rate of the dvbs package, shared with the modulator
+type CodeRate = dvbs.CodeRate
+
+const (
+	CodeRateAuto = dvbs.CodeRateAuto
+	CodeRate1_2  = dvbs.CodeRate1_2
+	CodeRate2_3  = dvbs.CodeRate2_3
+	CodeRate3_4  = dvbs.CodeRate3_4
+	CodeRate5_6  = dvbs.CodeRate5_6
+	CodeRate7_8  = dvbs.CodeRate7_8
+)
+
+var codeRates = dvbs.CodeRates
+
+const softErasure = 127
+
+// Generator polynomials G1 = 171 and G2 = 133 (octal), newest bit at LSB
+const polyX = 0x4F
+const polyY = 0x6D
+
+func ParseCodeRate(s string) (CodeRate, error) {
+	return dvbs.ParseCodeRate(s)
+}
+
+// puncturedLength returns how many transmitted soft bits carry numBits decoded bits starting at the specified phase
+func puncturedLength(rate CodeRate, phase, numBits int) int {
+	x, y := rate.Pattern()
+	period := len(x)
+	n := 0
+
+	for i := 0; i < numBits; i++ {
+		pos := (phase + i) % period
+		n += int(x[pos] + y[pos])
+	}
+
+	return n
+}
+
+// maxPuncturedLength returns the biggest puncturedLength over all phases
+func maxPuncturedLength(rate CodeRate, numBits int) int {
+	max := 0
+	for phase := 0; phase < rate.Period(); phase++ {
+		n := puncturedLength(rate, phase, numBits)
+		if n > max {
+			max = n
+		}
+	}
+
+	return max
+}
+
+// depuncture expands the received soft bits into the mother 1/2 code inserting erasures at punctured positions.
+// Returns the number of soft bits consumed from input.
+func depuncture(rate CodeRate, phase int, input, output []byte) int {
+	x, y := rate.Pattern()
+	period := len(x)
+	k := 0
+
+	for i := 0; i < len(output)/2; i++ {
+		pos := (phase + i) % period
+
+		if x[pos] == 1 {
+			output[i*2] = input[k]
+			k++
+		} else {
+			output[i*2] = softErasure
+		}
+
+		if y[pos] == 1 {
+			output[i*2+1] = input[k]
+			k++
+		} else {
+			output[i*2+1] = softErasure
+		}
+	}
+
+	return k
+}
+
+// countBitErrors re-encodes and punctures the decoded bits and compares them against the hard decision of the
+// received soft bits. The first skipBits are only used to fill the encoder state.
+func countBitErrors(rate CodeRate, phase int, received, decoded []byte, skipBits int) int {
+	x, y := rate.Pattern()
+	period := len(x)
+	sr := uint(0)
+	k := 0
+	errors := 0
+	total := 0
+
+	for i := 0; i < len(decoded)*8 && k < len(received); i++ {
+		bit := uint(decoded[i/8]>>uint(7-i%8)) & 1
+		sr = ((sr << 1) | bit) & 0x7F
+		pos := (phase + i) % period
+
+		if x[pos] == 1 {
+			if i >= skipBits {
+				total++
+				if hardBit(received[k]) != byte(bits.OnesCount(sr&polyX)&1) {
+					errors++
+				}
+			}
+			k++
+		}
+
+		if y[pos] == 1 && k < len(received) {
+			if i >= skipBits {
+				total++
+				if hardBit(received[k]) != byte(bits.OnesCount(sr&polyY)&1) {
+					errors++
+				}
+			}
+			k++
+		}
+	}
+
+	// Soft bit polarity depends on the constellation rotation, so take the closest one
+	if total-errors < errors {
+		return total - errors
+	}
+
+	return errors
+}
+
+func hardBit(v byte) byte {
+	if v > softErasure {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,93 @@
+package receiver
+
+import (
+	"math"
+)
+
+// carrierLoop is the carrier recovery before the clock recovery: a Costas loop for QPSK or decisionLoop for DSNG
+type carrierLoop interface {
+	WorkBuffer(input, output []complex64) int
+	GetFrequency() float32
+}
+
+// Smoothing of the power used to scale the samples to the constellation
+const decisionPowerAlpha = 0.001
+
+// decisionLoop is a decision directed carrier loop for the DSNG 8PSK and 16QAM. It has the same second order loop
+// as the Costas loops, the phase error is measured against the closest point of the power normalized constellation,
+// so it locks on the points the TCMDecoder demaps.
+type decisionLoop struct {
+	alpha     float32
+	beta      float32
+	phase     float32
+	frequency float32
+	power     float32
+	points    []complex64
+}
+
+func makeDecisionLoop(loopBandwidth float32, modulation string) *decisionLoop {
+	damping := float32(math.Sqrt2 / 2)
+	denom := 1 + 2*damping*loopBandwidth + loopBandwidth*loopBandwidth
+
+	return &decisionLoop{
+		alpha:  4 * damping * loopBandwidth / denom,
+		beta:   4 * loopBandwidth * loopBandwidth / denom,
+		points: dsngConstellation(modulation),
+	}
+}
+
+func (l *decisionLoop) WorkBuffer(input, output []complex64) int {
+	for i, s := range input {
+		sin, cos := math.Sincos(float64(-l.phase))
+		o := s * complex(float32(cos), float32(sin))
+		output[i] = o
+
+		p := sqAbs(o)
+		if l.power == 0 {
+			l.power = p
+		} else {
+			l.power += decisionPowerAlpha * (p - l.power)
+		}
+
+		if l.power == 0 {
+			continue
+		}
+
+		n := o * complex(1/float32(math.Sqrt(float64(l.power))), 0)
+		best := l.points[0]
+		for _, c := range l.points[1:] {
+			if sqAbs(n-c) < sqAbs(n-best) {
+				best = c
+			}
+		}
+
+		// Im(n * conj(best)) is the sine of the phase error times the point amplitude
+		e := (imag(n)*real(best) - real(n)*imag(best)) / sqAbs(best)
+		if e > 1 {
+			e = 1
+		} else if e < -1 {
+			e = -1
+		}
+
+		l.frequency += l.beta * e
+		if l.frequency > 1 {
+			l.frequency = 1
+		} else if l.frequency < -1 {
+			l.frequency = -1
+		}
+
+		l.phase += l.frequency + l.alpha*e
+		for l.phase > 2*math.Pi {
+			l.phase -= 2 * math.Pi
+		}
+		for l.phase < -2*math.Pi {
+			l.phase += 2 * math.Pi
+		}
+	}
+
+	return len(input)
+}
+
+func (l *decisionLoop) GetFrequency() float32 {
+	return l.frequency
+}
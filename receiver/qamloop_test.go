@@ -0,0 +1,76 @@
+package receiver
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestDecisionLoopLock runs the DSNG constellations with a carrier offset through the loop and checks the frequency
+// estimate and the derotated symbols
+func TestDecisionLoopLock(t *testing.T) {
+	const numSymbols = 60000
+	const offset = 0.002 // Radians per symbol
+
+	for _, modulation := range []string{Modulation8PSK, Modulation16QAM} {
+		loop := makeDecisionLoop(0.002, modulation)
+		rng := rand.New(rand.NewSource(1))
+		points := dsngConstellation(modulation)
+
+		symbols := make([]complex64, numSymbols)
+		for i := range symbols {
+			symbols[i] = points[rng.Intn(len(points))]
+		}
+		input := addNoise(symbols, 0, 25, rng)
+		for i := range input {
+			sin, cos := math.Sincos(offset*float64(i) + 0.3)
+			input[i] *= complex(float32(cos), float32(sin))
+		}
+
+		output := make([]complex64, numSymbols)
+		loop.WorkBuffer(input, output)
+
+		if f := float64(loop.GetFrequency()); math.Abs(f-offset) > offset/10 {
+			t.Errorf("%s: frequency %g, expected %g", modulation, f, offset)
+		}
+
+		// After the lock the symbols sit on the constellation rotated by a multiple of its symmetry
+		symmetry := dsngSymmetry(modulation)
+		step := 2 * math.Pi / float64(symmetry)
+		tail := output[numSymbols/2:]
+
+		var power float64
+		for _, s := range tail {
+			power += float64(sqAbs(s))
+		}
+		scale := complex(float32(math.Sqrt(float64(len(tail))/power)), 0)
+
+		errors := make([]int, symmetry)
+		for r := range errors {
+			sin, cos := math.Sincos(-step * float64(r))
+			rotation := complex(float32(cos), float32(sin)) * scale
+			for i, s := range tail {
+				s *= rotation
+				best := 0
+				for v, p := range points {
+					if sqAbs(s-p) < sqAbs(s-points[best]) {
+						best = v
+					}
+				}
+				if points[best] != symbols[numSymbols/2+i] {
+					errors[r]++
+				}
+			}
+		}
+
+		min := len(tail)
+		for _, e := range errors {
+			if e < min {
+				min = e
+			}
+		}
+		if min > len(tail)/1000 {
+			t.Errorf("%s: %d of %d symbols wrong after the lock", modulation, min, len(tail))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package receiver
+
+import (
+	"math"
+	"sync"
+)
+
+// Smoothing of the block estimates
+const qualityAlpha = 0.1
+
+// Required Eb/N0 for QEF reception, EN 300 421 Table D.1 (BER 2e-4 after Viterbi)
+var qefEbN0 = map[CodeRate]float64{
+	CodeRate1_2: 4.5,
+	CodeRate2_3: 5.0,
+	CodeRate3_4: 5.5,
+	CodeRate5_6: 6.0,
+	CodeRate7_8: 6.4,
+}
+
+// qefEsN0 returns the Es/N0 needed for QEF reception. Eb is the useful bit, so QPSK, the inner code and RS(204,188) count.
+func qefEsN0(rate CodeRate) float64 {
+	ebn0, ok := qefEbN0[rate]
+	if !ok {
+		return math.NaN()
+	}
+
+	r := float64(rate.Period()) / float64(puncturedLength(rate, 0, rate.Period()))
+
+	return ebn0 + 10*math.Log10(2*r*mpegtsFrameSize/dvbsFrameSize)
+}
+
+// qualityEstimator measures the clock recovered QPSK symbols, or the symbols of the constellation when set.
+// MER and EVM use the distance to the closest constellation point, Es/N0 uses the M2M4 moments estimator that
+// doesn't depend on the decisions.
+type qualityEstimator struct {
+	sync.Mutex
+	initialized bool
+	power       float64 // Mean symbol power
+	m4          float64
+	ideal       float64 // Power of the ideal constellation
+	errorPower  float64
+
+	points   []complex64 // Unit energy constellation, nil for QPSK
+	kurtosis float64     // E|s|^4 of the constellation
+}
+
+// setConstellation sets the unit energy constellation of a modulation other than QPSK
+func (q *qualityEstimator) setConstellation(points []complex64) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.points = points
+	q.kurtosis = 0
+	for _, p := range points {
+		a := float64(real(p)*real(p) + imag(p)*imag(p))
+		q.kurtosis += a * a
+	}
+	q.kurtosis /= float64(len(points))
+}
+
+func (q *qualityEstimator) update(symbols []complex64) {
+	if len(symbols) == 0 {
+		return
+	}
+
+	n := float64(len(symbols))
+	m2 := float64(0)
+	m4 := float64(0)
+	amplitude := float64(0)
+
+	for _, s := range symbols {
+		re := float64(real(s))
+		im := float64(imag(s))
+		p := re*re + im*im
+		m2 += p
+		m4 += p * p
+		amplitude += math.Abs(re) + math.Abs(im)
+	}
+
+	m2 /= n
+	m4 /= n
+	amplitude /= 2 * n
+
+	q.Lock()
+	points := q.points
+	q.Unlock()
+
+	errorPower := float64(0)
+	ideal := 2 * amplitude * amplitude
+
+	if points != nil && m2 > 0 {
+		// Scaled to the unit energy constellation, the error is measured against the closest point
+		scale := complex(float32(1/math.Sqrt(m2)), 0)
+		for _, s := range symbols {
+			s *= scale
+			best := float32(math.MaxFloat32)
+			for _, p := range points {
+				if d := sqAbs(s - p); d < best {
+					best = d
+				}
+			}
+			errorPower += float64(best)
+		}
+		errorPower *= m2 / n
+		ideal = m2 - errorPower
+	} else {
+		for _, s := range symbols {
+			er := math.Abs(float64(real(s))) - amplitude
+			ei := math.Abs(float64(imag(s))) - amplitude
+			errorPower += er*er + ei*ei
+		}
+		errorPower /= n
+	}
+
+	q.Lock()
+	if !q.initialized {
+		q.power, q.m4, q.ideal, q.errorPower = m2, m4, ideal, errorPower
+		q.initialized = true
+	} else {
+		q.power += qualityAlpha * (m2 - q.power)
+		q.m4 += qualityAlpha * (m4 - q.m4)
+		q.ideal += qualityAlpha * (ideal - q.ideal)
+		q.errorPower += qualityAlpha * (errorPower - q.errorPower)
+	}
+	q.Unlock()
+}
+
+// get returns the MER (dB), Es/N0 (dB), RMS EVM (%) and mean symbol power
+func (q *qualityEstimator) get() (mer, esn0, evm, power float64) {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.initialized || q.errorPower == 0 {
+		return math.NaN(), math.NaN(), math.NaN(), q.power
+	}
+
+	mer = 10 * math.Log10(q.ideal/q.errorPower)
+	evm = math.Sqrt(q.errorPower/q.ideal) * 100
+
+	// M2M4: S = sqrt((2 M2² - M4) / (2 - ka)), N = M2 - S. The kurtosis ka is 1 for PSK.
+	kurtosis := float64(1)
+	if q.points != nil {
+		kurtosis = q.kurtosis
+	}
+
+	esn0 = math.NaN()
+	if d := (2*q.power*q.power - q.m4) / (2 - kurtosis); d > 0 {
+		s := math.Sqrt(d)
+		if s < q.power {
+			esn0 = 10 * math.Log10(s/(q.power-s))
+		} else {
+			esn0 = math.Inf(1)
+		}
+	}
+
+	return mer, esn0, evm, q.power
+}
@@ -0,0 +1,138 @@
+package receiver
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var qpskConstellation = []complex64{
+	complex(math.Sqrt2/2, math.Sqrt2/2), complex(-math.Sqrt2/2, math.Sqrt2/2),
+	complex(-math.Sqrt2/2, -math.Sqrt2/2), complex(math.Sqrt2/2, -math.Sqrt2/2),
+}
+
+func TestQualityEstimator(t *testing.T) {
+	const (
+		blockSize = 1000
+		numBlocks = 100
+		level     = 0.37 // The estimates don't depend on the level
+	)
+
+	// The M2M4 estimate of QAM at a high Es/N0 is dominated by the kurtosis of the random symbols, only the MER is checked
+	tests := []struct {
+		modulation string
+		esN0       float64
+		m2m4       bool
+	}{
+		{ModulationQPSK, 10, true},
+		{ModulationQPSK, 15, true},
+		{ModulationQPSK, 25, true},
+		{Modulation8PSK, 16, true},
+		{Modulation8PSK, 22, true},
+		{Modulation16QAM, 18, true},
+		{Modulation16QAM, 25, false},
+	}
+
+	for i, tt := range tests {
+		points := qpskConstellation
+		q := &qualityEstimator{}
+		if tt.modulation != ModulationQPSK {
+			points = dsngConstellation(tt.modulation)
+			q.setConstellation(points)
+		}
+
+		rng := rand.New(rand.NewSource(int64(i)))
+		symbols := make([]complex64, blockSize)
+		for b := 0; b < numBlocks; b++ {
+			for k := range symbols {
+				symbols[k] = points[rng.Intn(len(points))]
+			}
+			noisy := addNoise(symbols, 0, tt.esN0, rng)
+			for k := range noisy {
+				noisy[k] *= level
+			}
+			q.update(noisy)
+		}
+
+		mer, esn0, evm, power := q.get()
+
+		// With unit energy symbols the MER is the Es/N0 while the decisions are right
+		if math.Abs(mer-tt.esN0) > 0.3 {
+			t.Errorf("%s at %g dB: MER %.2f dB", tt.modulation, tt.esN0, mer)
+		}
+		if tt.m2m4 && math.Abs(esn0-tt.esN0) > 0.3 {
+			t.Errorf("%s at %g dB: Es/N0 %.2f dB", tt.modulation, tt.esN0, esn0)
+		}
+
+		expectedEVM := 100 * math.Pow(10, -tt.esN0/20)
+		if math.Abs(evm/expectedEVM-1) > 0.05 {
+			t.Errorf("%s at %g dB: EVM %.2f%%, expected %.2f%%", tt.modulation, tt.esN0, evm, expectedEVM)
+		}
+
+		expectedPower := level * level * (1 + math.Pow(10, -tt.esN0/10))
+		if math.Abs(power/expectedPower-1) > 0.02 {
+			t.Errorf("%s at %g dB: power %f, expected %f", tt.modulation, tt.esN0, power, expectedPower)
+		}
+	}
+}
+
+func TestQualityEstimatorNoSignal(t *testing.T) {
+	q := &qualityEstimator{}
+	if mer, esn0, evm, _ := q.get(); !math.IsNaN(mer) || !math.IsNaN(esn0) || !math.IsNaN(evm) {
+		t.Errorf("estimates %f, %f, %f without symbols", mer, esn0, evm)
+	}
+
+	// Without noise there is no error to measure
+	q.update(qpskConstellation)
+	if mer, _, _, power := q.get(); !math.IsNaN(mer) || math.Abs(power-1) > 1e-6 {
+		t.Errorf("MER %f and power %f without noise", mer, power)
+	}
+}
+
+func TestQEFEsN0(t *testing.T) {
+	// Eb/N0 of Table D.1 and the useful bits per symbol, RS(204,188) included
+	expected := map[CodeRate]float64{
+		CodeRate1_2: 4.145,
+		CodeRate3_4: 6.906,
+		CodeRate7_8: 8.476,
+	}
+	for rate, esN0 := range expected {
+		if v := qefEsN0(rate); math.Abs(v-esN0) > 0.001 {
+			t.Errorf("rate %s: QEF Es/N0 %.3f dB, expected %.3f", rate, v, esN0)
+		}
+	}
+
+	if !math.IsNaN(qefEsN0(CodeRateAuto)) {
+		t.Error("QEF Es/N0 of an unknown code rate")
+	}
+
+	for _, mode := range []struct {
+		modulation string
+		rate       string
+		esN0       float64
+	}{
+		{Modulation8PSK, "2/3", 9.556},
+		{Modulation16QAM, "7/8", 15.786},
+	} {
+		modes, err := getDSNGModes(mode.modulation, mode.rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := modes[0].EsN0QEF(); math.Abs(v-mode.esN0) > 0.001 {
+			t.Errorf("%s: QEF Es/N0 %.3f dB, expected %.3f", modes[0], v, mode.esN0)
+		}
+	}
+
+	// The margin of a receiver locked at 3/4 with a known Es/N0
+	q := &qualityEstimator{}
+	rng := rand.New(rand.NewSource(1))
+	symbols := make([]complex64, 20000)
+	for k := range symbols {
+		symbols[k] = qpskConstellation[rng.Intn(4)]
+	}
+	q.update(addNoise(symbols, 0, 9, rng))
+	_, esn0, _, _ := q.get()
+	if margin := esn0 - qefEsN0(CodeRate3_4); math.Abs(margin-(9-6.906)) > 0.3 {
+		t.Errorf("link margin %.2f dB at 9 dB Es/N0 and rate 3/4", margin)
+	}
+}
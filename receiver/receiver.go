@@ -0,0 +1,224 @@
+package receiver
+
+import (
+	"context"
+	"github.com/OpenSatelliteProject/libsathelper"
+	"github.com/racerxdl/go.fifo"
+	"github.com/racerxdl/gorrect"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/segdsp/dsp"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Receiver is a DVB-S receiver chain: RRC filter, Costas loop, clock recovery, DeFEC, deinterleaver,
+// Reed Solomon and energy dispersal removal. The recovered transport stream is sent to the TS sinks.
+// With the DVB-S2 standard the clock recovered symbols go to the PL framing, LDPC, BCH and BBFRAME decoding instead.
+// The DSNG 8PSK and 16QAM modulations use the decisionLoop and the TCMDecoder in place of the Costas loop and the DeFEC.
+type Receiver struct {
+	sync.Mutex
+	cfg Config
+
+	filter    *dsp.FirFilter
+	costasNew carrierLoop
+	mmOld     SatHelper.ClockRecovery
+	buffer0   []complex64
+	buffer1   []complex64
+
+	convertBuffer []complex64 // Integer samples converted by SamplesCallback
+
+	quality qualityEstimator
+
+	defec         *DeFEC
+	tcm           *TCMDecoder
+	deinterleaver *Deinterleaver
+	rs            *gorrect.ReedSolomon
+
+	s2 *dvbs2Chain
+
+	rotationLock   sync.Mutex
+	decoderFifo    *fifo.Queue
+	queued         int64   // Items in decoderFifo not decoded yet
+	inputSamples   int64   // Samples given to PutSamples
+	inputScale     float64 // Frontend samples of each input sample, the decimation of a channel
+	decodedSample  int64   // inputSamples when the item being decoded was queued
+	reusableBuffer sync.Pool
+	lastBufferSize int32
+
+	sinksLock       sync.Mutex
+	sinks           []TSSink
+	outputs         []io.Closer
+	symbolsCallback func(symbols []complex64)
+	eventCallback   func(e Event)
+	locked          int32
+
+	packetCount        int64
+	rsErrors           int64 // Corrected bytes in the last group
+	rsCorrectedBytes   int64
+	rsCorrectedPackets int64
+	rsUncorrectable    int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func MakeReceiver(cfg Config) (*Receiver, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	sps := cfg.SampleRate / cfg.SymbolRate
+	rrcTaps := dsp.MakeRRC(1, cfg.SampleRate, cfg.SymbolRate, cfg.RollOff, cfg.RRCTaps)
+
+	r := &Receiver{
+		cfg:    cfg,
+		filter: dsp.MakeFirFilter(rrcTaps),
+		//mmOld = SatHelper.NewClockRecovery(float32(sps), 0.25*0.175*0.175, 0.5, 0.175, 0.005)
+		mmOld: SatHelper.NewClockRecovery(float32(sps), float32(cfg.ClockGainOmega()), float32(cfg.ClockMu), float32(cfg.ClockAlpha), float32(cfg.ClockOmegaLimit)),
+		//mmNew = digital.NewComplexClockRecovery(float32(sps), 0.25*0.175*0.175, 0.5, 0.175, 0.005)
+		deinterleaver:  MakeDeinterleaver(),
+		rs:             gorrect.MakeReedSolomon(dvbsFrameSize, mpegtsFrameSize, reedSolomonDistance, reedSolomonPoly),
+		decoderFifo:    fifo.NewQueue(),
+		lastBufferSize: 64 * 1024,
+		inputScale:     1,
+	}
+
+	r.reusableBuffer.New = r.newBuffer
+
+	if cfg.IsDSNG() {
+		r.costasNew = makeDecisionLoop(float32(cfg.PllAlpha), cfg.Modulation)
+	} else {
+		r.costasNew = dsp.MakeCostasLoop4(float32(cfg.PllAlpha))
+	}
+
+	switch {
+	case cfg.Standard == StandardDVBS2:
+		s2, err := makeDVBS2Chain(cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.s2 = s2
+	case cfg.IsDSNG():
+		modes, _ := getDSNGModes(cfg.Modulation, cfg.CodeRate)
+		r.tcm = MakeTCMDecoder(modes, cfg.ViterbiFactory())
+		r.quality.setConstellation(dsngConstellation(cfg.Modulation))
+	default:
+		r.defec = MakeDeFEC(cfg.GetCodeRate(), cfg.ViterbiFactory())
+	}
+
+	return r, nil
+}
+
+func (r *Receiver) newBuffer() interface{} {
+	return make([]byte, atomic.LoadInt32(&r.lastBufferSize))
+}
+
+// SetSymbolsCallback sets a function called with the clock recovered symbols, used for displaying the constellation
+func (r *Receiver) SetSymbolsCallback(cb func(symbols []complex64)) {
+	r.Lock()
+	r.symbolsCallback = cb
+	r.Unlock()
+}
+
+// Start runs the decoder until Stop is called or ctx is done
+func (r *Receiver) Start(ctx context.Context) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.cancel != nil {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go func() {
+		r.DecodeLoop(ctx)
+		close(r.done)
+	}()
+}
+
+// Stop stops the decoder and waits it to finish
+func (r *Receiver) Stop() {
+	r.Lock()
+	cancel := r.cancel
+	done := r.done
+	r.cancel = nil
+	r.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (r *Receiver) checkAndResizeBuffers(length int) {
+	if len(r.buffer0) < length {
+		r.buffer0 = make([]complex64, length)
+	}
+	if len(r.buffer1) < length {
+		r.buffer1 = make([]complex64, length)
+	}
+}
+
+func swapBuffers(a *[]complex64, b *[]complex64) {
+	c := *b
+	*b = *a
+	*a = c
+}
+
+// SamplesCallback can be used as the Frontend callback
+func (r *Receiver) SamplesCallback(data Frontend.SampleCallbackData) {
+	r.PutSamples(data.ComplexSamples(&r.convertBuffer))
+}
+
+// PutSamples runs the DSP chain over the samples and queues the symbols for the decoder
+func (r *Receiver) PutSamples(data []complex64) {
+	if len(data) == 0 {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	atomic.AddInt64(&r.inputSamples, int64(len(data)))
+	r.checkAndResizeBuffers(len(data))
+
+	copy(r.buffer0, data)
+
+	// The buffers can be longer than data after a bigger call
+	ba := r.buffer0[:len(data)]
+	bb := r.buffer1[:len(data)]
+
+	s := r.filter.WorkBuffer(ba, bb)
+	swapBuffers(&ba, &bb)
+
+	// The DVB-S2 carrier is recovered from the PLHEADER and pilots
+	if r.s2 == nil {
+		s = r.costasNew.WorkBuffer(ba, bb)
+		swapBuffers(&ba, &bb)
+	}
+
+	//s = mmNew.WorkBuffer(ba, bb)
+	s = r.mmOld.Work(&ba[0], &bb[0], s)
+	swapBuffers(&ba, &bb)
+
+	if r.s2 != nil {
+		r.putDVBS2Symbols(ba[:s])
+		return
+	}
+
+	if r.tcm != nil {
+		r.putTCMSymbols(ba[:s])
+		return
+	}
+
+	r.quality.update(ba[:s])
+
+	if r.symbolsCallback != nil {
+		r.symbolsCallback(ba[:s])
+	}
+
+	r.DecodePut(ba[:s])
+}
@@ -0,0 +1,349 @@
+package receiver_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/racerxdl/kissdvb/channelsim"
+	"github.com/racerxdl/kissdvb/modulator"
+	"github.com/racerxdl/kissdvb/receiver"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+)
+
+// The regression scenarios run synthetic captures from the modulator and the impairment simulator through the whole
+// receiver chain (RRC filter, Costas loop, clock recovery, DeFEC, deinterleaver, RS and energy dispersal removal) and
+// check the recovered transport stream against a golden hash.
+
+const tsPacketSize = 188
+const testPID = 0x100
+
+// Packets of each scenario
+const numPackets = 400
+
+// Every scenario has locked before this packet, the golden hash covers the test packets from here to the end
+const goldenFirstPacket = 100
+
+// SHA-256 of the test packets goldenFirstPacket to numPackets-1
+const goldenTSHash = "9b904bb60e367afd9f684cdde140d54b212aa8bc356b38f961e4ed89cf95dd2d"
+
+// Null packets after the test packets so the deinterleaver and the FEC overlap flush them out
+const tailPackets = 64
+
+type scenario struct {
+	name     string
+	codeRate string // Transmitted code rate
+	rxRate   string // Receiver code rate, empty for the same as transmitted
+	sim      func(cfg *channelsim.Config)
+	rx       func(cfg *receiver.Config)
+
+	maxLockPackets   int // Packets transmitted before the first recovered one
+	maxUncorrectable int // Packets flagged by Reed Solomon as uncorrectable
+}
+
+var scenarios = []scenario{
+	{name: "clean-1/2", codeRate: "1/2", maxLockPackets: 40},
+	{name: "clean-2/3", codeRate: "2/3", maxLockPackets: 40},
+	{name: "clean-3/4", codeRate: "3/4", maxLockPackets: 40},
+	{name: "clean-5/6", codeRate: "5/6", maxLockPackets: 40},
+	{name: "clean-7/8", codeRate: "7/8", maxLockPackets: 40},
+	{name: "auto-3/4", codeRate: "3/4", rxRate: "auto", maxLockPackets: 80},
+	{name: "auto-7/8", codeRate: "7/8", rxRate: "auto", maxLockPackets: 80},
+	{name: "awgn-1/2", codeRate: "1/2", maxLockPackets: 60, sim: func(c *channelsim.Config) {
+		c.EsN0 = 7
+	}},
+	{name: "awgn-3/4", codeRate: "3/4", maxLockPackets: 60, sim: func(c *channelsim.Config) {
+		c.EsN0 = 9
+	}},
+	{name: "cfo-1/2", codeRate: "1/2", maxLockPackets: 80, sim: func(c *channelsim.Config) {
+		c.FrequencyOffset = 2e3
+		c.FrequencyDrift = 500
+	}, rx: func(c *receiver.Config) {
+		c.PllAlpha = 0.002
+	}},
+	{name: "phase-noise-1/2", codeRate: "1/2", maxLockPackets: 60, sim: func(c *channelsim.Config) {
+		c.EsN0 = 12
+		c.PhaseNoise = 5
+	}, rx: func(c *receiver.Config) {
+		c.PllAlpha = 0.002
+	}},
+	{name: "timing-1/2", codeRate: "1/2", maxLockPackets: 60, sim: func(c *channelsim.Config) {
+		c.TimingOffset = 0.3
+		c.ClockDrift = 50
+	}},
+	{name: "iq-dc-1/2", codeRate: "1/2", maxLockPackets: 60, sim: func(c *channelsim.Config) {
+		c.EsN0 = 12
+		c.IQGain = 0.5
+		c.IQPhase = 3
+		c.DCI = 0.02
+		c.DCQ = -0.02
+	}},
+}
+
+func makePacket(n int) []byte {
+	p := make([]byte, tsPacketSize)
+	p[0] = 0x47
+	p[1] = byte(testPID >> 8)
+	p[2] = byte(testPID & 0xFF)
+	p[3] = 0x10 | byte(n&0xF)
+	binary.BigEndian.PutUint32(p[4:], uint32(n))
+
+	// Deterministic payload
+	x := uint32(n)*2654435761 + 1
+	for i := 8; i < tsPacketSize; i++ {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		p[i] = byte(x)
+	}
+
+	return p
+}
+
+func makeNullPacket() []byte {
+	p := make([]byte, tsPacketSize)
+	p[0] = 0x47
+	p[1] = 0x1F
+	p[2] = 0xFF
+	p[3] = 0x10
+	return p
+}
+
+type collector struct {
+	packets chan []byte
+}
+
+func (c *collector) PutTSFrame(ts []byte) {
+	p := make([]byte, len(ts))
+	copy(p, ts)
+	select {
+	case c.packets <- p:
+	default:
+	}
+}
+
+// modulate returns the baseband of the test packets followed by the tail
+func modulate(cfg modulator.Config) ([]complex64, error) {
+	baseband := &bytes.Buffer{}
+	mod, err := modulator.MakeModulator(cfg, baseband)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < numPackets; i++ {
+		mod.PutTSFrame(makePacket(i))
+	}
+
+	for i := 0; i < tailPackets; i++ {
+		mod.PutTSFrame(makeNullPacket())
+	}
+
+	if err := mod.Flush(); err != nil {
+		return nil, err
+	}
+
+	samples := make([]complex64, baseband.Len()/8)
+	if err := binary.Read(baseband, binary.LittleEndian, samples); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// run returns the test packets recovered in order and the receiver stats
+func (sc scenario) run(t *testing.T) ([][]byte, receiver.Stats) {
+	modCfg := modulator.DefaultConfig()
+	modCfg.CodeRate = sc.codeRate
+
+	samples, err := modulate(modCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simCfg := channelsim.DefaultConfig()
+	simCfg.SampleRate = modCfg.SampleRate
+	simCfg.SymbolRate = modCfg.SymbolRate
+	if sc.sim != nil {
+		sc.sim(&simCfg)
+	}
+
+	sim, err := channelsim.MakeSimulator(simCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rxCfg := receiver.DefaultConfig()
+	rxCfg.SampleRate = modCfg.SampleRate
+	rxCfg.SymbolRate = modCfg.SymbolRate
+	rxCfg.RollOff = modCfg.RollOff
+	rxCfg.CodeRate = sc.codeRate
+	if sc.rxRate != "" {
+		rxCfg.CodeRate = sc.rxRate
+	}
+	rxCfg.Viterbi = receiver.ViterbiGo
+	if sc.rx != nil {
+		sc.rx(&rxCfg)
+	}
+
+	rx, err := receiver.MakeReceiver(rxCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+
+	sink := &collector{packets: make(chan []byte, numPackets+tailPackets+64)}
+	rx.AddTSSink(sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rx.Start(ctx)
+
+	const chunkSize = 16384
+	for i := 0; i < len(samples); i += chunkSize {
+		end := i + chunkSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		rx.PutSamples(sim.Work(samples[i:end]))
+	}
+	rx.Drain()
+	close(sink.packets)
+
+	packets := make([][]byte, 0, numPackets)
+	for p := range sink.packets {
+		if int(p[1]&0x1F)<<8|int(p[2]) == testPID {
+			packets = append(packets, p)
+		}
+	}
+
+	return packets, rx.GetStats()
+}
+
+func TestGoldenPacket(t *testing.T) {
+	h := sha256.New()
+	for n := goldenFirstPacket; n < numPackets; n++ {
+		h.Write(makePacket(n))
+	}
+
+	if hash := hex.EncodeToString(h.Sum(nil)); hash != goldenTSHash {
+		t.Fatalf("test packets hash %s, expected %s", hash, goldenTSHash)
+	}
+}
+
+func TestRegression(t *testing.T) {
+	if !testing.Verbose() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	for _, sc := range scenarios {
+		sc := sc
+		t.Run(sc.name, func(t *testing.T) {
+			if testing.Short() && sc.sim != nil {
+				t.Skip("impairment scenario in short mode")
+			}
+
+			packets, stats := sc.run(t)
+			if len(packets) == 0 {
+				t.Fatalf("no packet recovered")
+			}
+
+			first := int(binary.BigEndian.Uint32(packets[0][4:]))
+			if first > sc.maxLockPackets {
+				t.Errorf("lock took %d packets, expected at most %d", first, sc.maxLockPackets)
+			}
+
+			if stats.RSUncorrectable > int64(sc.maxUncorrectable) {
+				t.Errorf("%d packets uncorrectable by RS, expected at most %d", stats.RSUncorrectable, sc.maxUncorrectable)
+			}
+
+			// Bit exact and without gaps from the golden start to the end
+			h := sha256.New()
+			for _, p := range packets {
+				if binary.BigEndian.Uint32(p[4:]) >= goldenFirstPacket {
+					h.Write(p)
+				}
+			}
+
+			if hash := hex.EncodeToString(h.Sum(nil)); hash != goldenTSHash {
+				t.Errorf("recovered TS hash %s, expected %s", hash, goldenTSHash)
+			}
+
+			t.Logf("first packet %d, received %d, RS corrected %d bytes, FEC %s", first, len(packets), stats.RSCorrectedBytes, stats.CodeRate)
+		})
+	}
+}
+
+func TestEventSamples(t *testing.T) {
+	if !testing.Verbose() {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	modCfg := modulator.DefaultConfig()
+	modCfg.CodeRate = "1/2"
+
+	samples, err := modulate(modCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rxCfg := receiver.DefaultConfig()
+	rxCfg.SampleRate = modCfg.SampleRate
+	rxCfg.SymbolRate = modCfg.SymbolRate
+	rxCfg.RollOff = modCfg.RollOff
+	rxCfg.CodeRate = "1/2"
+	rxCfg.Viterbi = receiver.ViterbiGo
+
+	rx, err := receiver.MakeReceiver(rxCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+
+	events := make(chan receiver.Event, 16)
+	rx.SetEventCallback(func(e receiver.Event) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+
+	// The whole input is queued before the decoder starts, so the events are decoded after the last sample
+	const chunkSize = 16384
+	for i := 0; i < len(samples); i += chunkSize {
+		end := i + chunkSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		rx.PutSamples(samples[i:end])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rx.Start(ctx)
+	rx.Drain()
+	close(events)
+
+	// The lock takes less than maxLockPackets of the numPackets+tailPackets transmitted
+	maxSample := int64(len(samples)) * 60 / (numPackets + tailPackets)
+
+	locked := false
+	for e := range events {
+		if e.Type != receiver.EventLockAcquired {
+			continue
+		}
+		locked = true
+		if e.Sample <= 0 || e.Sample > maxSample {
+			t.Errorf("lock acquired at sample %d, expected at most %d", e.Sample, maxSample)
+		}
+	}
+
+	if !locked {
+		t.Fatal("no lock acquired event")
+	}
+}
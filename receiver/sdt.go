@@ -0,0 +1,172 @@
+package receiver
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const sdtPID = 0x11
+const sdtTableActual = 0x42 // SDT of the actual transport stream
+const serviceDescriptorTag = 0x48
+
+// sdtSink reassembles the SDT sections of the transport stream (EN 300 468 5.2.3) and reports the name of
+// each service once, and again when it changes
+type sdtSink struct {
+	section  []byte
+	services map[uint16]string
+	cb       func(serviceID uint16, name, provider string)
+}
+
+func makeSDTSink(cb func(serviceID uint16, name, provider string)) *sdtSink {
+	return &sdtSink{
+		services: make(map[uint16]string),
+		cb:       cb,
+	}
+}
+
+func (s *sdtSink) PutTSFrame(ts []byte) {
+	if len(ts) != mpegtsFrameSize || ts[0] != tsSyncByte || ts[1]&0x80 != 0 || tsPID(ts) != sdtPID {
+		return
+	}
+
+	payload := ts[4:]
+	adaptation := ts[3] & 0x30
+	if adaptation&0x20 != 0 {
+		if int(payload[0])+1 >= len(payload) {
+			return
+		}
+		payload = payload[payload[0]+1:]
+	}
+	if adaptation&0x10 == 0 {
+		return
+	}
+
+	if ts[1]&0x40 == 0 {
+		if len(s.section) > 0 {
+			s.section = append(s.section, payload...)
+			s.parseSections()
+		}
+		return
+	}
+
+	// The pointer field skips the end of the previous section
+	pointer := int(payload[0])
+	if pointer+1 > len(payload) {
+		s.section = s.section[:0]
+		return
+	}
+	if len(s.section) > 0 {
+		s.section = append(s.section, payload[1:pointer+1]...)
+		s.parseSections()
+	}
+
+	s.section = append(s.section[:0], payload[pointer+1:]...)
+	s.parseSections()
+}
+
+// parseSections parses the complete sections at the start of the buffer
+func (s *sdtSink) parseSections() {
+	for len(s.section) >= 3 && s.section[0] != 0xFF {
+		length := 3 + (int(s.section[1]&0x0F)<<8 | int(s.section[2]))
+		if len(s.section) < length {
+			return
+		}
+
+		if crc32MPEG(s.section[:length]) == 0 {
+			s.parseSDT(s.section[:length])
+		}
+		s.section = s.section[length:]
+	}
+
+	s.section = s.section[:0]
+}
+
+func (s *sdtSink) parseSDT(section []byte) {
+	if section[0] != sdtTableActual || len(section) < 15 {
+		return
+	}
+
+	services := section[11 : len(section)-4]
+	for len(services) >= 5 {
+		serviceID := uint16(services[0])<<8 | uint16(services[1])
+		loopLength := int(services[3]&0x0F)<<8 | int(services[4])
+		if 5+loopLength > len(services) {
+			return
+		}
+
+		descriptors := services[5 : 5+loopLength]
+		for len(descriptors) >= 2 && 2+int(descriptors[1]) <= len(descriptors) {
+			tag, body := descriptors[0], descriptors[2:2+int(descriptors[1])]
+			descriptors = descriptors[2+len(body):]
+
+			if tag != serviceDescriptorTag || len(body) < 3 {
+				continue
+			}
+
+			providerLength := int(body[1])
+			if 3+providerLength > len(body) || 3+providerLength+int(body[2+providerLength]) > len(body) {
+				continue
+			}
+			provider := dvbString(body[2 : 2+providerLength])
+			name := dvbString(body[3+providerLength : 3+providerLength+int(body[2+providerLength])])
+
+			if s.services[serviceID] != name {
+				s.services[serviceID] = name
+				s.cb(serviceID, name, provider)
+			}
+		}
+
+		services = services[5+loopLength:]
+	}
+}
+
+// dvbString decodes a DVB text (EN 300 468 Annex A). The character table selector is skipped, UTF-8 is kept and the
+// other tables are read as Latin-1, which matches them for most names. The control codes are removed.
+func dvbString(b []byte) string {
+	utf := false
+	if len(b) > 0 && b[0] < 0x20 {
+		skip := 1
+		switch b[0] {
+		case 0x10:
+			skip = 3
+		case 0x1F:
+			skip = 2
+		case 0x15:
+			utf = true
+		}
+		if skip > len(b) {
+			skip = len(b)
+		}
+		b = b[skip:]
+	}
+
+	if utf && utf8.Valid(b) {
+		return strings.TrimSpace(string(b))
+	}
+
+	sb := strings.Builder{}
+	for _, c := range b {
+		if c < 0x20 || (c >= 0x7F && c < 0xA0) {
+			continue
+		}
+		sb.WriteRune(rune(c))
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// crc32MPEG is the CRC of the PSI sections, zero over a section that includes its CRC_32
+func crc32MPEG(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
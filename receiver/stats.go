@@ -0,0 +1,167 @@
+package receiver
+
+import (
+	"fmt"
+	"github.com/racerxdl/kissdvb/dvbs2"
+	"math"
+	"strings"
+	"sync/atomic"
+)
+
+type Stats struct {
+	Standard string
+	Locked   bool
+	CodeRate CodeRate
+	Mode     string // DSNG modulation and code rate, empty for QPSK
+	Phase    int
+	Rotation int
+	BER      int
+	Packets  int
+	RSErrors int // Corrected bytes in the last packet group
+
+	RSCorrectedBytes   int64
+	RSCorrectedPackets int64
+	RSUncorrectable    int64
+
+	// Symbol quality in dB, EVM in %. NaN when not available.
+	MER        float64
+	EsN0       float64
+	EVM        float64
+	Power      float64
+	LinkMargin float64 // Es/N0 above the QEF threshold of the code rate
+
+	FrequencyOffset     float64 // Costas loop frequency in Hz
+	ClockOmega          float64 // Clock recovery samples per symbol
+	DecoderFifo         int     // Soft bit buffers or PLFRAMEs waiting for the decoder
+	DeinterleaverFrames int
+
+	// DVB-S2
+	ModCod              string
+	PLFrames            int64
+	PLFramesFailed      int64 // LDPC, BCH or BBHEADER errors
+	PLFramesUnsupported int64
+	BCHCorrected        int64
+	TSCRCErrors         int64
+}
+
+func (r *Receiver) GetStats() Stats {
+	mer, esn0, evm, power := r.quality.get()
+
+	r.Lock()
+	frequency := float64(r.costasNew.GetFrequency()) * r.cfg.SampleRate / (2 * math.Pi)
+	omega := float64(r.mmOld.GetOmega())
+	r.Unlock()
+
+	if r.s2 != nil {
+		return r.getDVBS2Stats(mer, esn0, evm, power)
+	}
+
+	s := Stats{
+		Standard: StandardDVBS,
+		Packets:  int(atomic.LoadInt64(&r.packetCount)),
+		RSErrors: int(atomic.LoadInt64(&r.rsErrors)),
+
+		RSCorrectedBytes:   atomic.LoadInt64(&r.rsCorrectedBytes),
+		RSCorrectedPackets: atomic.LoadInt64(&r.rsCorrectedPackets),
+		RSUncorrectable:    atomic.LoadInt64(&r.rsUncorrectable),
+
+		MER:   mer,
+		EsN0:  esn0,
+		EVM:   evm,
+		Power: power,
+
+		FrequencyOffset:     frequency,
+		ClockOmega:          omega,
+		DecoderFifo:         r.decoderFifo.Len(),
+		DeinterleaverFrames: r.deinterleaver.NumStoredFrames(),
+	}
+
+	if r.defec != nil {
+		s.Locked = r.defec.IsLocked()
+		s.CodeRate = r.defec.GetCodeRate()
+		s.Phase = r.defec.GetPuncturePhase()
+		s.Rotation = r.defec.GetRotation()
+		s.BER = r.defec.GetBER()
+		s.LinkMargin = esn0 - qefEsN0(s.CodeRate)
+	}
+
+	if r.tcm != nil {
+		s.Locked = r.tcm.IsLocked()
+		s.Phase = r.tcm.GetOffset()
+		s.Rotation = r.tcm.GetRotation()
+		s.BER = r.tcm.GetBER()
+		s.Mode = strings.ToUpper(r.cfg.Modulation) + " " + r.cfg.CodeRate
+		s.CodeRate = CodeRateAuto
+		s.LinkMargin = math.NaN()
+
+		if mode := r.tcm.GetMode(); mode != nil {
+			s.Mode = mode.String()
+			s.CodeRate = mode.inner
+			s.LinkMargin = esn0 - mode.EsN0QEF()
+		}
+	}
+
+	return s
+}
+
+func (r *Receiver) getDVBS2Stats(mer, esn0, evm, power float64) Stats {
+	s2 := r.s2
+
+	r.Lock()
+	locked := s2.framer.IsLocked()
+	pls := s2.framer.GetPLS()
+	frequency := s2.framer.GetFrequency() * r.cfg.SymbolRate / (2 * math.Pi)
+	omega := float64(r.mmOld.GetOmega())
+	r.Unlock()
+
+	s := Stats{
+		Standard: StandardDVBS2,
+		Locked:   locked,
+		Packets:  int(atomic.LoadInt64(&r.packetCount)),
+
+		MER:        mer,
+		EsN0:       esn0,
+		EVM:        evm,
+		Power:      power,
+		LinkMargin: math.NaN(),
+
+		FrequencyOffset: frequency,
+		ClockOmega:      omega,
+		DecoderFifo:     r.decoderFifo.Len(),
+
+		PLFrames:            atomic.LoadInt64(&s2.frames),
+		PLFramesFailed:      atomic.LoadInt64(&s2.failed),
+		PLFramesUnsupported: atomic.LoadInt64(&s2.unsupported),
+		BCHCorrected:        atomic.LoadInt64(&s2.bchCorrected),
+		TSCRCErrors:         atomic.LoadInt64(&s2.crcErrors),
+	}
+
+	if locked {
+		s.ModCod = pls.String()
+		if m, ok := dvbs2.GetModCod(pls.ModCod); ok {
+			s.LinkMargin = esn0 - m.EsN0QEF
+		}
+	}
+
+	return s
+}
+
+func (s Stats) String() string {
+	if s.Standard == StandardDVBS2 {
+		return fmt.Sprintf("Locked: %t MODCOD: %s Frames: %d (failed %d, unsupported %d) BCH corrected: %d Packets: %d CRC errors: %d MER: %.1f dB Es/N0: %.1f dB EVM: %.1f%% Margin: %.1f dB",
+			s.Locked, s.ModCod, s.PLFrames, s.PLFramesFailed, s.PLFramesUnsupported, s.BCHCorrected, s.Packets, s.TSCRCErrors, s.MER, s.EsN0, s.EVM, s.LinkMargin)
+	}
+
+	fec := s.CodeRate.String()
+	if s.Mode != "" {
+		fec = s.Mode
+	}
+
+	return fmt.Sprintf("Locked: %t FEC: %s Phase: %d Rot: %d BER: %d Packets: %d RS: %d (corrected %d bytes in %d packets, %d uncorrectable) MER: %.1f dB Es/N0: %.1f dB EVM: %.1f%% Margin: %.1f dB",
+		s.Locked, fec, s.Phase, s.Rotation, s.BER, s.Packets, s.RSErrors, s.RSCorrectedBytes, s.RSCorrectedPackets, s.RSUncorrectable, s.MER, s.EsN0, s.EVM, s.LinkMargin)
+}
+
+// HasLinkMargin is false when the code rate is unknown or there is no Es/N0 estimate
+func (s Stats) HasLinkMargin() bool {
+	return !math.IsNaN(s.LinkMargin) && !math.IsInf(s.LinkMargin, 0)
+}
@@ -0,0 +1,430 @@
+package receiver
+
+import (
+	"github.com/racerxdl/kissdvb/dvbs"
+	"github.com/racerxdl/kissdvb/viterbi"
+	"log"
+	"math"
+	"sync"
+)
+
+// Decoded bits needed to check the sync pattern of 5 packets
+const tcmSyncSpan = 4*dvbsFrameBits + 8
+
+type tcmHypothesis struct {
+	mode     *DSNGMode
+	offset   int // Symbols before the first group
+	rotation int // Steps of 2π/symmetry, conjugated from symmetry up
+}
+
+// TCMDecoder is the DeFEC of the trellis coded DSNG modes. It decodes the symbols for every mode, group offset and
+// constellation rotation until the sync pattern is found, then follows the locked hypothesis.
+type TCMDecoder struct {
+	sync.Mutex
+	points     []complex64
+	distance   float32 // Smallest squared distance between points of different subsets
+	symmetry   int
+	hypotheses []tcmHypothesis
+
+	viterbi27   []ViterbiDecoder
+	rotated     [][]complex64
+	softBits    [][]byte
+	mother      [][]byte
+	coded       [][]byte
+	reencoded   [][]byte
+	punctured   [][]byte
+	decoded     [][]byte
+	bitErrors   []int
+	symbols     []complex64
+	maxWindow   int
+	searchShift int
+
+	lock         bool
+	locked       int
+	lockedOffset int
+	start        int // Bit of the locked window where the next frame starts
+	frame        []byte
+	frameReady   bool
+}
+
+// windowGroups returns the groups decoded by each hypothesis of the mode: the frame, the bits before it that
+// settle the Viterbi decoder and the encoder state, one group of slack and the same number of bits after it.
+// It's a multiple of 8 so the Viterbi decoder output is whole bytes.
+func windowGroups(m *DSNGMode) int {
+	b := m.groupBits()
+	groups := (2*numLastFrameBits + scanBits + 2*b - 1) / b
+	return (groups + 7) &^ 7
+}
+
+// MakeTCMDecoder creates a decoder for the modes, which should share the modulation. newViterbi creates the Viterbi
+// decoder of each hypothesis.
+func MakeTCMDecoder(modes []*DSNGMode, newViterbi ViterbiFactory) *TCMDecoder {
+	modulation := modes[0].Modulation
+	points := dsngConstellation(modulation)
+	symmetry := dsngSymmetry(modulation)
+
+	distance := float32(math.MaxFloat32)
+	for i, p := range points {
+		for j, q := range points {
+			if i&3 != j&3 {
+				if d := sqAbs(p - q); d < distance {
+					distance = d
+				}
+			}
+		}
+	}
+
+	t := &TCMDecoder{
+		points:      points,
+		distance:    distance,
+		symmetry:    symmetry,
+		hypotheses:  make([]tcmHypothesis, 0),
+		searchShift: math.MaxInt32,
+	}
+
+	for _, m := range modes {
+		groups := windowGroups(m)
+		windowBits := groups * m.groupBits()
+
+		// Each window searches this many positions, shifting less than that never skips a sync
+		search := windowBits - tcmSyncSpan - numLastFrameBits + 1
+		if shift := (search/m.groupBits() - 1) * m.group; shift < t.searchShift {
+			t.searchShift = shift
+		}
+
+		for offset := 0; offset < m.group; offset++ {
+			if w := offset + groups*m.group; w > t.maxWindow {
+				t.maxWindow = w
+			}
+
+			for rotation := 0; rotation < 2*symmetry; rotation++ {
+				t.hypotheses = append(t.hypotheses, tcmHypothesis{
+					mode:     m,
+					offset:   offset,
+					rotation: rotation,
+				})
+			}
+		}
+	}
+
+	for _, h := range t.hypotheses {
+		groups := windowGroups(h.mode)
+		symbols := groups * h.mode.group
+		codedBits := groups * h.mode.inner.Period()
+
+		v := newViterbi(codedBits)
+		t.viterbi27 = append(t.viterbi27, v)
+		t.rotated = append(t.rotated, make([]complex64, symbols))
+		t.softBits = append(t.softBits, make([]byte, symbols*2))
+		t.mother = append(t.mother, make([]byte, codedBits*2))
+		t.coded = append(t.coded, make([]byte, v.DecodedSize()))
+		t.reencoded = append(t.reencoded, make([]byte, v.DecodedSize()*16))
+		t.punctured = append(t.punctured, make([]byte, v.DecodedSize()*16))
+		// One extra byte for byteAt
+		t.decoded = append(t.decoded, make([]byte, (groups*h.mode.groupBits()+7)/8+1))
+	}
+
+	t.bitErrors = make([]int, len(t.hypotheses))
+
+	return t
+}
+
+// PutSymbols adds clock recovered symbols
+func (t *TCMDecoder) PutSymbols(symbols []complex64) {
+	t.Lock()
+	t.symbols = append(t.symbols, symbols...)
+	t.Unlock()
+}
+
+func (t *TCMDecoder) dropSymbols(n int) {
+	if n > len(t.symbols) {
+		n = len(t.symbols)
+	}
+
+	copy(t.symbols, t.symbols[n:])
+	t.symbols = t.symbols[:len(t.symbols)-n]
+}
+
+func (t *TCMDecoder) hypothesisIndex(mode *DSNGMode, offset, rotation int) int {
+	for i, h := range t.hypotheses {
+		if h.mode == mode && h.offset == offset && h.rotation == rotation {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// demapSymbol returns the soft bits (0 strong 0, 254 strong 1) of the coded bits C1 C0 of a normalized symbol
+func (t *TCMDecoder) demapSymbol(s complex64) (byte, byte) {
+	var min [2][2]float32
+	for b := range min {
+		min[b][0] = math.MaxFloat32
+		min[b][1] = math.MaxFloat32
+	}
+
+	for v, p := range t.points {
+		d := sqAbs(s - p)
+		c1 := v >> 1 & 1
+		c0 := v & 1
+		if d < min[0][c1] {
+			min[0][c1] = d
+		}
+		if d < min[1][c0] {
+			min[1][c0] = d
+		}
+	}
+
+	scale := 0.5 / t.distance
+	return float2byte((min[0][0] - min[0][1]) * scale), float2byte((min[1][0] - min[1][1]) * scale)
+}
+
+// decideUncoded returns the uncoded bits of the closest point of the subset
+func (t *TCMDecoder) decideUncoded(s complex64, subset int) int {
+	best := 0
+	bestDistance := float32(math.MaxFloat32)
+	for u := 0; u < len(t.points)/4; u++ {
+		if d := sqAbs(s - t.points[subset|u<<2]); d < bestDistance {
+			best = u
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+func (t *TCMDecoder) decodeHypothesis(n int) {
+	h := t.hypotheses[n]
+	m := h.mode
+	groups := windowGroups(m)
+	k := m.inner.Period()
+	symbols := t.symbols[h.offset : h.offset+groups*m.group]
+
+	power := float32(0)
+	for _, s := range symbols {
+		power += sqAbs(s)
+	}
+	scale := float32(1)
+	if power > 0 {
+		scale = float32(math.Sqrt(float64(len(symbols)) / float64(power)))
+	}
+
+	sin, cos := math.Sincos(-2 * math.Pi * float64(h.rotation%t.symmetry) / float64(t.symmetry))
+	rotation := complex(float32(cos), float32(sin)) * complex(scale, 0)
+	conjugate := h.rotation >= t.symmetry
+
+	rotated := t.rotated[n]
+	soft := t.softBits[n]
+	for i, s := range symbols {
+		s *= rotation
+		if conjugate {
+			s = complex(real(s), -imag(s))
+		}
+		rotated[i] = s
+		soft[i*2], soft[i*2+1] = t.demapSymbol(s)
+	}
+
+	depuncture(m.inner, 0, soft, t.mother[n])
+	t.viterbi27[n].Decode(t.mother[n], t.coded[n])
+
+	// The coded bits of each symbol come from the decoded bits, they tell the subset for the uncoded bits
+	viterbi.Encode27(t.coded[n], t.reencoded[n])
+	dvbs.Puncture(m.inner, 0, t.reencoded[n], t.punctured[n])
+	punctured := t.punctured[n]
+
+	errors := 0
+	for i := 2 * numLastFrameBits; i < len(soft); i++ {
+		if hardBit(soft[i]) != punctured[i] {
+			errors++
+		}
+	}
+	t.bitErrors[n] = errors
+
+	decoded := t.decoded[n]
+	for i := range decoded {
+		decoded[i] = 0
+	}
+
+	pos := 0
+	putBit := func(b int) {
+		if b != 0 {
+			decoded[pos/8] |= 0x80 >> uint(pos%8)
+		}
+		pos++
+	}
+
+	coded := t.coded[n]
+	for g := 0; g < groups; g++ {
+		for i := g * k; i < (g+1)*k; i++ {
+			putBit(int(coded[i/8]>>uint(7-i%8)) & 1)
+		}
+
+		for j := g * m.group; j < (g+1)*m.group; j++ {
+			subset := int(punctured[j*2])<<1 | int(punctured[j*2+1])
+			u := t.decideUncoded(rotated[j], subset)
+			for b := m.uncoded - 1; b >= 0; b-- {
+				putBit(u >> uint(b) & 1)
+			}
+		}
+	}
+}
+
+func (t *TCMDecoder) decodeAll() {
+	wg := sync.WaitGroup{}
+	wg.Add(len(t.hypotheses))
+
+	for i := 0; i < len(t.hypotheses); i++ {
+		go func(n int) {
+			t.decodeHypothesis(n)
+			wg.Done()
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// TryFindSync decodes the buffered symbols until a frame is ready or more symbols are needed. Returns true when
+// a frame is ready.
+func (t *TCMDecoder) TryFindSync() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	for {
+		if t.lock {
+			h := t.hypotheses[t.locked]
+			if len(t.symbols) < windowGroups(h.mode)*h.mode.group {
+				return false
+			}
+
+			t.decodeHypothesis(t.locked)
+			decoded := t.decoded[t.locked]
+			if syncPatternAt(decoded, t.start) {
+				if t.frame == nil {
+					t.frame = make([]byte, scanBits/8)
+				}
+				for i := range t.frame {
+					t.frame[i] = byteAt(decoded, t.start+i*8)
+				}
+				t.frameReady = true
+
+				// Keep the bits before the next frame for the Viterbi decoder
+				b := h.mode.groupBits()
+				end := t.start + scanBits
+				groups := (end - numLastFrameBits) / b
+				t.dropSymbols(groups * h.mode.group)
+				t.start = end - groups*b
+
+				return true
+			}
+
+			log.Printf("Lost lock at %d (%s, offset %d)!", h.rotation, h.mode, t.lockedOffset)
+			t.lock = false
+		}
+
+		if len(t.symbols) < t.maxWindow {
+			return false
+		}
+
+		t.decodeAll()
+
+		if n, pos := t.findSync(); n != -1 {
+			h := t.hypotheses[n]
+			log.Printf("Got lock at %d (%s, offset %d)\n", h.rotation, h.mode, h.offset)
+
+			b := h.mode.groupBits()
+			groups := (pos - numLastFrameBits) / b
+			t.dropSymbols(h.offset + groups*h.mode.group)
+			t.start = pos - groups*b
+			t.lock = true
+			t.locked = t.hypothesisIndex(h.mode, 0, h.rotation)
+			t.lockedOffset = h.offset
+			continue
+		}
+
+		t.dropSymbols(t.searchShift)
+	}
+}
+
+// findSync returns the first hypothesis with the sync pattern and its position in the decoded bits
+func (t *TCMDecoder) findSync() (int, int) {
+	for pos := numLastFrameBits; ; pos++ {
+		searched := false
+		for i, h := range t.hypotheses {
+			if pos+tcmSyncSpan > windowGroups(h.mode)*h.mode.groupBits() {
+				continue
+			}
+			searched = true
+
+			if syncPatternAt(t.decoded[i], pos) {
+				return i, pos
+			}
+		}
+
+		if !searched {
+			return -1, 0
+		}
+	}
+}
+
+// GetLockedFrame returns the scanPackets interleaved packets of the last frame
+func (t *TCMDecoder) GetLockedFrame() []byte {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.lock && t.frameReady {
+		t.frameReady = false
+		return t.frame
+	}
+
+	return nil
+}
+
+func (t *TCMDecoder) IsLocked() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.lock
+}
+
+// GetMode returns the locked mode, nil when not locked
+func (t *TCMDecoder) GetMode() *DSNGMode {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.lock {
+		return t.hypotheses[t.locked].mode
+	}
+
+	return nil
+}
+
+func (t *TCMDecoder) GetRotation() int {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.hypotheses[t.locked].rotation
+}
+
+// GetOffset returns the symbol offset of the groups found at lock
+func (t *TCMDecoder) GetOffset() int {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.lockedOffset
+}
+
+// GetBER returns the coded bits that differ from the re-encoded ones in the last window, -1 when not locked
+func (t *TCMDecoder) GetBER() int {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.lock {
+		return t.bitErrors[t.locked]
+	}
+
+	return -1
+}
+
+func sqAbs(c complex64) float32 {
+	return real(c)*real(c) + imag(c)*imag(c)
+}
@@ -0,0 +1,137 @@
+package receiver
+
+import (
+	"bytes"
+	"github.com/racerxdl/kissdvb/dvbs"
+	"github.com/racerxdl/kissdvb/viterbi"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// encodeTCM maps the data bits to the symbols of the mode: each group takes the encoder input bits, which give the
+// punctured coded bits of its symbols, followed by the uncoded bits of each symbol
+func encodeTCM(data []byte, m *DSNGMode) []complex64 {
+	k := m.inner.Period()
+	groups := len(data) * 8 / m.groupBits()
+	bit := func(i int) int {
+		return int(data[i/8]>>uint(7-i%8)) & 1
+	}
+
+	input := make([]byte, (groups*k+7)/8)
+	uncoded := make([]int, groups*m.group)
+	pos := 0
+	for g := 0; g < groups; g++ {
+		for i := g * k; i < (g+1)*k; i++ {
+			input[i/8] |= byte(bit(pos) << uint(7-i%8))
+			pos++
+		}
+
+		for j := g * m.group; j < (g+1)*m.group; j++ {
+			for b := 0; b < m.uncoded; b++ {
+				uncoded[j] = uncoded[j]<<1 | bit(pos)
+				pos++
+			}
+		}
+	}
+
+	mother := make([]byte, len(input)*16)
+	viterbi.Encode27(input, mother)
+	punctured := make([]byte, len(mother))
+	dvbs.Puncture(m.inner, 0, mother, punctured)
+
+	points := dsngConstellation(m.Modulation)
+	symbols := make([]complex64, groups*m.group)
+	for j := range symbols {
+		subset := int(punctured[j*2])<<1 | int(punctured[j*2+1])
+		symbols[j] = points[subset|uncoded[j]<<2]
+	}
+
+	return symbols
+}
+
+// addNoise rotates the symbols by phase and adds white gaussian noise for the Es/N0 in dB
+func addNoise(symbols []complex64, phase, esN0 float64, rng *rand.Rand) []complex64 {
+	sigma := math.Sqrt(math.Pow(10, -esN0/10) / 2)
+	sin, cos := math.Sincos(phase)
+	rotation := complex(float32(cos), float32(sin))
+
+	output := make([]complex64, len(symbols))
+	for i, s := range symbols {
+		noise := complex(float32(rng.NormFloat64()*sigma), float32(rng.NormFloat64()*sigma))
+		output[i] = s*rotation + noise
+	}
+
+	return output
+}
+
+func TestTCMRoundTrip(t *testing.T) {
+	const numFrames = 6
+
+	for i, m := range dsngModes {
+		rng := rand.New(rand.NewSource(int64(i)))
+		data := makeDVBSFrames(numFrames, rng)
+
+		// A rotation by a symmetry step and a symbol offset that isn't a multiple of the group
+		symmetry := dsngSymmetry(m.Modulation)
+		symbols := encodeTCM(data, m)[1:]
+		symbols = addNoise(symbols, 2*math.Pi*float64(i%symmetry)/float64(symmetry), m.EsN0QEF()+3, rng)
+
+		tcm := MakeTCMDecoder([]*DSNGMode{m}, GoViterbi(0))
+		decoded := make([]byte, 0, len(data))
+		for start := 0; start < len(symbols); start += 3001 {
+			end := start + 3001
+			if end > len(symbols) {
+				end = len(symbols)
+			}
+			tcm.PutSymbols(symbols[start:end])
+
+			for tcm.TryFindSync() {
+				decoded = append(decoded, tcm.GetLockedFrame()...)
+			}
+		}
+
+		if tcm.GetMode() != m {
+			t.Errorf("%s: locked mode %v", m, tcm.GetMode())
+			continue
+		}
+
+		if len(decoded) < (numFrames-2)*scanBits/8 {
+			t.Errorf("%s: decoded %d frames, expected at least %d", m, len(decoded)/(scanBits/8), numFrames-2)
+			continue
+		}
+
+		start := bytes.Index(data, decoded[:dvbsFrameSize])
+		if start < 0 || !bytes.Equal(decoded, data[start:start+len(decoded)]) {
+			t.Errorf("%s: decoded frames differ from the transmitted ones", m)
+		}
+
+		if ber := tcm.GetBER(); ber < 0 || ber > len(symbols)/100 {
+			t.Errorf("%s: %d coded bit errors", m, ber)
+		}
+	}
+}
+
+func TestTCMAutoMode(t *testing.T) {
+	const numFrames = 4
+
+	m := dsngModes[1] // 8PSK 5/6
+	rng := rand.New(rand.NewSource(10))
+	data := makeDVBSFrames(numFrames, rng)
+	symbols := addNoise(encodeTCM(data, m), 0, m.EsN0QEF()+3, rng)
+
+	modes, err := getDSNGModes(m.Modulation, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcm := MakeTCMDecoder(modes, GoViterbi(0))
+	tcm.PutSymbols(symbols)
+	if !tcm.TryFindSync() || tcm.GetMode() != m {
+		t.Fatalf("locked mode %v, expected %s", tcm.GetMode(), m)
+	}
+
+	if frame := tcm.GetLockedFrame(); frame == nil || !bytes.Contains(data, frame[:dvbsFrameSize]) {
+		t.Error("first frame differs from the transmitted ones")
+	}
+}
@@ -0,0 +1,164 @@
+package receiver
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TSFileSink struct {
+	sync.Mutex
+	filename string
+	file     *os.File
+	writer   *bufio.Writer
+
+	maxSize     int64
+	maxDuration time.Duration
+	fileSize    int64
+	fileStart   time.Time
+	pids        map[uint16]bool
+	closed      bool
+}
+
+// MakeTSFileSink creates a sink writing to filename. When rotating, the next files have the current time appended to the name.
+func MakeTSFileSink(filename string) (*TSFileSink, error) {
+	s := &TSFileSink{
+		filename: filename,
+	}
+
+	if err := s.open(filename); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SetRotation starts a new file after maxSize bytes or maxDuration. Zero disables that limit.
+func (s *TSFileSink) SetRotation(maxSize int64, maxDuration time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.maxSize = maxSize
+	s.maxDuration = maxDuration
+}
+
+// SetPIDFilter only writes packets from the specified PIDs. An empty list writes all packets.
+func (s *TSFileSink) SetPIDFilter(pids []uint16) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(pids) == 0 {
+		s.pids = nil
+		return
+	}
+
+	s.pids = make(map[uint16]bool)
+	for _, pid := range pids {
+		s.pids[pid] = true
+	}
+}
+
+func (s *TSFileSink) open(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	s.file = f
+	s.writer = bufio.NewWriter(f)
+	s.fileSize = 0
+	s.fileStart = time.Now()
+
+	return nil
+}
+
+func (s *TSFileSink) closeFile() error {
+	err := s.writer.Flush()
+	if serr := s.file.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.writer = nil
+	s.file = nil
+
+	return err
+}
+
+// rotatedFilename returns the filename with the current time before the extension, like output-20191020-120000.ts
+func (s *TSFileSink) rotatedFilename() string {
+	ext := filepath.Ext(s.filename)
+	base := strings.TrimSuffix(s.filename, ext)
+	name := fmt.Sprintf("%s-%s%s", base, time.Now().Format("20060102-150405"), ext)
+
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s-%d%s", base, time.Now().Format("20060102-150405"), i, ext)
+	}
+}
+
+func (s *TSFileSink) needsRotation() bool {
+	if s.maxSize > 0 && s.fileSize >= s.maxSize {
+		return true
+	}
+
+	return s.maxDuration > 0 && time.Since(s.fileStart) >= s.maxDuration
+}
+
+func (s *TSFileSink) rotate() {
+	if s.writer != nil {
+		if err := s.closeFile(); err != nil {
+			log.Printf("Error closing TS file: %s", err)
+		}
+	}
+
+	name := s.rotatedFilename()
+	if err := s.open(name); err != nil {
+		log.Printf("Error creating TS file %s: %s", name, err)
+		return
+	}
+
+	log.Printf("Writing TS to %s", name)
+}
+
+func (s *TSFileSink) PutTSFrame(ts []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.pids != nil && !s.pids[tsPID(ts)] {
+		return
+	}
+
+	if !s.closed && (s.writer == nil || s.needsRotation()) {
+		s.rotate()
+	}
+
+	if s.writer != nil {
+		n, err := s.writer.Write(ts)
+		if err != nil {
+			log.Printf("Error writing TS file: %s", err)
+		}
+		s.fileSize += int64(n)
+	}
+}
+
+func (s *TSFileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.closed = true
+
+	if s.writer == nil {
+		return nil
+	}
+
+	return s.closeFile()
+}
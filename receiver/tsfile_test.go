@@ -0,0 +1,130 @@
+package receiver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+// tsFileNames returns the output.ts file of dir followed by the rotated files in name order
+func tsFileNames(t *testing.T, dir string) []string {
+	rotated, err := filepath.Glob(filepath.Join(dir, "output-*.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(rotated)
+	return append([]string{filepath.Join(dir, "output.ts")}, rotated...)
+}
+
+// readTSFiles returns the packets of each file of tsFileNames
+func readTSFiles(t *testing.T, dir string) [][][]byte {
+	names := tsFileNames(t, dir)
+
+	files := make([][][]byte, 0)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(data)%mpegtsFrameSize != 0 {
+			t.Errorf("%s: %d bytes aren't whole packets", filepath.Base(name), len(data))
+		}
+
+		packets := make([][]byte, 0)
+		for i := 0; i+mpegtsFrameSize <= len(data); i += mpegtsFrameSize {
+			packets = append(packets, data[i:i+mpegtsFrameSize])
+		}
+		files = append(files, packets)
+	}
+
+	return files
+}
+
+func TestTSFileRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := MakeTSFileSink(filepath.Join(dir, "output.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.SetRotation(0, 50*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		sink.PutTSFrame(makeTestTSPacket(i))
+	}
+	time.Sleep(60 * time.Millisecond)
+	for i := 10; i < 25; i++ {
+		sink.PutTSFrame(makeTestTSPacket(i))
+	}
+
+	// Close flushes the buffered packets of the second file
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sink.PutTSFrame(makeTestTSPacket(25))
+
+	names := tsFileNames(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("%d files, expected 2", len(names))
+	}
+	if !regexp.MustCompile(`^output-\d{8}-\d{6}\.ts$`).MatchString(filepath.Base(names[1])) {
+		t.Errorf("rotated file %s without the time", filepath.Base(names[1]))
+	}
+
+	files := readTSFiles(t, dir)
+	if len(files[0]) != 10 || len(files[1]) != 15 {
+		t.Fatalf("%d and %d packets, expected 10 and 15", len(files[0]), len(files[1]))
+	}
+
+	n := 0
+	for _, packets := range files {
+		for _, p := range packets {
+			if !bytes.Equal(p, makeTestTSPacket(n)) {
+				t.Fatalf("packet %d differs", n)
+			}
+			n++
+		}
+	}
+}
+
+func TestTSFileSizeRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := MakeTSFileSink(filepath.Join(dir, "output.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.SetRotation(4*mpegtsFrameSize, 0)
+	sink.SetPIDFilter([]uint16{0})
+
+	for i := 0; i < 12; i++ {
+		p := makeTestTSPacket(i)
+		if i%3 == 2 {
+			p[2] = 1 // Filtered
+		}
+		sink.PutTSFrame(p)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The files rotated in the same second are numbered
+	files := readTSFiles(t, dir)
+	if len(files) != 2 || len(files[0]) != 4 || len(files[1]) != 4 {
+		t.Fatalf("%d files, expected 2 of 4 packets", len(files))
+	}
+}
@@ -0,0 +1,169 @@
+package receiver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const udpTSPackets = 7 // 7 * 188 = 1316 bytes, fits in a 1500 bytes MTU
+const rtpHeaderSize = 12
+const rtpPayloadTypeMP2T = 33 // RFC 3551
+const rtpClockRate = 90000
+
+// UDPSink sends the transport stream in UDP datagrams with 7 TS packets each, optionally with a RTP header (RFC 2250)
+type UDPSink struct {
+	sync.Mutex
+	conn       *net.UDPConn
+	buffer     []byte
+	numPackets int
+
+	rtp      bool
+	sequence uint16
+	ssrc     uint32
+	t0       time.Time
+}
+
+// MakeUDPSink creates a sink sending to address (host:port). For multicast destinations the ttl and
+// network interface name are used, an empty iface uses the system default.
+func MakeUDPSink(address string, ttl int, iface string, rtp bool) (*UDPSink, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if addr.IP.IsMulticast() {
+		if err := setMulticastOptions(conn, addr, ttl, iface); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	headerSize := 0
+	if rtp {
+		headerSize = rtpHeaderSize
+	}
+
+	return &UDPSink{
+		conn:     conn,
+		buffer:   make([]byte, headerSize+udpTSPackets*mpegtsFrameSize),
+		rtp:      rtp,
+		sequence: uint16(rand.Uint32()),
+		ssrc:     rand.Uint32(),
+		t0:       time.Now(),
+	}, nil
+}
+
+// setMulticastOptions sets the ttl (hop limit for IPv6) and the interface of a multicast destination
+func setMulticastOptions(conn *net.UDPConn, addr *net.UDPAddr, ttl int, iface string) error {
+	var ifi *net.Interface
+	if iface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(iface); err != nil {
+			return err
+		}
+	}
+
+	if addr.IP.To4() == nil {
+		pc := ipv6.NewPacketConn(conn)
+		if err := pc.SetMulticastHopLimit(ttl); err != nil {
+			return fmt.Errorf("cannot set multicast hop limit: %s", err)
+		}
+		if ifi != nil {
+			if err := pc.SetMulticastInterface(ifi); err != nil {
+				return fmt.Errorf("cannot set multicast interface: %s", err)
+			}
+		}
+		return nil
+	}
+
+	pc := ipv4.NewPacketConn(conn)
+	if err := pc.SetMulticastTTL(ttl); err != nil {
+		return fmt.Errorf("cannot set multicast ttl: %s", err)
+	}
+	if ifi != nil {
+		if err := pc.SetMulticastInterface(ifi); err != nil {
+			return fmt.Errorf("cannot set multicast interface: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *UDPSink) writeRTPHeader() {
+	timestamp := uint32(time.Since(s.t0).Seconds() * rtpClockRate)
+
+	s.buffer[0] = 0x80 // Version 2, no padding, no extension, no CSRC
+	s.buffer[1] = rtpPayloadTypeMP2T
+	binary.BigEndian.PutUint16(s.buffer[2:], s.sequence)
+	binary.BigEndian.PutUint32(s.buffer[4:], timestamp)
+	binary.BigEndian.PutUint32(s.buffer[8:], s.ssrc)
+	s.sequence++
+}
+
+func (s *UDPSink) PutTSFrame(ts []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.conn == nil {
+		return
+	}
+
+	headerSize := len(s.buffer) - udpTSPackets*mpegtsFrameSize
+	copy(s.buffer[headerSize+s.numPackets*mpegtsFrameSize:], ts)
+	s.numPackets++
+
+	if s.numPackets == udpTSPackets {
+		s.flush()
+	}
+}
+
+// flush sends the buffered packets, less than udpTSPackets only when closing
+func (s *UDPSink) flush() {
+	if s.numPackets == 0 {
+		return
+	}
+
+	headerSize := len(s.buffer) - udpTSPackets*mpegtsFrameSize
+	if s.rtp {
+		s.writeRTPHeader()
+	}
+
+	if _, err := s.conn.Write(s.buffer[:headerSize+s.numPackets*mpegtsFrameSize]); err != nil {
+		log.Printf("Error sending UDP datagram: %s", err)
+	}
+
+	s.numPackets = 0
+}
+
+func (s *UDPSink) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *UDPSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	// The last datagram has the packets left
+	s.flush()
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
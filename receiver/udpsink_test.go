@@ -0,0 +1,129 @@
+package receiver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"net"
+	"testing"
+	"time"
+)
+
+func makeTestTSPacket(n int) []byte {
+	p := make([]byte, mpegtsFrameSize)
+	p[0] = 0x47
+	p[3] = byte(n)
+	for i := 4; i < len(p); i++ {
+		p[i] = byte(n + i)
+	}
+	return p
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readDatagram(t *testing.T, conn *net.UDPConn) []byte {
+	buffer := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer[:n]
+}
+
+func TestUDPSink(t *testing.T) {
+	const numPackets = udpTSPackets + 3
+
+	for _, rtp := range []bool{false, true} {
+		listener := listenUDP(t)
+
+		sink, err := MakeUDPSink(listener.LocalAddr().String(), 1, "", rtp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < numPackets; i++ {
+			sink.PutTSFrame(makeTestTSPacket(i))
+		}
+
+		full := readDatagram(t, listener)
+
+		// Close sends the partial datagram
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		partial := readDatagram(t, listener)
+		listener.Close()
+
+		headerSize := 0
+		if rtp {
+			headerSize = rtpHeaderSize
+		}
+
+		if len(full) != headerSize+udpTSPackets*mpegtsFrameSize {
+			t.Fatalf("rtp %v: datagram of %d bytes", rtp, len(full))
+		}
+		if len(partial) != headerSize+(numPackets-udpTSPackets)*mpegtsFrameSize {
+			t.Fatalf("rtp %v: last datagram of %d bytes", rtp, len(partial))
+		}
+
+		payload := append(append([]byte{}, full[headerSize:]...), partial[headerSize:]...)
+		for i := 0; i < numPackets; i++ {
+			if !bytes.Equal(payload[i*mpegtsFrameSize:(i+1)*mpegtsFrameSize], makeTestTSPacket(i)) {
+				t.Errorf("rtp %v: packet %d differs", rtp, i)
+			}
+		}
+
+		if rtp {
+			if full[0] != 0x80 || full[1] != rtpPayloadTypeMP2T {
+				t.Errorf("wrong RTP header %x", full[:2])
+			}
+			if binary.BigEndian.Uint16(partial[2:])-binary.BigEndian.Uint16(full[2:]) != 1 {
+				t.Error("RTP sequence not incremented")
+			}
+			if !bytes.Equal(full[8:12], partial[8:12]) {
+				t.Error("RTP SSRC changed")
+			}
+		}
+	}
+}
+
+func TestUDPSinkMulticast(t *testing.T) {
+	tests := []struct {
+		address string
+		ipv6    bool
+	}{
+		{"239.255.0.1:5004", false},
+		{"[ff05::1]:5004", true},
+	}
+
+	for _, tt := range tests {
+		sink, err := MakeUDPSink(tt.address, 4, "", false)
+		if err != nil {
+			// No route for the family in this environment
+			t.Logf("%s: %s", tt.address, err)
+			continue
+		}
+
+		var hops int
+		if tt.ipv6 {
+			hops, err = ipv6.NewPacketConn(sink.conn).MulticastHopLimit()
+		} else {
+			hops, err = ipv4.NewPacketConn(sink.conn).MulticastTTL()
+		}
+		sink.Close()
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.address, err)
+		} else if hops != 4 {
+			t.Errorf("%s: multicast ttl %d, expected 4", tt.address, hops)
+		}
+	}
+}
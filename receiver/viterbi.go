@@ -0,0 +1,69 @@
+package receiver
+
+import (
+	"fmt"
+	"github.com/OpenSatelliteProject/libsathelper"
+	"github.com/racerxdl/kissdvb/viterbi"
+)
+
+const (
+	ViterbiSatHelper = "sathelper"
+	ViterbiGo        = "go"
+)
+
+// ViterbiDecoder decodes frames of the rate 1/2 K=7 mother code. Input is EncodedSize() soft bits,
+// output is DecodedSize() packed bytes.
+type ViterbiDecoder interface {
+	Decode(input, output []byte)
+	GetBER() int
+	DecodedSize() int
+	EncodedSize() int
+}
+
+// ViterbiFactory creates a decoder for frames of frameBits decoded bits
+type ViterbiFactory func(frameBits int) ViterbiDecoder
+
+type satHelperViterbi struct {
+	v SatHelper.Viterbi27
+}
+
+func (s *satHelperViterbi) Decode(input, output []byte) {
+	s.v.Decode(&input[0], &output[0])
+}
+
+func (s *satHelperViterbi) GetBER() int {
+	return s.v.GetBER()
+}
+
+func (s *satHelperViterbi) DecodedSize() int {
+	return s.v.DecodedSize()
+}
+
+func (s *satHelperViterbi) EncodedSize() int {
+	return s.v.EncodedSize()
+}
+
+// SatHelperViterbi uses the libsathelper (cgo) Viterbi27
+func SatHelperViterbi(frameBits int) ViterbiDecoder {
+	return &satHelperViterbi{v: SatHelper.NewViterbi27(frameBits)}
+}
+
+// GoViterbi uses the pure Go decoder with the specified traceback length (0 for the whole frame)
+func GoViterbi(tracebackLength int) ViterbiFactory {
+	return func(frameBits int) ViterbiDecoder {
+		v := viterbi.MakeViterbi27(frameBits)
+		v.SetTracebackLength(tracebackLength)
+		return v
+	}
+}
+
+func makeViterbiFactory(name string, tracebackLength int) (ViterbiFactory, error) {
+	switch name {
+	case ViterbiSatHelper:
+		return SatHelperViterbi, nil
+	case ViterbiGo:
+		return GoViterbi(tracebackLength), nil
+	}
+
+	return nil, fmt.Errorf("invalid viterbi decoder %q, should be %s or %s", name, ViterbiSatHelper, ViterbiGo)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"github.com/racerxdl/kissdvb/Frontend"
+	"github.com/racerxdl/kissdvb/Frontend/CFileFrontend"
+	"github.com/racerxdl/kissdvb/Frontend/NetworkFrontend"
+	"github.com/racerxdl/kissdvb/Frontend/PipeFrontend"
+	"github.com/racerxdl/kissdvb/Frontend/RtlTcpFrontend"
+	"github.com/racerxdl/kissdvb/receiver"
+	"log"
+	"strings"
+	"time"
+)
+
+const rtlTcpPrefix = "rtltcp://"
+const udpPrefix = "udp://"
+const tcpPrefix = "tcp://"
+
+// setupFrontend creates the frontend of the input: an rtl_tcp server for rtltcp://host:port, raw IQ received at
+// udp://host:port or tcp://host:port, raw IQ from stdin (-) or a named pipe, a SigMF recording or a file.
+// The sample rate and center frequency of a SigMF recording replace the ones of cfg.
+func setupFrontend(cfg *Config) (Frontend.BaseFrontend, error) {
+	if PipeFrontend.IsPipe(cfg.Input) {
+		byteOrder, _ := Frontend.ParseByteOrder(cfg.IQByteOrder)
+
+		frontend, err := PipeFrontend.NewPipeFrontend(cfg.Input, cfg.IQFormat, byteOrder)
+		if err != nil {
+			return nil, err
+		}
+
+		frontend.SetSampleRate(uint32(cfg.SampleRate))
+
+		return frontend, nil
+	}
+
+	if strings.HasPrefix(cfg.Input, udpPrefix) || strings.HasPrefix(cfg.Input, tcpPrefix) {
+		parts := strings.SplitN(cfg.Input, "://", 2)
+		byteOrder, _ := Frontend.ParseByteOrder(cfg.IQByteOrder)
+
+		frontend, err := NetworkFrontend.NewNetworkFrontend(parts[0], parts[1], cfg.IQFormat, byteOrder)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := frontend.SetSequenceBytes(cfg.UDPSequence); err != nil {
+			return nil, err
+		}
+
+		frontend.SetSampleRate(uint32(cfg.SampleRate))
+		if !frontend.Init() {
+			return nil, fmt.Errorf("cannot listen at %s", cfg.Input)
+		}
+
+		return frontend, nil
+	}
+
+	if strings.HasPrefix(cfg.Input, rtlTcpPrefix) {
+		frontend := RtlTcpFrontend.NewRtlTcpFrontend(strings.TrimPrefix(cfg.Input, rtlTcpPrefix))
+		frontend.SetSampleRate(uint32(cfg.SampleRate))
+		frontend.SetCenterFrequency(uint32(cfg.CenterFrequency))
+		frontend.SetGain1(uint8(cfg.Gain))
+		frontend.SetAGC(cfg.AGC)
+
+		if !frontend.Init() {
+			return nil, fmt.Errorf("cannot connect to rtl_tcp at %s", cfg.Input)
+		}
+
+		return frontend, nil
+	}
+
+	if Frontend.IsSigMF(cfg.Input) {
+		frontend, err := CFileFrontend.NewSigMFFrontend(cfg.Input)
+		if err != nil {
+			return nil, err
+		}
+
+		if frontend.GetSampleRate() != 0 {
+			cfg.SampleRate = float64(frontend.GetSampleRate())
+		} else {
+			frontend.SetSampleRate(uint32(cfg.SampleRate))
+		}
+		if frontend.GetCenterFrequency() != 0 {
+			cfg.CenterFrequency = float64(frontend.GetCenterFrequency())
+		}
+		if cfg.FastAsPossible {
+			frontend.EnableFastAsPossible()
+		}
+		setupPlayback(frontend, cfg)
+
+		return frontend, nil
+	}
+
+	frontend := CFileFrontend.NewCFileFrontend(cfg.Input)
+	byteOrder, _ := Frontend.ParseByteOrder(cfg.IQByteOrder)
+	if err := frontend.SetSampleFormat(cfg.IQFormat, byteOrder); err != nil {
+		return nil, err
+	}
+	frontend.SetSampleRate(uint32(cfg.SampleRate))
+	if cfg.FastAsPossible {
+		frontend.EnableFastAsPossible()
+	}
+	setupPlayback(frontend, cfg)
+
+	return frontend, nil
+}
+
+// setupReceivers creates the receivers with their outputs and the input frontend feeding them, and the SigMF
+// recorder of the input when enabled. Without channels there is a single receiver for the whole input.
+func setupReceivers(cfg *Config) (Frontend.BaseFrontend, []*receiver.Receiver, *Frontend.SigMFRecorder, error) {
+	frontend, err := setupFrontend(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The sample rate of a SigMF recording replaces the configured one
+	if err := cfg.Validate(); err != nil {
+		frontend.Destroy()
+		return nil, nil, nil, err
+	}
+
+	receivers := make([]*receiver.Receiver, 0)
+	callbacks := make([]Frontend.SamplesCallback, 0)
+
+	if len(cfg.Channels) == 0 {
+		rx, err := receiver.MakeReceiver(cfg.Config)
+		if err != nil {
+			frontend.Destroy()
+			return nil, nil, nil, err
+		}
+
+		if err := rx.SetupOutputs(cfg.OutputConfig); err != nil {
+			rx.Close()
+			frontend.Destroy()
+			return nil, nil, nil, err
+		}
+
+		receivers = append(receivers, rx)
+		callbacks = append(callbacks, rx.SamplesCallback)
+	}
+
+	for _, chCfg := range cfg.Channels {
+		ch, err := receiver.MakeChannel(cfg.Config, cfg.SampleRate, chCfg)
+		if err != nil {
+			closeReceivers(receivers)
+			frontend.Destroy()
+			return nil, nil, nil, err
+		}
+		receivers = append(receivers, ch.Receiver)
+		callbacks = append(callbacks, ch.SamplesCallback)
+	}
+
+	var recorder *Frontend.SigMFRecorder
+	if cfg.Record != "" {
+		recorder, err = Frontend.MakeSigMFRecorder(cfg.Record, float64(frontend.GetSampleRate()), cfg.CenterFrequency)
+		if err != nil {
+			closeReceivers(receivers)
+			frontend.Destroy()
+			return nil, nil, nil, err
+		}
+
+		// The recorder and the receivers count the same samples, the events are annotated at the sample that caused them
+		callbacks = append([]Frontend.SamplesCallback{recorder.SamplesCallback}, callbacks...)
+		annotateEvents(recorder, receivers)
+	}
+
+	if len(callbacks) == 1 {
+		frontend.SetSamplesAvailableCallback(callbacks[0])
+	} else {
+		frontend.SetSamplesAvailableCallback(Frontend.MakeFanOut(callbacks...))
+	}
+
+	return frontend, receivers, recorder, nil
+}
+
+// annotateEvents adds the lock changes and service names of the receivers to the recording
+func annotateEvents(recorder *Frontend.SigMFRecorder, receivers []*receiver.Receiver) {
+	for i, rx := range receivers {
+		prefix := ""
+		if len(receivers) > 1 {
+			prefix = fmt.Sprintf("channel %d ", i)
+		}
+
+		rx.SetEventCallback(func(e receiver.Event) {
+			recorder.AnnotateAt(uint64(e.Sample), prefix+e.Type, e.Text)
+		})
+	}
+}
+
+func closeReceivers(receivers []*receiver.Receiver) {
+	for _, rx := range receivers {
+		rx.Close()
+	}
+}
+
+// endOfInput returns a channel closed when a finite input like a pipe ends. It is nil (never ready) for live inputs.
+func endOfInput(frontend Frontend.BaseFrontend) <-chan struct{} {
+	if f, ok := frontend.(interface{ Done() <-chan struct{} }); ok {
+		return f.Done()
+	}
+	return nil
+}
+
+// drainReceivers decodes what is still queued in the receivers after the end of the input and logs a summary
+func drainReceivers(frontend Frontend.BaseFrontend, receivers []*receiver.Receiver, started time.Time) {
+	for _, rx := range receivers {
+		rx.Drain()
+	}
+
+	elapsed := time.Since(started)
+	if f, ok := frontend.(interface{ GetSamples() int64 }); ok {
+		samples := f.GetSamples()
+		duration := time.Duration(float64(samples) / float64(frontend.GetSampleRate()) * float64(time.Second))
+		log.Printf("End of input: %d samples (%s of signal) in %s", samples, duration.Round(time.Millisecond), elapsed.Round(time.Millisecond))
+	} else {
+		log.Printf("End of input after %s", elapsed.Round(time.Millisecond))
+	}
+
+	logStats(receivers)
+}
+
+func logStats(receivers []*receiver.Receiver) {
+	if len(receivers) == 1 {
+		log.Println(receivers[0].GetStats())
+		return
+	}
+
+	for i, rx := range receivers {
+		log.Printf("Channel %d: %s", i, rx.GetStats())
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !headless
+// +build !headless
+
 package main
 
 import (
@@ -8,6 +11,8 @@ import (
 	"github.com/golang/freetype/truetype"
 	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/racerxdl/kissdvb/Frontend/CFileFrontend"
+	"github.com/racerxdl/kissdvb/receiver"
 	"image"
 	"image/color"
 	"sync"
@@ -27,12 +32,17 @@ var fonts = make(map[string]*nk.Font)
 var videoFrameNK nk.Image
 var videoTexture int32 = -1
 
+// File playback controlled by the UI, nil for the other inputs
+var playback *CFileFrontend.CFileFrontend
+
 const (
 	winWidth  = 1280
 	winHeight = 900
 
 	maxVertexBuffer  = 512 * 1024
 	maxElementBuffer = 128 * 1024
+
+	playbackWidth = 400
 )
 
 func init() {
@@ -120,8 +130,23 @@ func UpdateConstellation() {
 	gc.Restore()
 	gc.SetFillColor(color.White)
 	gc.SetFontSize(10)
-	gc.FillStringAt(fmt.Sprintf("RS: %02d", rsErrors.Load().(int)), 10, 235)
-	gc.FillStringAt(fmt.Sprintf("BER: %04d Packets: %06d", defec.GetBER(), packetCount.Load().(int)), 10, 250)
+	stats := rx.GetStats()
+	gc.FillStringAt(fmt.Sprintf("MER: %.1f dB Es/N0: %.1f dB EVM: %.1f%%", stats.MER, stats.EsN0, stats.EVM), 10, 15)
+	if stats.HasLinkMargin() {
+		gc.FillStringAt(fmt.Sprintf("Margin: %.1f dB", stats.LinkMargin), 10, 30)
+	}
+	if stats.Standard == receiver.StandardDVBS2 {
+		gc.FillStringAt(fmt.Sprintf("Frames: %d Failed: %d BCH: %d", stats.PLFrames, stats.PLFramesFailed, stats.BCHCorrected), 10, 220)
+		gc.FillStringAt(fmt.Sprintf("MODCOD: %s", stats.ModCod), 10, 235)
+	} else {
+		gc.FillStringAt(fmt.Sprintf("RS fixed: %d B / %d pkt Bad: %d", stats.RSCorrectedBytes, stats.RSCorrectedPackets, stats.RSUncorrectable), 10, 220)
+		fec := stats.CodeRate.String()
+		if stats.Mode != "" {
+			fec = stats.Mode
+		}
+		gc.FillStringAt(fmt.Sprintf("RS: %02d FEC: %s Phase: %d Rot: %d", stats.RSErrors, fec, stats.Phase, stats.Rotation), 10, 235)
+	}
+	gc.FillStringAt(fmt.Sprintf("BER: %04d Packets: %06d", stats.BER, stats.Packets), 10, 250)
 
 	isUpdated = true
 	drawLock.Unlock()
@@ -199,6 +224,9 @@ func rgbaTex(tex int32, rgba *image.RGBA) (nk.Image, int32) {
 
 func DrawConstellation(win *glfw.Window, ctx *nk.Context) {
 	width, height := win.GetSize()
+	if playback != nil {
+		width -= playbackWidth
+	}
 	bounds := nk.NkRect(0, 0, float32(width), float32(height))
 	update := nk.NkBegin(ctx, "Window", bounds, 0)
 	if update > 0 {
@@ -218,7 +246,7 @@ func DrawVideoFrame(win *glfw.Window, ctx *nk.Context) {
 	width, height := win.GetSize()
 	bounds := nk.NkRect(0, 256, float32(width), float32(height)-256)
 	update := nk.NkBegin(ctx, "Video", bounds, 0)
-	if update > 0 {
+	if update > 0 && videoPlayer != nil {
 		if videoPlayer.IsFrameReady() {
 			videoFrameNK, videoTexture = rgbaTex(videoTexture, videoPlayer.GetFrame())
 			isUpdated = false
@@ -231,6 +259,65 @@ func DrawVideoFrame(win *glfw.Window, ctx *nk.Context) {
 	nk.NkEnd(ctx)
 }
 
+// DrawPlayback shows the position of the input file with the pause, loop, seek and speed controls
+func DrawPlayback(win *glfw.Window, ctx *nk.Context) {
+	if playback == nil {
+		return
+	}
+
+	width, _ := win.GetSize()
+	bounds := nk.NkRect(float32(width-playbackWidth), 0, playbackWidth, 256)
+	update := nk.NkBegin(ctx, "Playback", bounds, nk.WindowBorder|nk.WindowTitle)
+	if update > 0 {
+		nk.NkLayoutRowDynamic(ctx, 20, 1)
+		{
+			nk.NkLabel(ctx, playbackStatus(playback), nk.TextLeft)
+			nk.NkLabel(ctx, pacingStatus(playback), nk.TextLeft)
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 25, 1)
+		{
+			// As a fraction, the sample numbers are too large for float32
+			position := float32(0)
+			if length := playback.GetLength(); length > 0 {
+				position = float32(float64(playback.GetPosition()) / float64(length))
+				if nk.NkSliderFloat(ctx, 0, &position, 1, 0.001) > 0 {
+					playback.SeekSample(int64(float64(position) * float64(length)))
+				}
+			}
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 30, 2)
+		{
+			if playback.IsPaused() {
+				if nk.NkButtonLabel(ctx, "Resume") > 0 {
+					playback.Resume()
+				}
+			} else if nk.NkButtonLabel(ctx, "Pause") > 0 {
+				playback.Pause()
+			}
+
+			loop := "Loop: off"
+			if playback.IsLooping() {
+				loop = "Loop: on"
+			}
+			if nk.NkButtonLabel(ctx, loop) > 0 {
+				playback.SetLoop(!playback.IsLooping())
+			}
+		}
+
+		nk.NkLayoutRowDynamic(ctx, 25, 1)
+		{
+			speed := float32(playback.GetSpeed())
+			nk.NkPropertyFloat(ctx, "Speed", 0.1, &speed, 16, 0.1, 0.05)
+			if float64(speed) != playback.GetSpeed() {
+				playback.SetSpeed(float64(speed))
+			}
+		}
+	}
+	nk.NkEnd(ctx)
+}
+
 func gfxMain(win *glfw.Window, ctx *nk.Context) {
 	drawLock.Lock()
 	defer drawLock.Unlock()
@@ -238,6 +325,7 @@ func gfxMain(win *glfw.Window, ctx *nk.Context) {
 	nk.NkPlatformNewFrame()
 
 	DrawConstellation(win, ctx)
+	DrawPlayback(win, ctx)
 	DrawVideoFrame(win, ctx)
 
 	// Render
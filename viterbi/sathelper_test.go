@@ -0,0 +1,36 @@
+//go:build sathelper
+// +build sathelper
+
+package viterbi
+
+import (
+	"github.com/OpenSatelliteProject/libsathelper"
+	"testing"
+)
+
+// TestViterbi27SatHelper compares the decoder with the libsathelper one, run with go test -tags sathelper
+func TestViterbi27SatHelper(t *testing.T) {
+	data := makeTestData(6)
+	encoded := make([]byte, testFrameBits*2)
+	Encode27(data, encoded)
+
+	for _, ebN0 := range []float64{6, 4, 3} {
+		soft := softBits(encoded, ebN0, 7)
+
+		sat := SatHelper.NewViterbi27(testFrameBits)
+		satOutput := make([]byte, sat.DecodedSize())
+		sat.Decode(&soft[0], &satOutput[0])
+
+		v := MakeViterbi27(testFrameBits)
+		output := make([]byte, v.DecodedSize())
+		v.Decode(soft, output)
+
+		if differences := bitErrors(satOutput, output); differences > 0 {
+			t.Errorf("Eb/N0 %g dB: %d decoded bits differ from libsathelper", ebN0, differences)
+		}
+
+		if d := sat.GetBER() - v.GetBER(); d < -testMargin || d > testMargin {
+			t.Errorf("Eb/N0 %g dB: BER %d, libsathelper %d", ebN0, v.GetBER(), sat.GetBER())
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package viterbi
+
+import "math/bits"
+
+// Rate 1/2, K = 7 convolutional code used by DVB-S (EN 300 421) and CCSDS.
+// Generator polynomials G1 = 171 and G2 = 133 (octal), newest bit at LSB.
+const (
+	K     = 7
+	PolyX = 0x4F
+	PolyY = 0x6D
+
+	numStates = 1 << (K - 1)
+	regMask   = (1 << K) - 1
+)
+
+// Soft bits are 0 (strong 0) to 255 (strong 1), 127 is used for erasures
+const softMax = 255
+const softMiddle = 127
+
+var outputX, outputY [1 << K]byte
+
+func init() {
+	for sr := 0; sr < 1<<K; sr++ {
+		outputX[sr] = byte(bits.OnesCount(uint(sr&PolyX)) & 1)
+		outputY[sr] = byte(bits.OnesCount(uint(sr&PolyY)) & 1)
+	}
+}
+
+// Viterbi27 is a soft decision Viterbi decoder for the rate 1/2 K = 7 code. It has the same
+// input / output format as SatHelper.Viterbi27: frameBits*2 soft bytes in and frameBits/8 packed bytes (MSB first) out.
+type Viterbi27 struct {
+	frameBits       int
+	tracebackLength int
+	decisions       []uint64
+	bestStates      []uint8
+	metrics         [2][numStates]uint32
+	encoded         []byte
+	ber             int
+}
+
+// MakeViterbi27 creates a decoder for frames of frameBits decoded bits
+func MakeViterbi27(frameBits int) *Viterbi27 {
+	return &Viterbi27{
+		frameBits:  frameBits,
+		decisions:  make([]uint64, frameBits),
+		bestStates: make([]uint8, frameBits),
+		encoded:    make([]byte, frameBits*2),
+	}
+}
+
+// SetTracebackLength sets how many bits after a decoded bit are used to decide it.
+// Zero (the default) traces back from the best state at the end of the frame.
+func (v *Viterbi27) SetTracebackLength(n int) {
+	if n < 0 {
+		n = 0
+	}
+	v.tracebackLength = n
+}
+
+func (v *Viterbi27) GetTracebackLength() int {
+	return v.tracebackLength
+}
+
+func (v *Viterbi27) DecodedSize() int {
+	return v.frameBits / 8
+}
+
+func (v *Viterbi27) EncodedSize() int {
+	return v.frameBits * 2
+}
+
+// GetBER returns how many hard decisions of the last input differ from the re-encoded output
+func (v *Viterbi27) GetBER() int {
+	return v.ber
+}
+
+// Decode decodes EncodedSize() soft bits from input into DecodedSize() bytes of output.
+// The encoder state at the frame start is unknown, so all states start with the same metric.
+func (v *Viterbi27) Decode(input, output []byte) {
+	n := v.frameBits
+	cur := &v.metrics[0]
+	next := &v.metrics[1]
+
+	for s := range cur {
+		cur[s] = 0
+	}
+
+	var branch [1 << K]uint32
+
+	for t := 0; t < n; t++ {
+		x := uint32(input[t*2])
+		y := uint32(input[t*2+1])
+		dx := [2]uint32{x, softMax - x}
+		dy := [2]uint32{y, softMax - y}
+
+		for sr := 0; sr < 1<<K; sr++ {
+			branch[sr] = dx[outputX[sr]] + dy[outputY[sr]]
+		}
+
+		decision := uint64(0)
+		min := ^uint32(0)
+		best := 0
+
+		for s := 0; s < numStates; s++ {
+			// Predecessors: s>>1 (sr = s) and s>>1 | 0x20 (sr = s | 0x40)
+			p := s >> 1
+			m0 := cur[p] + branch[s]
+			m1 := cur[p|numStates>>1] + branch[s|numStates]
+
+			if m1 < m0 {
+				m0 = m1
+				decision |= 1 << uint(s)
+			}
+
+			next[s] = m0
+			if m0 < min {
+				min = m0
+				best = s
+			}
+		}
+
+		// Normalize to avoid overflows
+		for s := range next {
+			next[s] -= min
+		}
+
+		v.decisions[t] = decision
+		v.bestStates[t] = uint8(best)
+		cur, next = next, cur
+	}
+
+	for i := 0; i < n/8; i++ {
+		output[i] = 0
+	}
+
+	if v.tracebackLength == 0 || v.tracebackLength >= n {
+		v.traceback(int(v.bestStates[n-1]), n, 0, n, output)
+	} else {
+		// Decide each block of tracebackLength bits from the best state tracebackLength bits later
+		for start := 0; start < n; start += v.tracebackLength {
+			end := start + v.tracebackLength
+			if end > n {
+				end = n
+			}
+			from := end + v.tracebackLength
+			if from > n {
+				from = n
+			}
+			v.traceback(int(v.bestStates[from-1]), from, start, end, output)
+		}
+	}
+
+	v.ber = v.countErrors(input, output)
+}
+
+// traceback walks the decisions from state at time "from" and writes the bits in [start, end) to output
+func (v *Viterbi27) traceback(state, from, start, end int, output []byte) {
+	for t := from - 1; t >= start; t-- {
+		bit := byte(state & 1)
+		if t < end && bit == 1 && t/8 < len(output) {
+			output[t/8] |= 0x80 >> uint(t%8)
+		}
+		state = state>>1 | int((v.decisions[t]>>uint(state))&1)<<(K-2)
+	}
+}
+
+func (v *Viterbi27) countErrors(input, output []byte) int {
+	Encode27(output, v.encoded)
+	errors := 0
+
+	for i, e := range v.encoded {
+		hard := byte(0)
+		if input[i] > softMiddle {
+			hard = 1
+		}
+		if hard != e {
+			errors++
+		}
+	}
+
+	return errors
+}
+
+// Encoder27 is the rate 1/2 K = 7 convolutional encoder. The state is kept between calls.
+type Encoder27 struct {
+	sr int
+}
+
+// MakeEncoder27 creates an encoder at state zero
+func MakeEncoder27() *Encoder27 {
+	return &Encoder27{}
+}
+
+// Encode encodes the packed bits (MSB first) of input into len(input)*16 bits of output (X0 Y0 X1 Y1...),
+// one bit (0 or 1) per byte.
+func (e *Encoder27) Encode(input, output []byte) {
+	for i := 0; i < len(input)*8; i++ {
+		bit := int(input[i/8]>>uint(7-i%8)) & 1
+		e.sr = ((e.sr << 1) | bit) & regMask
+		output[i*2] = outputX[e.sr]
+		output[i*2+1] = outputY[e.sr]
+	}
+}
+
+// Encode27 encodes input with a new encoder, see Encoder27.Encode
+func Encode27(input, output []byte) {
+	MakeEncoder27().Encode(input, output)
+}
@@ -0,0 +1,164 @@
+package viterbi
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const testFrameBits = 8192
+
+// Bits at the frame ends depend on the unknown start and end states
+const testMargin = 64
+
+func makeTestData(seed int64) []byte {
+	data := make([]byte, testFrameBits/8)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// softBits maps the encoded bits to BPSK soft bits with white gaussian noise at ebN0 dB. No noise for +Inf.
+func softBits(encoded []byte, ebN0 float64, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+
+	// Rate 1/2: Es/N0 = Eb/N0 - 3 dB
+	sigma := math.Sqrt(1 / (2 * math.Pow(10, (ebN0-3)/10)))
+	soft := make([]byte, len(encoded))
+	for i, b := range encoded {
+		v := float64(b)*2 - 1
+		if !math.IsInf(ebN0, 1) {
+			v += rng.NormFloat64() * sigma
+		}
+		soft[i] = byte(math.Max(0, math.Min(softMax, softMiddle+0.5+v*64)))
+	}
+
+	return soft
+}
+
+// bitErrors counts the different bits outside the margins
+func bitErrors(a, b []byte) int {
+	errors := 0
+	for i := testMargin; i < len(a)*8-testMargin; i++ {
+		if (a[i/8]^b[i/8])>>uint(7-i%8)&1 != 0 {
+			errors++
+		}
+	}
+	return errors
+}
+
+func TestEncode27Impulse(t *testing.T) {
+	// The impulse response is the generator polynomials, 171 and 133 octal from the newest bit
+	encoded := make([]byte, 16)
+	Encode27([]byte{0x80}, encoded)
+
+	expected := []byte{
+		1, 1,
+		1, 0,
+		1, 1,
+		1, 1,
+		0, 0,
+		0, 1,
+		1, 1,
+		0, 0,
+	}
+
+	if !bytes.Equal(encoded, expected) {
+		t.Fatalf("impulse encoded as %v, expected %v", encoded, expected)
+	}
+}
+
+func TestEncoder27KeepsState(t *testing.T) {
+	data := makeTestData(1)
+	whole := make([]byte, len(data)*16)
+	Encode27(data, whole)
+
+	e := MakeEncoder27()
+	split := make([]byte, len(data)*16)
+	e.Encode(data[:100], split)
+	e.Encode(data[100:], split[100*16:])
+
+	if !bytes.Equal(whole, split) {
+		t.Fatal("encoding in two calls differs from one call")
+	}
+}
+
+func TestViterbi27Noise(t *testing.T) {
+	tests := []struct {
+		ebN0      float64
+		traceback int
+		maxErrors int
+	}{
+		{math.Inf(1), 0, 0},
+		{math.Inf(1), 35, 0},
+		{6, 0, 0},
+		{6, 35, 0},
+		{6, 64, 0},
+		{6, 128, 0},
+		{4, 0, 0},
+		{4, 64, 0},
+		{4, 128, 0},
+		{3, 0, 8},
+		{3, 35, 16},
+		{3, 128, 8},
+	}
+
+	data := makeTestData(2)
+	encoded := make([]byte, testFrameBits*2)
+	Encode27(data, encoded)
+
+	for _, tt := range tests {
+		soft := softBits(encoded, tt.ebN0, 3)
+
+		channelErrors := 0
+		for i, b := range encoded {
+			if (soft[i] > softMiddle) != (b == 1) {
+				channelErrors++
+			}
+		}
+		if !math.IsInf(tt.ebN0, 1) && channelErrors == 0 {
+			t.Fatalf("Eb/N0 %g dB: no channel errors", tt.ebN0)
+		}
+
+		v := MakeViterbi27(testFrameBits)
+		v.SetTracebackLength(tt.traceback)
+		output := make([]byte, v.DecodedSize())
+		v.Decode(soft, output)
+
+		if errors := bitErrors(data, output); errors > tt.maxErrors {
+			t.Errorf("Eb/N0 %g dB traceback %d: %d bit errors, expected at most %d", tt.ebN0, tt.traceback, errors, tt.maxErrors)
+		}
+
+		if math.IsInf(tt.ebN0, 1) && v.GetBER() != 0 {
+			t.Errorf("traceback %d: BER %d without noise", tt.traceback, v.GetBER())
+		}
+	}
+}
+
+func TestViterbi27Erasures(t *testing.T) {
+	data := makeTestData(4)
+	encoded := make([]byte, testFrameBits*2)
+	Encode27(data, encoded)
+	soft := softBits(encoded, 8, 5)
+
+	// Rate 3/4 puncturing: X1 Y1 Y2 X3 of each 3 bits are sent
+	for i := 0; i < testFrameBits; i++ {
+		switch i % 3 {
+		case 1:
+			soft[i*2] = softMiddle
+		case 2:
+			soft[i*2+1] = softMiddle
+		}
+	}
+
+	for _, traceback := range []int{0, 96} {
+		v := MakeViterbi27(testFrameBits)
+		v.SetTracebackLength(traceback)
+		output := make([]byte, v.DecodedSize())
+		v.Decode(soft, output)
+
+		if errors := bitErrors(data, output); errors > 0 {
+			t.Errorf("traceback %d: %d bit errors with erasures", traceback, errors)
+		}
+	}
+}